@@ -1,18 +1,24 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/redhatinsights/mbop/internal/clientip"
 	"github.com/redhatinsights/mbop/internal/config"
+	"github.com/redhatinsights/mbop/internal/service/events"
+	"github.com/redhatinsights/mbop/internal/service/jwks"
 	"github.com/redhatinsights/mbop/internal/service/mailer"
+	"github.com/redhatinsights/mbop/internal/service/ocm"
 	"github.com/redhatinsights/platform-go-middlewares/identity"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/redhatinsights/mbop/internal/handlers"
+	"github.com/redhatinsights/mbop/internal/keyring"
 	l "github.com/redhatinsights/mbop/internal/logger"
 	"github.com/redhatinsights/mbop/internal/middleware"
 	"github.com/redhatinsights/mbop/internal/store"
@@ -29,6 +35,14 @@ func main() {
 		panic(err)
 	}
 
+	if _, err := clientip.NewResolverFromConfig(); err != nil {
+		panic(err)
+	}
+
+	if err := handlers.CheckAllowlistRoutes(); err != nil {
+		panic(err)
+	}
+
 	r := chi.NewRouter()
 	// Emulating the log message at the beginning of mainHandler()
 	r.Use(middleware.Logging)
@@ -40,22 +54,39 @@ func main() {
 	r.Post("/v*", handlers.CatchAll)
 	r.Get("/api/entitlements*", handlers.CatchAll)
 	r.Get("/v1/jwt", handlers.JWTV1Handler)
+	r.Get("/v1/jwt/keys", handlers.JWTKeysV1Handler)
+	r.Get("/.well-known/jwks.json", handlers.JWKSHandler)
 	r.Post("/v1/users", handlers.UsersV1Handler)
 	r.Post("/v1/sendEmails", handlers.SendEmails)
 	r.Get("/v3/accounts/{orgID}/users", handlers.AccountsV3UsersHandler)
 	r.Post("/v3/accounts/{orgID}/usersBy", handlers.AccountsV3UsersByHandler)
-	r.Get("/v1/auth", handlers.AuthV1Handler)
+	r.With(handlers.BearerAuth, handlers.EnforceAllowlist(handlers.AllowlistAuth, handlers.OrgFromAuth)).
+		Get("/v1/auth", handlers.AuthV1Handler)
+	r.Get("/v1/registrations/self", handlers.RegistrationSelfHandler)
 
 	// all the handlers that need xrhid
 	r.With(identity.EnforceIdentity).Group(func(r chi.Router) {
 		r.Get("/v1/registrations", handlers.RegistrationListHandler)
-		r.Post("/v1/registrations", handlers.RegistrationCreateHandler)
+		r.With(handlers.EnforceAllowlist(handlers.AllowlistRegistrations, handlers.OrgFromIdentity)).
+			Post("/v1/registrations", handlers.RegistrationCreateHandler)
+		r.Get("/v1/registrations/export", handlers.RegistrationExportHandler)
+		r.Post("/v1/registrations/import", handlers.RegistrationImportHandler)
+		r.Get("/v1/registrations/{uid}", handlers.RegistrationGetHandler)
+		r.Patch("/v1/registrations/{uid}", handlers.RegistrationUpdateHandler)
 		r.Delete("/v1/registrations/{uid}", handlers.RegistrationDeleteHandler)
-		r.Get("/v1/registrations/token", handlers.TokenHandler)
+		r.Post("/v1/registrations/{uid}/restore", handlers.RegistrationRestoreHandler)
+		r.Post("/v1/registrations/{uid}/rotate", handlers.RegistrationRotateHandler)
+		r.With(handlers.EnforceAllowlist(handlers.AllowlistToken, handlers.OrgFromIdentity)).
+			Get("/v1/registrations/token", handlers.TokenHandler)
+		r.Post("/v1/registrations/token/introspect", handlers.TokenIntrospectHandler)
+		r.Delete("/v1/registrations/token/{jti}", handlers.TokenRevokeHandler)
 
 		r.Get("/api/mbop/v1/allowlist", handlers.AllowlistListHandler)
 		r.Post("/api/mbop/v1/allowlist", handlers.AllowlistCreateHandler)
+		r.Put("/api/mbop/v1/allowlist", handlers.AllowlistReplaceHandler)
 		r.Delete("/api/mbop/v1/allowlist", handlers.AllowlistDeleteHandler)
+
+		r.Get("/api/mbop/v1/audit", handlers.AuditListHandler)
 	})
 
 	err := mailer.InitConfig()
@@ -64,6 +95,38 @@ func main() {
 		l.Log.Info("failed to init mailer module", "error", err)
 	}
 
+	_, err = events.NewPublisher()
+	if err != nil {
+		l.Log.Info("failed to init events module", "error", err)
+	}
+
+	_, err = keyring.Get()
+	if err != nil {
+		l.Log.Info("failed to load token keys", "error", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	retention, err := time.ParseDuration(conf.RegistrationRetention)
+	if err != nil {
+		panic(err)
+	}
+	purgeInterval, err := time.ParseDuration(conf.RegistrationPurgeInterval)
+	if err != nil {
+		panic(err)
+	}
+	go store.PurgeDeletedRegistrations(ctx, retention, purgeInterval)
+	go store.PurgeExpiredRevocations(ctx, purgeInterval)
+
+	if conf.JwkURL != "" {
+		cache, err := jwks.FromConfig()
+		if err != nil {
+			panic(err)
+		}
+		go cache.Start(ctx)
+	}
+
 	// listen for OS signals so we can terminate when receiving one
 	interrupts := make(chan os.Signal, 1)
 	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
@@ -97,4 +160,8 @@ func main() {
 	}
 
 	<-interrupts
+
+	if err := ocm.Shutdown(); err != nil {
+		l.Log.Error(err, "error closing sdk connection")
+	}
 }
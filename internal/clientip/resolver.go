@@ -0,0 +1,217 @@
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/redhatinsights/mbop/internal/config"
+	l "github.com/redhatinsights/mbop/internal/logger"
+)
+
+// the header value that switches parsing over to RFC 7239
+const forwardedHeader = "forwarded"
+
+/*
+Resolver works out the address of the client that made a request. The
+forwarding header is only believed as far as it was written by one of the
+trusted proxies, so a client can't just send its own header to pick an
+address:
+  - a connection from anything other than a trusted proxy is the client
+  - otherwise the header is walked right-to-left (nearest hop first), and the
+    first hop that isn't a trusted proxy is the client
+  - if every hop is trusted the left-most one is the client
+  - an unparseable hop ends the walk, leaving the last good address
+*/
+type Resolver struct {
+	trusted []*net.IPNet
+	header  string
+}
+
+// NewResolver builds a resolver trusting the given proxies (CIDRs or single
+// IPs) and reading hops from the given header, either an X-Forwarded-For
+// style list or `Forwarded` for RFC 7239.
+func NewResolver(trustedProxies []string, header string) (*Resolver, error) {
+	res := &Resolver{header: header}
+
+	for _, proxy := range trustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			res.trusted = append(res.trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+			continue
+		}
+
+		_, block, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		res.trusted = append(res.trusted, block)
+	}
+
+	return res, nil
+}
+
+// NewResolverFromConfig builds a resolver from TRUSTED_PROXIES and
+// ALLOWLIST_HEADER. TRUSTED_PROXIES only trusts loopback by default, anything
+// deployed behind an ingress has to list the ingress' CIDRs or every request
+// resolves to the ingress itself.
+func NewResolverFromConfig() (*Resolver, error) {
+	c := config.Get()
+	return NewResolver(strings.Split(c.TrustedProxies, ","), c.AllowlistHeader)
+}
+
+var (
+	defaultResolver *Resolver
+	defaultOnce     sync.Once
+)
+
+// FromRequest resolves the client address with the resolver from the config.
+func FromRequest(r *http.Request) string {
+	defaultOnce.Do(func() {
+		res, err := NewResolverFromConfig()
+		if err != nil {
+			// main refuses to start with a bad config, so this only happens in
+			// tests - falling back to not trusting any headers at all
+			l.Log.Error(err, "invalid client ip config, ignoring forwarding headers")
+			res = &Resolver{}
+		}
+		defaultResolver = res
+	})
+
+	return defaultResolver.ClientIP(r)
+}
+
+// ClientIP returns the resolved client address for the request
+func (res *Resolver) ClientIP(r *http.Request) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+
+	ip := net.ParseIP(remote)
+	if ip == nil || !res.isTrusted(ip) {
+		return remote
+	}
+
+	client := ip.String()
+	hops := res.hops(r)
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseNode(hops[i])
+		if ip == nil {
+			break
+		}
+
+		client = ip.String()
+		if !res.isTrusted(ip) {
+			break
+		}
+	}
+
+	return client
+}
+
+func (res *Resolver) isTrusted(ip net.IP) bool {
+	for _, block := range res.trusted {
+		if block.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// the hops in the forwarding header(s), left-most (furthest away) first
+func (res *Resolver) hops(r *http.Request) []string {
+	if res.header == "" {
+		return nil
+	}
+
+	values := r.Header.Values(res.header)
+	if strings.EqualFold(res.header, forwardedHeader) {
+		return forwardedFor(values)
+	}
+
+	hops := make([]string, 0, len(values))
+	for _, v := range values {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	return hops
+}
+
+// pulls the `for` parameter out of each RFC 7239 forwarded-element, elements
+// without one come back empty so they still count as a (unparseable) hop
+func forwardedFor(values []string) []string {
+	hops := make([]string, 0, len(values))
+
+	for _, v := range values {
+		for _, element := range splitQuoted(v, ',') {
+			var node string
+			for _, pair := range splitQuoted(element, ';') {
+				key, value, found := strings.Cut(pair, "=")
+				if found && strings.EqualFold(strings.TrimSpace(key), "for") {
+					node = value
+				}
+			}
+			hops = append(hops, node)
+		}
+	}
+
+	return hops
+}
+
+// splits s on sep, ignoring any seps inside a quoted string
+func splitQuoted(s string, sep rune) []string {
+	var parts []string
+	var quoted, escaped bool
+
+	start := 0
+	for i, c := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case c == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+// parses a single hop, which can have a port on it and (for RFC 7239) be
+// quoted with IPv6 addresses in brackets, e.g. `"[2001:db8::1]:4711"`.
+// Obfuscated identifiers and `unknown` aren't addresses so come back nil.
+func parseNode(node string) net.IP {
+	node = strings.Trim(strings.TrimSpace(node), `"`)
+
+	if ip := net.ParseIP(node); ip != nil {
+		return ip
+	}
+
+	if strings.HasPrefix(node, "[") {
+		end := strings.Index(node, "]")
+		if end == -1 {
+			return nil
+		}
+		return net.ParseIP(node[1:end])
+	}
+
+	host, _, err := net.SplitHostPort(node)
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
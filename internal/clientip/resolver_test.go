@@ -0,0 +1,67 @@
+package clientip
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	xff, err := NewResolver([]string{"10.0.0.0/8", " 192.168.1.1", "fd00::/8"}, "x-forwarded-for")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fwd, err := NewResolver([]string{"10.0.0.0/8"}, "Forwarded")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		res      *Resolver
+		remote   string
+		header   string
+		values   []string
+		expected string
+	}{
+		{"no header", xff, "1.2.3.4:1234", "", nil, "1.2.3.4"},
+		{"untrusted peer ignores header", xff, "1.2.3.4:1234", "X-Forwarded-For", []string{"5.6.7.8"}, "1.2.3.4"},
+		{"trusted peer no header", xff, "10.0.0.1:1234", "", nil, "10.0.0.1"},
+		{"single hop", xff, "10.0.0.1:1234", "X-Forwarded-For", []string{"5.6.7.8"}, "5.6.7.8"},
+		{"multi hop", xff, "10.0.0.1:1234", "X-Forwarded-For", []string{"1.2.3.4, 10.0.0.2"}, "1.2.3.4"},
+		{"spoofed hop", xff, "10.0.0.1:1234", "X-Forwarded-For", []string{"1.2.3.4, 5.6.7.8, 10.0.0.2"}, "5.6.7.8"},
+		{"single trusted ip", xff, "192.168.1.1:1234", "X-Forwarded-For", []string{"5.6.7.8"}, "5.6.7.8"},
+		{"repeated headers", xff, "10.0.0.1:1234", "X-Forwarded-For", []string{"1.2.3.4", "10.0.0.2"}, "1.2.3.4"},
+		{"all trusted", xff, "10.0.0.1:1234", "X-Forwarded-For", []string{"10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"garbage hop", xff, "10.0.0.1:1234", "X-Forwarded-For", []string{"1.2.3.4, nope, 10.0.0.2"}, "10.0.0.2"},
+		{"hop with port", xff, "10.0.0.1:1234", "X-Forwarded-For", []string{"1.2.3.4:5678"}, "1.2.3.4"},
+		{"ipv6", xff, "[fd00::1]:1234", "X-Forwarded-For", []string{"2001:db8::1"}, "2001:db8::1"},
+		{"wrong header", xff, "10.0.0.1:1234", "Forwarded", []string{"for=1.2.3.4"}, "10.0.0.1"},
+
+		{"forwarded", fwd, "10.0.0.1:1234", "Forwarded", []string{"for=1.2.3.4;proto=https"}, "1.2.3.4"},
+		{"forwarded multi hop", fwd, "10.0.0.1:1234", "Forwarded", []string{`for=5.6.7.8, for="[2001:db8::1]:4711";by=10.0.0.1, For=10.0.0.2`}, "2001:db8::1"},
+		{"forwarded quoted port", fwd, "10.0.0.1:1234", "Forwarded", []string{`for="1.2.3.4:5678"`}, "1.2.3.4"},
+		{"forwarded unknown", fwd, "10.0.0.1:1234", "Forwarded", []string{"for=unknown, for=10.0.0.2"}, "10.0.0.2"},
+		{"forwarded no for", fwd, "10.0.0.1:1234", "Forwarded", []string{"for=1.2.3.4, proto=http"}, "10.0.0.1"},
+		{"forwarded ignores xff", fwd, "10.0.0.1:1234", "X-Forwarded-For", []string{"1.2.3.4"}, "10.0.0.1"},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "http://foobar/", nil)
+		req.RemoteAddr = c.remote
+		for _, v := range c.values {
+			req.Header.Add(c.header, v)
+		}
+
+		if out := c.res.ClientIP(req); out != c.expected {
+			t.Errorf("%s: expected %q got %q", c.name, c.expected, out)
+		}
+	}
+}
+
+func TestNewResolverInvalidProxy(t *testing.T) {
+	for _, proxy := range []string{"nope", "10.0.0.0/33", "10.0.0.1, 10.0.0.2"} {
+		if _, err := NewResolver([]string{proxy}, "x-forwarded-for"); err == nil {
+			t.Errorf("expected error for trusted proxy %q", proxy)
+		}
+	}
+}
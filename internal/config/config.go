@@ -12,8 +12,15 @@ type MbopConfig struct {
 	SESAccessKey           string
 	SESSecretKey           string
 	MailerModule           string
+	EventsModule           string
+	EventsWebhookURL       string
+	EventsMaxRetries       int
+	EventsRetryBackoff     string
 	JwtModule              string
 	JwkURL                 string
+	JwksCacheTTL           string
+	JwksMinRefreshInterval string
+	JwksFetchTimeout       string
 	UsersModule            string
 	CognitoAppClientID     string
 	CognitoAppClientSecret string
@@ -24,6 +31,12 @@ type MbopConfig struct {
 	TokenKID               string
 	PrivateKey             string
 	PublicKey              string
+	TokenKeyDir            string
+	TokenIssuer            string
+	TokenAudiences         string
+	TokenScopes            string
+	TokenPreviousKID       string
+	TokenPreviousPublicKey string
 	DisableCatchall        bool
 	IsInternalLabel        string
 	Debug                  bool
@@ -42,6 +55,8 @@ type MbopConfig struct {
 
 	AllowlistEnabled bool
 	AllowlistHeader  string
+	AllowlistRoutes  string
+	TrustedProxies   string
 	StoreBackend     string
 	DatabaseHost     string
 	DatabasePort     string
@@ -49,6 +64,12 @@ type MbopConfig struct {
 	DatabasePassword string
 	DatabaseName     string
 
+	RegistrationRetention     string
+	RegistrationPurgeInterval string
+	RegistrationQuota         int
+	AllowlistQuota            int
+	RegistrationImporters     string
+
 	Port    string
 	TLSPort string
 	UseTLS  bool
@@ -68,6 +89,9 @@ func Get() *MbopConfig {
 	certDir := fetchWithDefault("CERT_DIR", "/certs")
 	keyCloakTimeout, _ := strconv.ParseInt(fetchWithDefault("KEYCLOAK_TIMEOUT", "60"), 0, 64)
 	userServiceTimeout, _ := strconv.ParseInt(fetchWithDefault("KEYCLOAK_USER_SERVICE_TIMEOUT", "60"), 0, 64)
+	registrationQuota, _ := strconv.Atoi(fetchWithDefault("REGISTRATION_QUOTA", "0"))
+	allowlistQuota, _ := strconv.Atoi(fetchWithDefault("ALLOWLIST_QUOTA", "0"))
+	eventsMaxRetries, _ := strconv.Atoi(fetchWithDefault("EVENTS_MAX_RETRIES", "3"))
 
 	var tls bool
 	_, err := os.Stat(certDir + "/tls.crt")
@@ -76,16 +100,20 @@ func Get() *MbopConfig {
 	}
 
 	c := &MbopConfig{
-		UsersModule:     fetchWithDefault("USERS_MODULE", ""),
-		JwtModule:       fetchWithDefault("JWT_MODULE", ""),
-		JwkURL:          fetchWithDefault("JWK_URL", ""),
-		MailerModule:    fetchWithDefault("MAILER_MODULE", "print"),
-		FromEmail:       fetchWithDefault("FROM_EMAIL", "no-reply@redhat.com"),
-		ToEmail:         fetchWithDefault("TO_EMAIL", "no-reply@redhat.com"),
-		SESRegion:       fetchWithDefault("SES_REGION", "us-east-1"),
-		SESAccessKey:    fetchWithDefault("SES_ACCESS_KEY", ""),
-		SESSecretKey:    fetchWithDefault("SES_SECRET_KEY", ""),
-		DisableCatchall: disableCatchAll,
+		UsersModule:            fetchWithDefault("USERS_MODULE", ""),
+		JwtModule:              fetchWithDefault("JWT_MODULE", ""),
+		JwkURL:                 fetchWithDefault("JWK_URL", ""),
+		JwksCacheTTL:           fetchWithDefault("JWKS_CACHE_TTL", "15m"),
+		JwksFetchTimeout:       fetchWithDefault("JWKS_FETCH_TIMEOUT", "10s"),
+		JwksMinRefreshInterval: fetchWithDefault("JWKS_MIN_REFRESH_INTERVAL", "30s"),
+		MailerModule:           fetchWithDefault("MAILER_MODULE", "print"),
+		EventsModule:           fetchWithDefault("EVENTS_MODULE", "print"),
+		FromEmail:              fetchWithDefault("FROM_EMAIL", "no-reply@redhat.com"),
+		ToEmail:                fetchWithDefault("TO_EMAIL", "no-reply@redhat.com"),
+		SESRegion:              fetchWithDefault("SES_REGION", "us-east-1"),
+		SESAccessKey:           fetchWithDefault("SES_ACCESS_KEY", ""),
+		SESSecretKey:           fetchWithDefault("SES_SECRET_KEY", ""),
+		DisableCatchall:        disableCatchAll,
 
 		DatabaseHost:     fetchWithDefault("DATABASE_HOST", "localhost"),
 		DatabasePort:     fetchWithDefault("DATABASE_PORT", "5432"),
@@ -95,6 +123,18 @@ func Get() *MbopConfig {
 		StoreBackend:     fetchWithDefault("STORE_BACKEND", "memory"),
 		AllowlistEnabled: allowlistEnabled,
 		AllowlistHeader:  fetchWithDefault("ALLOWLIST_HEADER", "x-forwarded-for"),
+		AllowlistRoutes:  fetchWithDefault("ALLOWLIST_ROUTES", "registrations"),
+		TrustedProxies:   fetchWithDefault("TRUSTED_PROXIES", "127.0.0.0/8,::1/128"),
+
+		RegistrationRetention:     fetchWithDefault("REGISTRATION_RETENTION", "720h"),
+		RegistrationPurgeInterval: fetchWithDefault("REGISTRATION_PURGE_INTERVAL", "1h"),
+		RegistrationQuota:         registrationQuota,
+		AllowlistQuota:            allowlistQuota,
+		RegistrationImporters:     fetchWithDefault("REGISTRATION_IMPORTERS", ""),
+
+		EventsWebhookURL:   fetchWithDefault("EVENTS_WEBHOOK_URL", ""),
+		EventsMaxRetries:   eventsMaxRetries,
+		EventsRetryBackoff: fetchWithDefault("EVENTS_RETRY_BACKOFF", "1s"),
 
 		CognitoAppClientID:     fetchWithDefault("COGNITO_APP_CLIENT_ID", ""),
 		CognitoAppClientSecret: fetchWithDefault("COGNITO_APP_CLIENT_SECRET", ""),
@@ -105,6 +145,12 @@ func Get() *MbopConfig {
 		TokenKID:               fetchWithDefault("TOKEN_KID", ""),
 		PrivateKey:             fetchWithDefault("TOKEN_PRIVATE_KEY", ""),
 		PublicKey:              fetchWithDefault("TOKEN_PUBLIC_KEY", ""),
+		TokenKeyDir:            fetchWithDefault("TOKEN_KEY_DIR", ""),
+		TokenIssuer:            fetchWithDefault("TOKEN_ISSUER", "mbop"),
+		TokenAudiences:         fetchWithDefault("TOKEN_AUDIENCES", ""),
+		TokenScopes:            fetchWithDefault("TOKEN_SCOPES", ""),
+		TokenPreviousKID:       fetchWithDefault("TOKEN_PREVIOUS_KID", ""),
+		TokenPreviousPublicKey: fetchWithDefault("TOKEN_PREVIOUS_PUBLIC_KEY", ""),
 		IsInternalLabel:        fetchWithDefault("IS_INTERNAL_LABEL", ""),
 		Debug:                  debug,
 
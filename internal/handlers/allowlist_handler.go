@@ -3,24 +3,57 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
-	"net"
+	"fmt"
 	"net/http"
-	"strings"
 	"time"
 
 	l "github.com/redhatinsights/mbop/internal/logger"
+	"github.com/redhatinsights/mbop/internal/service/events"
 	"github.com/redhatinsights/mbop/internal/store"
 	"github.com/redhatinsights/platform-go-middlewares/identity"
 )
 
 type allowlistCreateRequest struct {
-	IPBlock string `json:"ip_block"`
+	IPBlock     string     `json:"ip_block"`
+	Description string     `json:"description,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
 }
 
 type allowListResponse struct {
-	IPBlock   string    `json:"ip_block"`
-	OrgID     string    `json:"org_id"`
-	CreatedAt time.Time `json:"created_at"`
+	IPBlock     string     `json:"ip_block"`
+	OrgID       string     `json:"org_id"`
+	Description string     `json:"description"`
+	CreatedBy   string     `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+type allowlistConflictResponse struct {
+	Message   string                   `json:"message"`
+	Conflicts []allowlistConflictBlock `json:"conflicts"`
+}
+
+// Relation is how the existing block relates to the one being added
+// (candidate), one of duplicate/superset/subset
+type allowlistConflictBlock struct {
+	IPBlock   string `json:"ip_block"`
+	Relation  string `json:"relation"`
+	Candidate string `json:"candidate"`
+}
+
+func newAllowlistConflictResponse(err store.ErrAllowlistConflict) *allowlistConflictResponse {
+	out := &allowlistConflictResponse{
+		Message:   err.Error(),
+		Conflicts: make([]allowlistConflictBlock, len(err.Conflicts)),
+	}
+	for i := range err.Conflicts {
+		out.Conflicts[i] = allowlistConflictBlock{
+			IPBlock:   err.Conflicts[i].IPBlock,
+			Relation:  err.Conflicts[i].Relation,
+			Candidate: err.Conflicts[i].Candidate,
+		}
+	}
+	return out
 }
 
 func AllowlistCreateHandler(w http.ResponseWriter, r *http.Request) {
@@ -37,24 +70,24 @@ func AllowlistCreateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !strings.Contains(createReq.IPBlock, "/") {
-		createReq.IPBlock += "/32"
-	}
-
-	_, _, err = net.ParseCIDR(createReq.IPBlock)
+	block, err := newAllowlistBlock(&createReq, id.Identity.User.Username)
 	if err != nil {
-		do400(w, "invalid IP block, needs to be an IPv4 range or single IP")
+		do400(w, err.Error())
 		return
 	}
+	createReq.IPBlock = block.IPBlock
+	block.OrgID = id.Identity.OrgID
 
 	db := store.GetStore()
 
-	err = db.AllowAddress(&store.AllowlistBlock{IPBlock: createReq.IPBlock, OrgID: id.Identity.OrgID})
+	err = db.AllowAddress(block)
 	if err != nil {
-		do500(w, "error storing address: "+err.Error())
+		doAllowlistError(w, err, "error storing address: ")
 		return
 	}
 
+	recordAudit(r, store.AuditAllowlistCreate, createReq.IPBlock)
+	publishEvent(r, events.Event{Type: events.AllowlistChanged, IPBlock: createReq.IPBlock})
 	w.WriteHeader(201)
 }
 
@@ -71,9 +104,23 @@ func AllowlistDeleteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	raw := block
+	block, err := store.CanonicalBlock(raw)
+	if err != nil {
+		do400(w, "invalid IP block, needs to be an IPv4/IPv6 range or single IP")
+		return
+	}
+
 	db := store.GetStore()
 
-	err := db.DenyAddress(&store.AllowlistBlock{IPBlock: block, OrgID: id.Identity.OrgID})
+	err = db.DenyAddress(&store.AllowlistBlock{IPBlock: block, OrgID: id.Identity.OrgID})
+	// blocks added before they were canonicalized are stored as they were sent
+	if errors.Is(err, store.ErrAddressNotAllowListed) && raw != block {
+		err = db.DenyAddress(&store.AllowlistBlock{IPBlock: raw, OrgID: id.Identity.OrgID})
+		if err == nil {
+			block = raw
+		}
+	}
 	if err != nil {
 		if errors.Is(err, store.ErrAddressNotAllowListed) {
 			doError(w, "ip not allowlisted", 404)
@@ -84,9 +131,61 @@ func AllowlistDeleteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recordAudit(r, store.AuditAllowlistDelete, block)
+	publishEvent(r, events.Event{Type: events.AllowlistChanged, IPBlock: block})
 	w.WriteHeader(204)
 }
 
+// AllowlistReplaceHandler swaps the org's whole allowlist for the blocks in
+// the body, so it can be managed declaratively. Emptying the allowlist locks
+// the org out, so an empty list needs `?allow_empty=true` and a null body is
+// never taken as one.
+func AllowlistReplaceHandler(w http.ResponseWriter, r *http.Request) {
+	id := identity.Get(r.Context())
+	if !id.Identity.User.OrgAdmin {
+		doError(w, "user must be org admin to replace allowlist", 403)
+		return
+	}
+
+	var replaceReq []allowlistCreateRequest
+	err := json.NewDecoder(r.Body).Decode(&replaceReq)
+	if err != nil {
+		do400(w, "invalid json in body - expected an array of blocks with [ip_block], [description] and [expires_at]")
+		return
+	}
+	if replaceReq == nil {
+		do400(w, "invalid json in body - expected an array of blocks, not null")
+		return
+	}
+	if len(replaceReq) == 0 && r.URL.Query().Get("allow_empty") != "true" {
+		do400(w, "refusing to remove every block from the allowlist without allow_empty=true")
+		return
+	}
+
+	blocks := make([]store.AllowlistBlock, len(replaceReq))
+	for i := range replaceReq {
+		block, err := newAllowlistBlock(&replaceReq[i], id.Identity.User.Username)
+		if err != nil {
+			do400(w, fmt.Sprintf("block %d: %s", i, err.Error()))
+			return
+		}
+		blocks[i] = *block
+	}
+
+	db := store.GetStore()
+
+	err = db.ReplaceAllowlist(id.Identity.OrgID, blocks)
+	if err != nil {
+		doAllowlistError(w, err, "error replacing allowlist: ")
+		return
+	}
+
+	recordAudit(r, store.AuditAllowlistReplace, fmt.Sprintf("%d blocks", len(blocks)))
+	publishEvent(r, events.Event{Type: events.AllowlistChanged})
+
+	AllowlistListHandler(w, r)
+}
+
 func AllowlistListHandler(w http.ResponseWriter, r *http.Request) {
 	id := identity.Get(r.Context())
 	if !id.Identity.User.OrgAdmin {
@@ -105,9 +204,12 @@ func AllowlistListHandler(w http.ResponseWriter, r *http.Request) {
 	out := make([]allowListResponse, len(addrs))
 	for i, addr := range addrs {
 		out[i] = allowListResponse{
-			IPBlock:   addr.IPBlock,
-			OrgID:     addr.OrgID,
-			CreatedAt: addr.CreatedAt,
+			IPBlock:     addr.IPBlock,
+			OrgID:       addr.OrgID,
+			Description: addr.Description,
+			CreatedBy:   addr.CreatedBy,
+			CreatedAt:   addr.CreatedAt,
+			ExpiresAt:   addr.ExpiresAt,
 		}
 	}
 
@@ -116,3 +218,34 @@ func AllowlistListHandler(w http.ResponseWriter, r *http.Request) {
 		l.Log.Info("failed to encode response", "error", err)
 	}
 }
+
+// validates and canonicalizes a block from a request
+func newAllowlistBlock(req *allowlistCreateRequest, createdBy string) (*store.AllowlistBlock, error) {
+	block, err := store.CanonicalBlock(req.IPBlock)
+	if err != nil {
+		return nil, errors.New("invalid IP block, needs to be an IPv4/IPv6 range or single IP")
+	}
+
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		return nil, errors.New("parameter [expires_at] must be in the future")
+	}
+
+	return &store.AllowlistBlock{
+		IPBlock:     block,
+		Description: req.Description,
+		CreatedBy:   createdBy,
+		ExpiresAt:   req.ExpiresAt,
+	}, nil
+}
+
+func doAllowlistError(w http.ResponseWriter, err error, msg string) {
+	var conflict store.ErrAllowlistConflict
+	switch {
+	case errors.As(err, &conflict):
+		sendJSONWithStatusCode(w, newAllowlistConflictResponse(conflict), 409)
+	case errors.Is(err, store.ErrQuotaExceeded{}):
+		doError(w, err.Error(), 429)
+	default:
+		do500(w, msg+err.Error())
+	}
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/redhatinsights/mbop/internal/config"
+	"github.com/redhatinsights/mbop/internal/logger"
+	"github.com/redhatinsights/mbop/internal/store"
+	"github.com/redhatinsights/platform-go-middlewares/identity"
+	"github.com/stretchr/testify/suite"
+)
+
+type AllowlistTestSuite struct {
+	suite.Suite
+	rec   *httptest.ResponseRecorder
+	store store.Store
+}
+
+func (suite *AllowlistTestSuite) SetupSuite() {
+	_ = logger.Init()
+	config.Reset()
+	os.Setenv("STORE_BACKEND", "memory")
+}
+
+func (suite *AllowlistTestSuite) BeforeTest(_, _ string) {
+	suite.rec = httptest.NewRecorder()
+	suite.Nil(store.SetupStore())
+
+	// creating a new store for every test and overriding the dep injection function
+	suite.store = store.GetStore()
+	store.GetStore = func() store.Store { return suite.store }
+}
+
+func (suite *AllowlistTestSuite) AfterTest(_, _ string) {
+	suite.rec.Result().Body.Close()
+}
+
+func TestAllowlistEndpoint(t *testing.T) {
+	suite.Run(t, new(AllowlistTestSuite))
+}
+
+func (suite *AllowlistTestSuite) TestCreateIPv4Default() {
+	AllowlistCreateHandler(suite.rec, newAllowlistRequest(http.MethodPost, "http://foobar/api/mbop/v1/allowlist", `{"ip_block": "10.0.0.1"}`))
+
+	//nolint:bodyclose
+	suite.Equal(http.StatusCreated, suite.rec.Result().StatusCode)
+
+	addrs, err := suite.store.AllowedAddresses("1234")
+	suite.Nil(err)
+	suite.Equal("10.0.0.1/32", addrs[0].IPBlock)
+}
+
+func (suite *AllowlistTestSuite) TestCreateIPv6Default() {
+	AllowlistCreateHandler(suite.rec, newAllowlistRequest(http.MethodPost, "http://foobar/api/mbop/v1/allowlist", `{"ip_block": "2001:DB8::1"}`))
+
+	//nolint:bodyclose
+	suite.Equal(http.StatusCreated, suite.rec.Result().StatusCode)
+
+	addrs, err := suite.store.AllowedAddresses("1234")
+	suite.Nil(err)
+	suite.Equal("2001:db8::1/128", addrs[0].IPBlock)
+}
+
+func (suite *AllowlistTestSuite) TestCreateCanonicalized() {
+	AllowlistCreateHandler(suite.rec, newAllowlistRequest(http.MethodPost, "http://foobar/api/mbop/v1/allowlist", `{"ip_block": "2001:db8::1/32"}`))
+
+	//nolint:bodyclose
+	suite.Equal(http.StatusCreated, suite.rec.Result().StatusCode)
+
+	addrs, err := suite.store.AllowedAddresses("1234")
+	suite.Nil(err)
+	suite.Equal("2001:db8::/32", addrs[0].IPBlock)
+}
+
+func (suite *AllowlistTestSuite) TestCreateInvalid() {
+	AllowlistCreateHandler(suite.rec, newAllowlistRequest(http.MethodPost, "http://foobar/api/mbop/v1/allowlist", `{"ip_block": "2001:db8::1/129"}`))
+
+	status, body := suite.statusAndBody()
+	suite.Equal(http.StatusBadRequest, status)
+	suite.Equal(`{"message":"invalid IP block, needs to be an IPv4/IPv6 range or single IP"}`, body)
+}
+
+func (suite *AllowlistTestSuite) TestCreateOverlapping() {
+	suite.Nil(suite.store.AllowAddress(&store.AllowlistBlock{OrgID: "1234", IPBlock: "10.0.0.0/16"}))
+
+	AllowlistCreateHandler(suite.rec, newAllowlistRequest(http.MethodPost, "http://foobar/api/mbop/v1/allowlist", `{"ip_block": "10.0.1.0/24"}`))
+
+	status, body := suite.statusAndBody()
+	suite.Equal(http.StatusConflict, status)
+	suite.Equal(`{"message":"ip blocks overlap: 10.0.0.0/16 is a superset of 10.0.1.0/24","conflicts":[{"ip_block":"10.0.0.0/16","relation":"superset","candidate":"10.0.1.0/24"}]}`, body)
+}
+
+func (suite *AllowlistTestSuite) TestDeleteCanonicalized() {
+	suite.Nil(suite.store.AllowAddress(&store.AllowlistBlock{OrgID: "1234", IPBlock: "2001:db8::1/128"}))
+
+	AllowlistDeleteHandler(suite.rec, newAllowlistRequest(http.MethodDelete, "http://foobar/api/mbop/v1/allowlist?block=2001:DB8::1", ""))
+
+	//nolint:bodyclose
+	suite.Equal(http.StatusNoContent, suite.rec.Result().StatusCode)
+
+	addrs, err := suite.store.AllowedAddresses("1234")
+	suite.Nil(err)
+	suite.Len(addrs, 0)
+}
+
+func (suite *AllowlistTestSuite) TestDeleteStoredBeforeCanonicalized() {
+	// as stored before blocks were canonicalized on the way in
+	suite.Nil(suite.store.AllowAddress(&store.AllowlistBlock{OrgID: "1234", IPBlock: "10.0.0.1/8"}))
+
+	AllowlistDeleteHandler(suite.rec, newAllowlistRequest(http.MethodDelete, "http://foobar/api/mbop/v1/allowlist?block=10.0.0.1/8", ""))
+
+	//nolint:bodyclose
+	suite.Equal(http.StatusNoContent, suite.rec.Result().StatusCode)
+
+	addrs, err := suite.store.AllowedAddresses("1234")
+	suite.Nil(err)
+	suite.Len(addrs, 0)
+}
+
+func (suite *AllowlistTestSuite) statusAndBody() (int, string) {
+	//nolint:bodyclose
+	rsp := suite.rec.Result()
+	body, _ := io.ReadAll(rsp.Body)
+	return rsp.StatusCode, string(body)
+}
+
+func newAllowlistRequest(method, url, body string) *http.Request {
+	req := httptest.NewRequest(method, url, bytes.NewReader([]byte(body)))
+	return req.WithContext(context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+		User:  identity.User{OrgAdmin: true, Username: "foobar"},
+		OrgID: "1234",
+	}}))
+}
+
+func (suite *AllowlistTestSuite) TestCreateWithMetadata() {
+	AllowlistCreateHandler(suite.rec, newAllowlistRequest(http.MethodPost, "http://foobar/api/mbop/v1/allowlist",
+		`{"ip_block": "10.0.0.0/24", "description": "office", "expires_at": "2099-01-01T00:00:00Z"}`))
+
+	//nolint:bodyclose
+	suite.Equal(http.StatusCreated, suite.rec.Result().StatusCode)
+
+	suite.rec = httptest.NewRecorder()
+	AllowlistListHandler(suite.rec, newAllowlistRequest(http.MethodGet, "http://foobar/api/mbop/v1/allowlist", ""))
+
+	_, body := suite.statusAndBody()
+	var out []allowListResponse
+	suite.Nil(json.Unmarshal([]byte(body), &out))
+	suite.Equal("office", out[0].Description)
+	suite.Equal("foobar", out[0].CreatedBy)
+	suite.Equal(2099, out[0].ExpiresAt.Year())
+}
+
+func (suite *AllowlistTestSuite) TestCreateExpiresInPast() {
+	AllowlistCreateHandler(suite.rec, newAllowlistRequest(http.MethodPost, "http://foobar/api/mbop/v1/allowlist",
+		`{"ip_block": "10.0.0.0/24", "expires_at": "2001-01-01T00:00:00Z"}`))
+
+	status, body := suite.statusAndBody()
+	suite.Equal(http.StatusBadRequest, status)
+	suite.Equal(`{"message":"parameter [expires_at] must be in the future"}`, body)
+}
+
+func (suite *AllowlistTestSuite) TestReplace() {
+	suite.Nil(suite.store.AllowAddress(&store.AllowlistBlock{OrgID: "1234", IPBlock: "192.168.0.0/24"}))
+
+	AllowlistReplaceHandler(suite.rec, newAllowlistRequest(http.MethodPut, "http://foobar/api/mbop/v1/allowlist",
+		`[{"ip_block": "10.0.0.1", "description": "one"}, {"ip_block": "2001:db8::1"}]`))
+
+	status, body := suite.statusAndBody()
+	suite.Equal(http.StatusOK, status)
+
+	var out []allowListResponse
+	suite.Nil(json.Unmarshal([]byte(body), &out))
+	suite.Len(out, 2)
+	suite.Equal("10.0.0.1/32", out[0].IPBlock)
+	suite.Equal("one", out[0].Description)
+	suite.Equal("2001:db8::1/128", out[1].IPBlock)
+}
+
+func (suite *AllowlistTestSuite) TestReplaceOverlapping() {
+	AllowlistReplaceHandler(suite.rec, newAllowlistRequest(http.MethodPut, "http://foobar/api/mbop/v1/allowlist",
+		`[{"ip_block": "10.0.0.1"}, {"ip_block": "10.0.0.0/24"}]`))
+
+	status, body := suite.statusAndBody()
+	suite.Equal(http.StatusConflict, status)
+	suite.Equal(`{"message":"ip blocks overlap: 10.0.0.1/32 is a subset of 10.0.0.0/24","conflicts":[{"ip_block":"10.0.0.1/32","relation":"subset","candidate":"10.0.0.0/24"}]}`, body)
+}
+
+func (suite *AllowlistTestSuite) TestReplaceInvalidBlock() {
+	AllowlistReplaceHandler(suite.rec, newAllowlistRequest(http.MethodPut, "http://foobar/api/mbop/v1/allowlist",
+		`[{"ip_block": "10.0.0.1"}, {"ip_block": "nope"}]`))
+
+	status, body := suite.statusAndBody()
+	suite.Equal(http.StatusBadRequest, status)
+	suite.Equal(`{"message":"block 1: invalid IP block, needs to be an IPv4/IPv6 range or single IP"}`, body)
+}
+
+func (suite *AllowlistTestSuite) TestReplaceNull() {
+	suite.Nil(suite.store.AllowAddress(&store.AllowlistBlock{OrgID: "1234", IPBlock: "192.168.0.0/24"}))
+
+	AllowlistReplaceHandler(suite.rec, newAllowlistRequest(http.MethodPut, "http://foobar/api/mbop/v1/allowlist?allow_empty=true", `null`))
+
+	status, _ := suite.statusAndBody()
+	suite.Equal(http.StatusBadRequest, status)
+
+	blocks, err := suite.store.AllowedAddresses("1234")
+	suite.Nil(err)
+	suite.Len(blocks, 1)
+}
+
+func (suite *AllowlistTestSuite) TestReplaceEmpty() {
+	suite.Nil(suite.store.AllowAddress(&store.AllowlistBlock{OrgID: "1234", IPBlock: "192.168.0.0/24"}))
+
+	AllowlistReplaceHandler(suite.rec, newAllowlistRequest(http.MethodPut, "http://foobar/api/mbop/v1/allowlist", `[]`))
+
+	status, body := suite.statusAndBody()
+	suite.Equal(http.StatusBadRequest, status)
+	suite.Equal(`{"message":"refusing to remove every block from the allowlist without allow_empty=true"}`, body)
+
+	blocks, err := suite.store.AllowedAddresses("1234")
+	suite.Nil(err)
+	suite.Len(blocks, 1)
+}
+
+func (suite *AllowlistTestSuite) TestReplaceEmptyAllowed() {
+	suite.Nil(suite.store.AllowAddress(&store.AllowlistBlock{OrgID: "1234", IPBlock: "192.168.0.0/24"}))
+
+	AllowlistReplaceHandler(suite.rec, newAllowlistRequest(http.MethodPut, "http://foobar/api/mbop/v1/allowlist?allow_empty=true", `[]`))
+
+	status, body := suite.statusAndBody()
+	suite.Equal(http.StatusOK, status)
+	suite.Equal("[]\n", body)
+}
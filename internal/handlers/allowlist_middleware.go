@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/redhatinsights/mbop/internal/clientip"
+	"github.com/redhatinsights/mbop/internal/config"
+	"github.com/redhatinsights/mbop/internal/store"
+	"github.com/redhatinsights/platform-go-middlewares/identity"
+)
+
+// the route groups the allowlist can be enforced on, picked with ALLOWLIST_ROUTES
+const (
+	AllowlistRegistrations = "registrations"
+	AllowlistAuth          = "auth"
+	AllowlistToken         = "token"
+)
+
+var allowlistRouteGroups = []string{AllowlistRegistrations, AllowlistAuth, AllowlistToken}
+
+type allowlistDeniedResponse struct {
+	Message string `json:"message"`
+	IP      string `json:"ip"`
+}
+
+// OrgLookup works out which org's allowlist a request is checked against. An
+// empty org means it can't be told yet, in which case the request is passed
+// through for the handler to reject however it normally would.
+type OrgLookup func(r *http.Request) (string, error)
+
+// OrgFromIdentity takes the org from the x-rh-identity header
+func OrgFromIdentity(r *http.Request) (string, error) {
+	return identity.Get(r.Context()).Identity.OrgID, nil
+}
+
+// OrgFromCert takes the org from the registration matching the gateway cert's CN
+func OrgFromCert(r *http.Request) (string, error) {
+	gatewayCN, err := getCertCN(r.Header.Get(CertHeader))
+	if err != nil {
+		return "", nil
+	}
+
+	reg, err := store.GetStore().FindByUID(gatewayCN)
+	if err != nil {
+		if errors.Is(err, store.ErrRegistrationNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return reg.OrgID, nil
+}
+
+// OrgFromAuth takes the org from a bearer token's identity when there is one,
+// otherwise from the gateway cert
+func OrgFromAuth(r *http.Request) (string, error) {
+	if id, ok := bearerIdentity(r); ok {
+		return id.Identity.OrgID, nil
+	}
+	return OrgFromCert(r)
+}
+
+// EnforceAllowlist rejects requests from addresses that aren't on the org's
+// allowlist. It only does anything while the allowlist is enabled and the
+// route group is one of the configured ALLOWLIST_ROUTES, so it can be attached
+// to each group of routes up front.
+func EnforceAllowlist(group string, lookup OrgLookup) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !allowlistEnforced(group) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			orgID, err := lookup(r)
+			if err != nil {
+				do500(w, "error finding org for allowlist: "+err.Error())
+				return
+			}
+			if orgID == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ip := clientip.FromRequest(r)
+			allowed, err := store.GetStore().AllowedIP(ip, orgID)
+			if err != nil {
+				do500(w, "error listing ip addresses: "+err.Error())
+				return
+			}
+			if !allowed {
+				sendJSONWithStatusCode(w, &allowlistDeniedResponse{
+					Message: fmt.Sprintf("address %s is not allowlisted", ip),
+					IP:      ip,
+				}, 403)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func allowlistEnforced(group string) bool {
+	c := config.Get()
+	if !c.AllowlistEnabled {
+		return false
+	}
+
+	return stringInSlice(group, splitList(c.AllowlistRoutes))
+}
+
+// CheckAllowlistRoutes makes sure every configured route group exists, so a
+// typo doesn't quietly leave routes open.
+func CheckAllowlistRoutes() error {
+	for _, g := range splitList(config.Get().AllowlistRoutes) {
+		if !stringInSlice(g, allowlistRouteGroups) {
+			return fmt.Errorf("unknown allowlist route group %q, must be one of %v", g, allowlistRouteGroups)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/redhatinsights/mbop/internal/config"
+	"github.com/redhatinsights/mbop/internal/logger"
+	"github.com/redhatinsights/mbop/internal/store"
+	"github.com/redhatinsights/platform-go-middlewares/identity"
+	"github.com/stretchr/testify/suite"
+)
+
+type AllowlistMiddlewareTestSuite struct {
+	suite.Suite
+	rec   *httptest.ResponseRecorder
+	store store.Store
+}
+
+func (suite *AllowlistMiddlewareTestSuite) SetupSuite() {
+	_ = logger.Init()
+	config.Reset()
+	os.Setenv("STORE_BACKEND", "memory")
+}
+
+func (suite *AllowlistMiddlewareTestSuite) BeforeTest(_, _ string) {
+	suite.rec = httptest.NewRecorder()
+	suite.Nil(store.SetupStore())
+
+	// creating a new store for every test and overriding the dep injection function
+	suite.store = store.GetStore()
+	store.GetStore = func() store.Store { return suite.store }
+
+	config.Get().AllowlistEnabled = true
+	config.Get().AllowlistRoutes = "registrations, auth"
+	suite.Nil(suite.store.AllowAddress(&store.AllowlistBlock{OrgID: "1234", IPBlock: "1.2.3.0/24"}))
+}
+
+func (suite *AllowlistMiddlewareTestSuite) AfterTest(_, _ string) {
+	suite.rec.Result().Body.Close()
+	config.Get().AllowlistEnabled = false
+	config.Get().AllowlistRoutes = "registrations"
+}
+
+func TestAllowlistMiddleware(t *testing.T) {
+	suite.Run(t, new(AllowlistMiddlewareTestSuite))
+}
+
+// a handler that only says it was reached
+var reachedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusTeapot)
+})
+
+func (suite *AllowlistMiddlewareTestSuite) TestAllowed() {
+	req := newAllowlistMiddlewareRequest("1.2.3.4, 127.0.0.2")
+	EnforceAllowlist(AllowlistRegistrations, OrgFromIdentity)(reachedHandler).ServeHTTP(suite.rec, req)
+
+	status, _ := suite.statusAndBody()
+	suite.Equal(http.StatusTeapot, status)
+}
+
+func (suite *AllowlistMiddlewareTestSuite) TestDenied() {
+	req := newAllowlistMiddlewareRequest("5.6.7.8")
+	EnforceAllowlist(AllowlistRegistrations, OrgFromIdentity)(reachedHandler).ServeHTTP(suite.rec, req)
+
+	status, body := suite.statusAndBody()
+	suite.Equal(http.StatusForbidden, status)
+	suite.Equal(`{"message":"address 5.6.7.8 is not allowlisted","ip":"5.6.7.8"}`, body)
+}
+
+func (suite *AllowlistMiddlewareTestSuite) TestSpoofedHeader() {
+	// the client made up the first hop, the proxy appended the real address
+	req := newAllowlistMiddlewareRequest("1.2.3.4, 5.6.7.8")
+	EnforceAllowlist(AllowlistRegistrations, OrgFromIdentity)(reachedHandler).ServeHTTP(suite.rec, req)
+
+	status, body := suite.statusAndBody()
+	suite.Equal(http.StatusForbidden, status)
+	suite.Equal(`{"message":"address 5.6.7.8 is not allowlisted","ip":"5.6.7.8"}`, body)
+}
+
+func (suite *AllowlistMiddlewareTestSuite) TestRouteGroupNotEnforced() {
+	req := newAllowlistMiddlewareRequest("5.6.7.8")
+	EnforceAllowlist(AllowlistToken, OrgFromIdentity)(reachedHandler).ServeHTTP(suite.rec, req)
+
+	status, _ := suite.statusAndBody()
+	suite.Equal(http.StatusTeapot, status)
+}
+
+func (suite *AllowlistMiddlewareTestSuite) TestAllowlistDisabled() {
+	config.Get().AllowlistEnabled = false
+
+	req := newAllowlistMiddlewareRequest("5.6.7.8")
+	EnforceAllowlist(AllowlistRegistrations, OrgFromIdentity)(reachedHandler).ServeHTTP(suite.rec, req)
+
+	status, _ := suite.statusAndBody()
+	suite.Equal(http.StatusTeapot, status)
+}
+
+func (suite *AllowlistMiddlewareTestSuite) TestCertDenied() {
+	_, err := suite.store.Create(&store.Registration{OrgID: "1234", UID: "abc1234"})
+	suite.Nil(err)
+
+	req := newAllowlistMiddlewareRequest("5.6.7.8")
+	req.Header.Set(CertHeader, "/CN=abc1234")
+	EnforceAllowlist(AllowlistAuth, OrgFromCert)(reachedHandler).ServeHTTP(suite.rec, req)
+
+	status, _ := suite.statusAndBody()
+	suite.Equal(http.StatusForbidden, status)
+}
+
+func (suite *AllowlistMiddlewareTestSuite) TestCertAllowed() {
+	_, err := suite.store.Create(&store.Registration{OrgID: "1234", UID: "abc1234"})
+	suite.Nil(err)
+
+	req := newAllowlistMiddlewareRequest("1.2.3.4")
+	req.Header.Set(CertHeader, "/CN=abc1234")
+	EnforceAllowlist(AllowlistAuth, OrgFromCert)(reachedHandler).ServeHTTP(suite.rec, req)
+
+	status, _ := suite.statusAndBody()
+	suite.Equal(http.StatusTeapot, status)
+}
+
+func (suite *AllowlistMiddlewareTestSuite) TestUnknownCertPassesThrough() {
+	// no registration to find the org from, left to the handler to reject
+	req := newAllowlistMiddlewareRequest("5.6.7.8")
+	req.Header.Set(CertHeader, "/CN=abc1234")
+	EnforceAllowlist(AllowlistAuth, OrgFromCert)(reachedHandler).ServeHTTP(suite.rec, req)
+
+	status, _ := suite.statusAndBody()
+	suite.Equal(http.StatusTeapot, status)
+}
+
+func (suite *AllowlistMiddlewareTestSuite) TestCheckAllowlistRoutes() {
+	suite.Nil(CheckAllowlistRoutes())
+
+	config.Get().AllowlistRoutes = "registrations,tokens"
+	suite.NotNil(CheckAllowlistRoutes())
+}
+
+func (suite *AllowlistMiddlewareTestSuite) statusAndBody() (int, string) {
+	//nolint:bodyclose
+	rsp := suite.rec.Result()
+	b, err := io.ReadAll(rsp.Body)
+	suite.Nil(err)
+	return rsp.StatusCode, string(b)
+}
+
+// a request coming through a trusted proxy that forwarded it for the given hops
+func newAllowlistMiddlewareRequest(forwardedFor string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/", nil)
+	req.Header.Set("x-forwarded-for", forwardedFor)
+	req.RemoteAddr = "127.0.0.1:1234"
+	return req.WithContext(context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+		User:  identity.User{OrgAdmin: true, Username: "foobar"},
+		OrgID: "1234",
+	}}))
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/redhatinsights/mbop/internal/clientip"
+	l "github.com/redhatinsights/mbop/internal/logger"
+	"github.com/redhatinsights/mbop/internal/store"
+	"github.com/redhatinsights/platform-go-middlewares/identity"
+)
+
+type auditCollection struct {
+	Entries []auditResponse `json:"entries"`
+	Meta    auditMeta       `json:"meta"`
+}
+
+type auditResponse struct {
+	Actor     string    `json:"actor"`
+	OrgID     string    `json:"org_id"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target"`
+	SourceIP  string    `json:"source_ip"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type auditMeta struct {
+	Count int `json:"count"`
+}
+
+func AuditListHandler(w http.ResponseWriter, r *http.Request) {
+	id := identity.Get(r.Context())
+	if !id.Identity.User.OrgAdmin {
+		doError(w, "user must be org admin to list audit log", 403)
+		return
+	}
+
+	limit, err := getLimit(r)
+	if err != nil {
+		do400(w, err.Error())
+		return
+	}
+	offset, err := getOffset(r)
+	if err != nil {
+		do400(w, err.Error())
+		return
+	}
+
+	db := store.GetStore()
+	entries, count, err := db.AuditEntries(id.Identity.OrgID, limit, offset)
+	if err != nil {
+		do500(w, "error listing audit log: "+err.Error())
+		return
+	}
+
+	out := make([]auditResponse, len(entries))
+	for i := range entries {
+		out[i] = auditResponse{
+			Actor:     entries[i].Actor,
+			OrgID:     entries[i].OrgID,
+			Action:    entries[i].Action,
+			Target:    entries[i].Target,
+			SourceIP:  entries[i].SourceIP,
+			CreatedAt: entries[i].CreatedAt,
+		}
+	}
+
+	sendJSON(w, &auditCollection{
+		Entries: out,
+		Meta: auditMeta{
+			Count: count,
+		},
+	})
+}
+
+// recordAudit stores an audit entry for a mutation made by the identity on the
+// request. The mutation has already happened at this point so a failure is
+// only logged rather than failing the request.
+func recordAudit(r *http.Request, action, target string) {
+	id := identity.Get(r.Context())
+
+	err := store.GetStore().RecordAudit(&store.AuditEntry{
+		Actor:    id.Identity.User.Username,
+		OrgID:    id.Identity.OrgID,
+		Action:   action,
+		Target:   target,
+		SourceIP: clientip.FromRequest(r),
+	})
+	if err != nil {
+		l.Log.Error(err, "failed to record audit entry", "action", action, "target", target, "org_id", id.Identity.OrgID)
+	}
+}
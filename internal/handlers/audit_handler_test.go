@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/redhatinsights/mbop/internal/config"
+	"github.com/redhatinsights/mbop/internal/logger"
+	"github.com/redhatinsights/mbop/internal/store"
+	"github.com/redhatinsights/platform-go-middlewares/identity"
+	"github.com/stretchr/testify/suite"
+)
+
+type AuditTestSuite struct {
+	suite.Suite
+	rec   *httptest.ResponseRecorder
+	store store.Store
+}
+
+func (suite *AuditTestSuite) SetupSuite() {
+	_ = logger.Init()
+	config.Reset()
+	os.Setenv("STORE_BACKEND", "memory")
+}
+
+func (suite *AuditTestSuite) BeforeTest(_, _ string) {
+	suite.rec = httptest.NewRecorder()
+	suite.Nil(store.SetupStore())
+
+	// creating a new store for every test and overriding the dep injection function
+	suite.store = store.GetStore()
+	store.GetStore = func() store.Store { return suite.store }
+}
+
+func (suite *AuditTestSuite) AfterTest(_, _ string) {
+	suite.rec.Result().Body.Close()
+}
+
+func TestAuditEndpoint(t *testing.T) {
+	suite.Run(t, new(AuditTestSuite))
+}
+
+func (suite *AuditTestSuite) TestMutationsAreAudited() {
+	ctx := context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+		User:  identity.User{OrgAdmin: true, Username: "foobar"},
+		OrgID: "1234",
+	}})
+
+	req := httptest.NewRequest(http.MethodPost, "http://foobar/registrations", bytes.NewReader([]byte(`{"uid": "abc1234", "display_name": "foobar"}`))).
+		WithContext(ctx)
+	req.Header.Set(CertHeader, "/CN=abc1234")
+	req.Header.Set("x-forwarded-for", "10.0.0.1, 127.0.0.2")
+	// the forwarding header is only believed coming from a trusted proxy
+	req.RemoteAddr = "127.0.0.1:1234"
+	RegistrationCreateHandler(httptest.NewRecorder(), req)
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("uid", "abc1234")
+	req = httptest.NewRequest(http.MethodDelete, "http://foobar/registrations/{uid}", nil).
+		WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+	RegistrationDeleteHandler(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodPost, "http://foobar/api/mbop/v1/allowlist", bytes.NewReader([]byte(`{"ip_block": "10.0.0.0/24"}`))).
+		WithContext(ctx)
+	AllowlistCreateHandler(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodGet, "http://foobar/api/mbop/v1/audit", nil).WithContext(ctx)
+	AuditListHandler(suite.rec, req)
+
+	//nolint:bodyclose
+	rsp := suite.rec.Result()
+	suite.Equal(http.StatusOK, rsp.StatusCode)
+
+	b, err := io.ReadAll(rsp.Body)
+	suite.Nil(err)
+
+	var body auditCollection
+	suite.Nil(json.Unmarshal(b, &body))
+	suite.Equal(3, body.Meta.Count)
+
+	suite.Equal(store.AuditAllowlistCreate, body.Entries[0].Action)
+	suite.Equal("10.0.0.0/24", body.Entries[0].Target)
+
+	suite.Equal(store.AuditRegistrationDelete, body.Entries[1].Action)
+	suite.Equal("abc1234", body.Entries[1].Target)
+
+	suite.Equal(store.AuditRegistrationCreate, body.Entries[2].Action)
+	suite.Equal("foobar", body.Entries[2].Actor)
+	suite.Equal("1234", body.Entries[2].OrgID)
+	suite.Equal("10.0.0.1", body.Entries[2].SourceIP)
+}
+
+func (suite *AuditTestSuite) TestNotOrgAdminList() {
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/api/mbop/v1/audit", nil).
+		WithContext(context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+			User:  identity.User{OrgAdmin: false, Username: "foobar"},
+			OrgID: "1234",
+		}}))
+	AuditListHandler(suite.rec, req)
+
+	//nolint:bodyclose
+	rsp := suite.rec.Result()
+	suite.Equal(http.StatusForbidden, rsp.StatusCode)
+}
+
+func (suite *AuditTestSuite) TestNegativeOffsetList() {
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/api/mbop/v1/audit?offset=-1", nil).
+		WithContext(context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+			User:  identity.User{OrgAdmin: true, Username: "foobar"},
+			OrgID: "1234",
+		}}))
+	AuditListHandler(suite.rec, req)
+
+	//nolint:bodyclose
+	rsp := suite.rec.Result()
+	suite.Equal(http.StatusBadRequest, rsp.StatusCode)
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/redhatinsights/mbop/internal/config"
+	"github.com/redhatinsights/mbop/internal/service/userprovider"
 	"github.com/redhatinsights/mbop/internal/store"
 )
 
@@ -32,39 +33,60 @@ type User struct {
 }
 
 func AuthV1Handler(w http.ResponseWriter, r *http.Request) {
-	switch config.Get().UsersModule {
-	case amsModule, mockModule, keycloakModule:
-		gatewayCN, err := getCertCN(r.Header.Get(CertHeader))
-		if err != nil {
-			do400(w, err.Error())
-			return
-		}
-
-		db := store.GetStore()
-
-		reg, err := db.FindByUID(gatewayCN)
-		if err != nil {
-			if errors.Is(err, store.ErrRegistrationNotFound) {
-				doError(w, err.Error(), 401)
-			} else {
-				do500(w, "failed to search for registration: "+err.Error())
-			}
-			return
-		}
+	if !userprovider.Registered(config.Get().UsersModule) {
+		CatchAll(w, r)
+		return
+	}
 
+	// already authenticated with one of our own tokens
+	if id, ok := bearerIdentity(r); ok {
 		sendJSON(w, AuthV1Response{
-			Mechanism: "cert",
+			Mechanism: "token",
 			User: User{
-				OrgID:       reg.OrgID,
-				DisplayName: reg.OrgID,
+				OrgID:       id.Identity.OrgID,
+				Username:    id.Identity.User.Username,
+				DisplayName: id.Identity.User.Username,
 				ID:          -1,
 				IsActive:    true,
-				IsOrgAdmin:  true,
-				Type:        "system",
+				IsOrgAdmin:  id.Identity.User.OrgAdmin,
+				Type:        "user",
 			},
 		})
+		return
+	}
 
-	default:
-		CatchAll(w, r)
+	gatewayCN, err := getCertCN(r.Header.Get(CertHeader))
+	if err != nil {
+		do400(w, err.Error())
+		return
 	}
+
+	db := store.GetStore()
+
+	reg, err := db.FindByUID(gatewayCN)
+	if err != nil {
+		if errors.Is(err, store.ErrRegistrationNotFound) {
+			doError(w, err.Error(), 401)
+		} else {
+			do500(w, "failed to search for registration: "+err.Error())
+		}
+		return
+	}
+
+	if reg.Expired() {
+		doError(w, "registration expired", 401)
+		return
+	}
+
+	sendJSON(w, AuthV1Response{
+		Mechanism: "cert",
+		User: User{
+			OrgID:       reg.OrgID,
+			DisplayName: reg.OrgID,
+			ID:          -1,
+			IsActive:    true,
+			IsOrgAdmin:  true,
+			Type:        "system",
+		},
+	})
 }
@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"testing"
+	"time"
 
 	"github.com/redhatinsights/mbop/internal/config"
 	"github.com/redhatinsights/mbop/internal/logger"
@@ -23,6 +25,7 @@ func (suite *AuthV1TestSuite) SetupSuite() {
 	_ = logger.Init()
 	config.Reset()
 	os.Setenv("STORE_BACKEND", "memory")
+	os.Setenv("USERS_MODULE", "mock")
 }
 
 func (suite *AuthV1TestSuite) BeforeTest(_, _ string) {
@@ -38,6 +41,10 @@ func (suite *AuthV1TestSuite) AfterTest(_, _ string) {
 	suite.rec.Result().Body.Close()
 }
 
+func TestAuthV1Endpoint(t *testing.T) {
+	suite.Run(t, new(AuthV1TestSuite))
+}
+
 func (suite *AuthV1TestSuite) TestV1AuthNotFound() {
 	req := httptest.NewRequest(http.MethodGet, "http://foobar/v1/auth", nil)
 	req.Header.Set(CertHeader, "/CN=1234")
@@ -73,3 +80,29 @@ func (suite *AuthV1TestSuite) TestV1AuthSuccess() {
 	suite.Equal(true, resp.User.IsOrgAdmin)
 	suite.Equal("system", resp.User.Type)
 }
+
+func (suite *AuthV1TestSuite) TestV1AuthDeleted() {
+	_, err := suite.store.Create(&store.Registration{OrgID: "12345", UID: "1234"})
+	suite.Nil(err)
+	suite.Nil(suite.store.Delete("12345", "1234"))
+
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/v1/auth", nil)
+	req.Header.Set(CertHeader, "/CN=1234")
+	AuthV1Handler(suite.rec, req)
+
+	//nolint:bodyclose
+	suite.Equal(http.StatusUnauthorized, suite.rec.Result().StatusCode)
+}
+
+func (suite *AuthV1TestSuite) TestV1AuthExpired() {
+	expiredAt := time.Now().Add(-time.Minute)
+	_, err := suite.store.Create(&store.Registration{OrgID: "12345", UID: "1234", ExpiresAt: &expiredAt})
+	suite.Nil(err)
+
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/v1/auth", nil)
+	req.Header.Set(CertHeader, "/CN=1234")
+	AuthV1Handler(suite.rec, req)
+
+	//nolint:bodyclose
+	suite.Equal(http.StatusUnauthorized, suite.rec.Result().StatusCode)
+}
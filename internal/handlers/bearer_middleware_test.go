@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redhatinsights/mbop/internal/config"
+	"github.com/redhatinsights/mbop/internal/keyring"
+	"github.com/redhatinsights/mbop/internal/logger"
+	"github.com/redhatinsights/mbop/internal/models"
+	"github.com/redhatinsights/mbop/internal/store"
+	"github.com/redhatinsights/platform-go-middlewares/identity"
+	"github.com/stretchr/testify/suite"
+)
+
+type BearerAuthTestSuite struct {
+	suite.Suite
+	rec   *httptest.ResponseRecorder
+	store store.Store
+	ring  *keyring.Ring
+	key   *rsa.PrivateKey
+}
+
+func (suite *BearerAuthTestSuite) SetupSuite() {
+	_ = logger.Init()
+	config.Reset()
+	os.Setenv("STORE_BACKEND", "memory")
+	os.Setenv("USERS_MODULE", "mock")
+
+	var err error
+	suite.key, err = rsa.GenerateKey(rand.Reader, 2048)
+	suite.Nil(err)
+	suite.ring, err = keyring.New([]keyring.Key{
+		{ID: "active", Algorithm: "RS256", Private: suite.key, Public: &suite.key.PublicKey},
+	}, "active")
+	suite.Nil(err)
+}
+
+func (suite *BearerAuthTestSuite) BeforeTest(_, _ string) {
+	suite.rec = httptest.NewRecorder()
+	suite.Nil(store.SetupStore())
+
+	// creating a new store for every test and overriding the dep injection function
+	suite.store = store.GetStore()
+	store.GetStore = func() store.Store { return suite.store }
+	keyring.Get = func() (*keyring.Ring, error) { return suite.ring, nil }
+}
+
+func (suite *BearerAuthTestSuite) AfterTest(_, _ string) {
+	suite.rec.Result().Body.Close()
+}
+
+func TestBearerAuth(t *testing.T) {
+	suite.Run(t, new(BearerAuthTestSuite))
+}
+
+func (suite *BearerAuthTestSuite) TestV1AuthWithToken() {
+	key, _ := suite.ring.Active()
+	token, err := models.Token{Key: key, Issuer: "mbop"}.Create(time.Minute, identity.Identity{
+		OrgID: "1234",
+		User:  identity.User{Username: "foobar", OrgAdmin: true},
+	}, "", nil)
+	suite.Nil(err)
+
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/v1/auth", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	BearerAuth(http.HandlerFunc(AuthV1Handler)).ServeHTTP(suite.rec, req)
+
+	status, body := suite.statusAndBody()
+	suite.Equal(http.StatusOK, status)
+
+	var resp AuthV1Response
+	suite.Nil(json.Unmarshal([]byte(body), &resp))
+	suite.Equal("token", resp.Mechanism)
+	suite.Equal("1234", resp.User.OrgID)
+	suite.Equal("foobar", resp.User.Username)
+	suite.True(resp.User.IsOrgAdmin)
+}
+
+func (suite *BearerAuthTestSuite) TestIdentityPopulated() {
+	token := suite.signedToken(time.Now(), time.Now().Add(time.Minute))
+
+	var id identity.XRHID
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id = identity.Get(r.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/", nil)
+	req.Header.Set("Authorization", "bearer "+token)
+	BearerAuth(handler).ServeHTTP(suite.rec, req)
+
+	suite.Equal("1234", id.Identity.OrgID)
+	suite.Equal("foobar", id.Identity.User.Username)
+	suite.Equal("jwt-auth", id.Identity.AuthType)
+}
+
+func (suite *BearerAuthTestSuite) TestExpiredToken() {
+	token := suite.signedToken(time.Now().Add(-time.Hour), time.Now().Add(-time.Minute))
+
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/v1/auth", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	BearerAuth(http.HandlerFunc(AuthV1Handler)).ServeHTTP(suite.rec, req)
+
+	status, _ := suite.statusAndBody()
+	suite.Equal(http.StatusUnauthorized, status)
+}
+
+func (suite *BearerAuthTestSuite) TestNotYetValidToken() {
+	token := suite.signedToken(time.Now().Add(time.Hour), time.Now().Add(2*time.Hour))
+
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/v1/auth", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	BearerAuth(http.HandlerFunc(AuthV1Handler)).ServeHTTP(suite.rec, req)
+
+	status, _ := suite.statusAndBody()
+	suite.Equal(http.StatusUnauthorized, status)
+}
+
+func (suite *BearerAuthTestSuite) TestTokenWithoutExpiry() {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, models.TokenClaims{
+		OrgID:            "1234",
+		RegisteredClaims: jwt.RegisteredClaims{Issuer: "mbop"},
+	})
+	token.Header["kid"] = "active"
+	signed, err := token.SignedString(suite.key)
+	suite.Nil(err)
+
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/v1/auth", nil)
+	req.Header.Set("Authorization", "Bearer "+signed)
+	BearerAuth(http.HandlerFunc(AuthV1Handler)).ServeHTTP(suite.rec, req)
+
+	status, body := suite.statusAndBody()
+	suite.Equal(http.StatusUnauthorized, status)
+	suite.Equal(`{"message":"invalid token: token has no expiry"}`, body)
+}
+
+func (suite *BearerAuthTestSuite) TestRevokedToken() {
+	token := suite.signedToken(time.Now(), time.Now().Add(time.Minute))
+	suite.Nil(suite.store.RevokeToken(&store.TokenRevocation{JTI: "abc", OrgID: "1234", ExpiresAt: time.Now().Add(time.Minute)}))
+
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/v1/auth", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	BearerAuth(http.HandlerFunc(AuthV1Handler)).ServeHTTP(suite.rec, req)
+
+	status, body := suite.statusAndBody()
+	suite.Equal(http.StatusUnauthorized, status)
+	suite.Equal(`{"message":"invalid token: token has been revoked"}`, body)
+}
+
+func (suite *BearerAuthTestSuite) TestUnknownKeyPassedThrough() {
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	suite.Nil(err)
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, models.TokenClaims{})
+	token.Header["kid"] = "someone-else"
+	signed, err := token.SignedString(other)
+	suite.Nil(err)
+
+	suite.True(suite.passedThrough("Bearer " + signed))
+}
+
+func (suite *BearerAuthTestSuite) TestNotAJWTPassedThrough() {
+	suite.True(suite.passedThrough("Bearer some-opaque-token"))
+}
+
+func (suite *BearerAuthTestSuite) TestWrongIssuer() {
+	key, _ := suite.ring.Active()
+	token, err := models.Token{Key: key, Issuer: "someone-else"}.Create(time.Minute, identity.Identity{
+		OrgID: "1234",
+		User:  identity.User{Username: "foobar"},
+	}, "", nil)
+	suite.Nil(err)
+
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/v1/auth", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	BearerAuth(http.HandlerFunc(AuthV1Handler)).ServeHTTP(suite.rec, req)
+
+	status, _ := suite.statusAndBody()
+	suite.Equal(http.StatusUnauthorized, status)
+}
+
+func (suite *BearerAuthTestSuite) TestOtherAudience() {
+	key, _ := suite.ring.Active()
+	token, err := models.Token{Key: key, Issuer: "mbop"}.Create(time.Minute, identity.Identity{
+		OrgID: "1234",
+		User:  identity.User{Username: "foobar"},
+	}, "some-other-service", nil)
+	suite.Nil(err)
+
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/v1/auth", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	BearerAuth(http.HandlerFunc(AuthV1Handler)).ServeHTTP(suite.rec, req)
+
+	status, body := suite.statusAndBody()
+	suite.Equal(http.StatusUnauthorized, status)
+	suite.Equal(`{"message":"invalid token: token is not meant for mbop"}`, body)
+}
+
+func (suite *BearerAuthTestSuite) TestOwnAudience() {
+	key, _ := suite.ring.Active()
+	token, err := models.Token{Key: key, Issuer: "mbop"}.Create(time.Minute, identity.Identity{
+		OrgID: "1234",
+		User:  identity.User{Username: "foobar"},
+	}, "mbop", nil)
+	suite.Nil(err)
+
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/v1/auth", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	BearerAuth(http.HandlerFunc(AuthV1Handler)).ServeHTTP(suite.rec, req)
+
+	status, _ := suite.statusAndBody()
+	suite.Equal(http.StatusOK, status)
+}
+
+func (suite *BearerAuthTestSuite) TestNoTokenFallsBackToCert() {
+	_, err := suite.store.Create(&store.Registration{OrgID: "12345", UID: "1234"})
+	suite.Nil(err)
+
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/v1/auth", nil)
+	req.Header.Set(CertHeader, "/CN=1234")
+	BearerAuth(http.HandlerFunc(AuthV1Handler)).ServeHTTP(suite.rec, req)
+
+	status, body := suite.statusAndBody()
+	suite.Equal(http.StatusOK, status)
+
+	var resp AuthV1Response
+	suite.Nil(json.Unmarshal([]byte(body), &resp))
+	suite.Equal("cert", resp.Mechanism)
+}
+
+// a token with the jti "abc" valid between the two times
+func (suite *BearerAuthTestSuite) signedToken(nbf, exp time.Time) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, models.TokenClaims{
+		OrgID:    "1234",
+		Username: "foobar",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "abc",
+			Issuer:    "mbop",
+			NotBefore: jwt.NewNumericDate(nbf),
+			ExpiresAt: jwt.NewNumericDate(exp),
+		},
+	})
+	token.Header["kid"] = "active"
+
+	signed, err := token.SignedString(suite.key)
+	suite.Nil(err)
+	return signed
+}
+
+// whether a request with the Authorization header reached the next handler
+// without BearerAuth touching it
+func (suite *BearerAuthTestSuite) passedThrough(authorization string) bool {
+	reached := false
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := r.Context().Value(identity.Key).(identity.XRHID)
+		reached = !ok && r.Header.Get("Authorization") == authorization
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/v1/auth", nil)
+	req.Header.Set("Authorization", authorization)
+	BearerAuth(handler).ServeHTTP(suite.rec, req)
+	return reached
+}
+
+func (suite *BearerAuthTestSuite) statusAndBody() (int, string) {
+	//nolint:bodyclose
+	rsp := suite.rec.Result()
+	b, err := io.ReadAll(rsp.Body)
+	suite.Nil(err)
+	return rsp.StatusCode, string(b)
+}
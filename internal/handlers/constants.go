@@ -5,10 +5,12 @@ Constants used throughout the handlers package, will probably mostly be module d
 */
 
 const awsModule = "aws"
-const amsModule = "ams"
-const mockModule = "mock"
 const printModule = "print"
 const keycloakModule = "keycloak"
 
+// how many users matched across every page, the v3 user lists being a bare
+// array with nowhere else to put it
+const totalCountHeader = "X-Total-Count"
+
 const defaultLimit = 100
 const defaultOffset = 0
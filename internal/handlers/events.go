@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	l "github.com/redhatinsights/mbop/internal/logger"
+	"github.com/redhatinsights/mbop/internal/service/events"
+	"github.com/redhatinsights/platform-go-middlewares/identity"
+)
+
+// publishEvent sends an event for a change made by the identity on the
+// request. Delivery (and any retries) happens in the background so it never
+// holds up or fails the request.
+func publishEvent(r *http.Request, e events.Event) {
+	id := identity.Get(r.Context())
+	e.OrgID = id.Identity.OrgID
+	e.Actor = id.Identity.User.Username
+	e.Timestamp = time.Now()
+
+	pub, err := events.NewPublisher()
+	if err != nil {
+		l.Log.Error(err, "error getting event publisher")
+		return
+	}
+
+	go func() {
+		if err := pub.Publish(context.Background(), &e); err != nil {
+			l.Log.Error(err, "failed to publish event", "type", e.Type, "org_id", e.OrgID)
+		}
+	}()
+}
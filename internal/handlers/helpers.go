@@ -1,16 +1,22 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/redhatinsights/mbop/internal/config"
 	l "github.com/redhatinsights/mbop/internal/logger"
 	"github.com/redhatinsights/mbop/internal/models"
+	"github.com/redhatinsights/mbop/internal/service/userprovider"
+	"github.com/redhatinsights/mbop/internal/store"
 )
 
 var (
@@ -61,6 +67,73 @@ func stringInSlice(a string, list []string) bool {
 	return false
 }
 
+// splits a comma separated config list, dropping any empty entries
+func splitList(s string) []string {
+	out := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// negotiate picks which of the offered media types to respond with going by an
+// Accept header, preferring the earlier offer when more than one is weighted
+// the same. No header at all gets the first offer, and false means nothing
+// offered is acceptable.
+func negotiate(accept string, offers []string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0], true
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		// the q of the most specific range matching the offer
+		q, specificity := 0.0, -1
+		for _, mediaRange := range strings.Split(accept, ",") {
+			params := strings.Split(mediaRange, ";")
+			rangeType := strings.ToLower(strings.TrimSpace(params[0]))
+
+			s := mediaRangeMatch(rangeType, offer)
+			if s <= specificity {
+				continue
+			}
+
+			specificity, q = s, 1.0
+			for _, p := range params[1:] {
+				name, value, _ := strings.Cut(strings.TrimSpace(p), "=")
+				if strings.EqualFold(name, "q") {
+					if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+						q = parsed
+					}
+				}
+			}
+		}
+
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best, best != ""
+}
+
+// how specifically a media range matches a media type, -1 being not at all
+func mediaRangeMatch(mediaRange, mediaType string) int {
+	switch {
+	case mediaRange == mediaType:
+		return 2
+	case mediaRange == "*/*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*"):
+		if strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*")) {
+			return 1
+		}
+	}
+	return -1
+}
+
 func initV1UserQuery(r *http.Request) (models.UserV1Query, error) {
 	q := models.UserV1Query{}
 
@@ -111,6 +184,89 @@ func initAccountV3UserQuery(r *http.Request) (models.UserV3Query, error) {
 	return q, nil
 }
 
+// "des" is what the rest of the api takes (sortOrder), so it's accepted here
+// too and means the same as "desc"
+var validRegistrationSortOrder = []string{"asc", "desc", "des"}
+
+func initRegistrationFilter(r *http.Request) (store.RegistrationFilter, error) {
+	q := r.URL.Query()
+	f := store.RegistrationFilter{
+		DisplayName: q.Get("display_name"),
+		Username:    q.Get("username"),
+		Extra:       getExtraFilter(r),
+		SortBy:      q.Get("sort_by"),
+		SortOrder:   q.Get("sort_order"),
+	}
+
+	if f.SortBy != "" && !stringInSlice(f.SortBy, store.RegistrationSortFields) {
+		return f, fmt.Errorf("sort_by must be one of %s", strings.Join(store.RegistrationSortFields, ", "))
+	}
+
+	if f.SortOrder != "" && !stringInSlice(f.SortOrder, validRegistrationSortOrder) {
+		return f, fmt.Errorf("sort_order must be one of %s", strings.Join(validRegistrationSortOrder, ", "))
+	}
+	if f.SortOrder == "des" {
+		f.SortOrder = "desc"
+	}
+
+	var err error
+	if q.Get("created_after") != "" {
+		f.CreatedAfter, err = time.Parse(time.RFC3339, q.Get("created_after"))
+		if err != nil {
+			return f, errors.New("created_after must be an RFC3339 timestamp")
+		}
+	}
+
+	if q.Get("created_before") != "" {
+		f.CreatedBefore, err = time.Parse(time.RFC3339, q.Get("created_before"))
+		if err != nil {
+			return f, errors.New("created_before must be an RFC3339 timestamp")
+		}
+	}
+
+	return f, nil
+}
+
+// userProvider is the provider for the configured USERS_MODULE. Without one
+// the request is passed through to the mbop server instance injected
+// somewhere, false meaning the response has already been written.
+func userProvider(w http.ResponseWriter, r *http.Request) (userprovider.UserProvider, bool) {
+	provider, err := userprovider.NewUserProvider()
+	if err != nil {
+		if errors.Is(err, userprovider.ErrUnsupportedModule) {
+			CatchAll(w, r)
+		} else {
+			do500(w, "Can't build users provider: "+err.Error())
+		}
+		return nil, false
+	}
+
+	return provider, true
+}
+
+// marks which of the users are org admins
+func setOrgAdmins(ctx context.Context, provider userprovider.UserProvider, users []models.User) error {
+	admins, err := provider.IsOrgAdmin(ctx, users)
+	if err != nil {
+		return err
+	}
+
+	for i := range users {
+		users[i].IsOrgAdmin = admins[users[i].ID].IsOrgAdmin
+	}
+	return nil
+}
+
+// the v3 user lists keep the response each module has always sent, the
+// trimmed down UserV3Response for AMS (and the mock) and the whole user for
+// keycloak
+func v3UsersResponse(users []models.User) any {
+	if config.Get().UsersModule == keycloakModule {
+		return users
+	}
+	return usersToV3Response(users).Responses
+}
+
 func usersToV3Response(users []models.User) models.UserV3Responses {
 	r := models.UserV3Responses{Responses: []models.UserV3Response{}}
 
@@ -220,10 +376,29 @@ func getLimit(r *http.Request) (int, error) {
 	if err != nil {
 		return defaultLimit, fmt.Errorf("limit must be of type int")
 	}
+	if limit < 0 {
+		return defaultLimit, fmt.Errorf("limit must not be negative")
+	}
 
 	return limit, nil
 }
 
+// pulls out any `extra[key]=value` query params into a map of key -> value
+func getExtraFilter(r *http.Request) map[string]string {
+	extra := make(map[string]string)
+
+	for k, v := range r.URL.Query() {
+		if strings.HasPrefix(k, "extra[") && strings.HasSuffix(k, "]") && len(v) > 0 {
+			key := strings.TrimSuffix(strings.TrimPrefix(k, "extra["), "]")
+			if key != "" {
+				extra[key] = v[0]
+			}
+		}
+	}
+
+	return extra
+}
+
 func getOffset(r *http.Request) (int, error) {
 	if r.URL.Query().Get("offset") == "" {
 		return defaultOffset, nil
@@ -233,6 +408,9 @@ func getOffset(r *http.Request) (int, error) {
 	if err != nil {
 		return defaultLimit, fmt.Errorf("offset must be of type int")
 	}
+	if offset < 0 {
+		return defaultOffset, fmt.Errorf("offset must not be negative")
+	}
 
 	return offset, nil
 }
@@ -1,13 +1,17 @@
 package handlers
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
 	"testing"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/redhatinsights/mbop/internal/config"
+	"github.com/redhatinsights/mbop/internal/models"
 )
 
 // defaultUsersModule holds the default users module set by the configuration.
@@ -47,3 +51,164 @@ func TestSendJSONWithStatusCodeContentTypeHeader(t *testing.T) {
 		t.Errorf(`unexpected "Content-Type" header received. Want "%s", got "%s"`, "application/json", response.Header.Get("Content-Type"))
 	}
 }
+
+// TestNegotiate tests picking a response media type from the "Accept" header.
+func TestNegotiate(t *testing.T) {
+	offers := []string{"application/json", "application/jwk+json", "text/plain"}
+
+	tests := []struct {
+		accept string
+		want   string
+		ok     bool
+	}{
+		{accept: "", want: "application/json", ok: true},
+		{accept: "*/*", want: "application/json", ok: true},
+		{accept: "application/jwk+json", want: "application/jwk+json", ok: true},
+		{accept: "text/*", want: "text/plain", ok: true},
+		{accept: "APPLICATION/JWK+JSON", want: "application/jwk+json", ok: true},
+		{accept: "application/json;q=0.5, application/jwk+json", want: "application/jwk+json", ok: true},
+		{accept: "application/jwk+json;q=0.5, */*;q=0.1", want: "application/jwk+json", ok: true},
+		{accept: "*/*, application/json;q=0", want: "application/jwk+json", ok: true},
+		{accept: "application/xml", want: "", ok: false},
+	}
+
+	for _, test := range tests {
+		got, ok := negotiate(test.accept, offers)
+		if got != test.want || ok != test.ok {
+			t.Errorf(`negotiate(%q) = "%s", %v, want "%s", %v`, test.accept, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+// TestRegistrationFilterSortOrder tests that both spellings of descending are
+// accepted for sort_order.
+func TestRegistrationFilterSortOrder(t *testing.T) {
+	tests := []struct {
+		sortOrder string
+		want      string
+		ok        bool
+	}{
+		{sortOrder: "", want: "", ok: true},
+		{sortOrder: "asc", want: "asc", ok: true},
+		{sortOrder: "desc", want: "desc", ok: true},
+		{sortOrder: "des", want: "desc", ok: true},
+		{sortOrder: "up", want: "", ok: false},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://foobar/registrations?sort_order="+test.sortOrder, nil)
+		f, err := initRegistrationFilter(req)
+		if (err == nil) != test.ok {
+			t.Errorf(`initRegistrationFilter(sort_order=%q) error = %v, want ok %v`, test.sortOrder, err, test.ok)
+			continue
+		}
+		if test.ok && f.SortOrder != test.want {
+			t.Errorf(`initRegistrationFilter(sort_order=%q) sort order = "%s", want "%s"`, test.sortOrder, f.SortOrder, test.want)
+		}
+	}
+}
+
+// TestAccountsV3UsersTotalCount tests that the total number of users is sent in the "X-Total-Count" header.
+func TestAccountsV3UsersTotalCount(t *testing.T) {
+	defer cleanup()
+
+	config.Get().UsersModule = "mock"
+
+	testRouter := chi.NewRouter()
+	testRouter.Get("/v3/accounts/{orgID}/users", AccountsV3UsersHandler)
+
+	testServer := httptest.NewServer(testRouter)
+	defer testServer.Close()
+
+	fullURL := fmt.Sprintf("%s/v3/accounts/12345/users?limit=5&admin_only=true", testServer.URL)
+	response, err := http.Get(fullURL) // nolint because the test server's URL is dynamic.
+	if err != nil {
+		t.Fatalf(`unable to send request to the "AccountsV3UsersHandler" endpoint: %s`, err)
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != 200 {
+		t.Errorf(`unexpected status code received. Want "%d", got "%d"`, 200, response.StatusCode)
+	}
+
+	if response.Header.Get("X-Total-Count") != "5" {
+		t.Errorf(`unexpected "X-Total-Count" header received. Want "%s", got "%s"`, "5", response.Header.Get("X-Total-Count"))
+	}
+}
+
+// TestAccountsV3UsersKeycloakResponse tests that in keycloak mode the whole user is sent back, as it always was, rather
+// than the trimmed down response AMS sends.
+func TestAccountsV3UsersKeycloakResponse(t *testing.T) {
+	defer cleanup()
+
+	// standing in for both keycloak's token endpoint and the keycloak user service
+	keycloakServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/token" {
+			_, _ = w.Write([]byte(`{"access_token": "token", "expires_in": 300}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"meta": {"total": 1}, "users": [{
+			"id": "1", "username": "foobar", "email": "foobar@redhat.com", "first_name": "foo", "last_name": "bar",
+			"is_active": true, "is_org_admin": true, "org_id": "12345", "user_id": "foobar-id", "type": "User"
+		}]}`))
+	}))
+	defer keycloakServer.Close()
+
+	serverURL, err := url.Parse(keycloakServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := config.Get()
+	defaults := *c
+	defer func() { *c = defaults }()
+
+	c.UsersModule = "keycloak"
+	c.KeyCloakTokenURL = keycloakServer.URL + "/"
+	c.KeyCloakTokenPath = "token"
+	c.KeyCloakUserServiceScheme = "http"
+	c.KeyCloakUserServiceHost = serverURL.Hostname()
+	c.KeyCloakUserServicePort = ":" + serverURL.Port()
+
+	testRouter := chi.NewRouter()
+	testRouter.Get("/v3/accounts/{orgID}/users", AccountsV3UsersHandler)
+
+	testServer := httptest.NewServer(testRouter)
+	defer testServer.Close()
+
+	fullURL := fmt.Sprintf("%s/v3/accounts/12345/users", testServer.URL)
+	response, err := http.Get(fullURL) // nolint because the test server's URL is dynamic.
+	if err != nil {
+		t.Fatalf(`unable to send request to the "AccountsV3UsersHandler" endpoint: %s`, err)
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != 200 {
+		t.Fatalf(`unexpected status code received. Want "%d", got "%d"`, 200, response.StatusCode)
+	}
+
+	var users []models.User
+	if err := json.NewDecoder(response.Body).Decode(&users); err != nil {
+		t.Fatalf(`unable to decode the response: %s`, err)
+	}
+
+	want := []models.User{{
+		Username:    "foobar",
+		ID:          "1",
+		Email:       "foobar@redhat.com",
+		FirstName:   "foo",
+		LastName:    "bar",
+		IsActive:    true,
+		IsOrgAdmin:  true,
+		Locale:      "en_US",
+		OrgID:       "12345",
+		DisplayName: "foobar-id",
+		Type:        "User",
+	}}
+	if !reflect.DeepEqual(users, want) {
+		t.Errorf(`unexpected users received. Want "%+v", got "%+v"`, want, users)
+	}
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/redhatinsights/mbop/internal/keyring"
+)
+
+// how long consumers can cache the key set for, short enough that a newly
+// added key gets picked up well before it starts signing
+const jwksMaxAge = "max-age=300"
+
+// JWKSHandler publishes the public half of every token key, so tokens can
+// still be verified while keys are being rotated.
+func JWKSHandler(w http.ResponseWriter, _ *http.Request) {
+	ring, err := keyring.Get()
+	if err != nil {
+		do500(w, "error loading token keys: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, "+jwksMaxAge)
+	sendJSON(w, ring.JWKS())
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redhatinsights/mbop/internal/keyring"
+	"github.com/redhatinsights/platform-go-middlewares/identity"
+	"github.com/stretchr/testify/suite"
+)
+
+type JWKSTestSuite struct {
+	suite.Suite
+	rec  *httptest.ResponseRecorder
+	ring *keyring.Ring
+}
+
+func (suite *JWKSTestSuite) SetupSuite() {
+	active, err := rsa.GenerateKey(rand.Reader, 2048)
+	suite.Nil(err)
+	old, err := rsa.GenerateKey(rand.Reader, 2048)
+	suite.Nil(err)
+
+	suite.ring, err = keyring.New([]keyring.Key{
+		{ID: "active", Algorithm: "RS256", Private: active, Public: &active.PublicKey},
+		{ID: "old", Algorithm: "RS256", Public: &old.PublicKey},
+	}, "active")
+	suite.Nil(err)
+}
+
+func (suite *JWKSTestSuite) BeforeTest(_, _ string) {
+	suite.rec = httptest.NewRecorder()
+	keyring.Get = func() (*keyring.Ring, error) { return suite.ring, nil }
+}
+
+func (suite *JWKSTestSuite) AfterTest(_, _ string) {
+	suite.rec.Result().Body.Close()
+}
+
+func TestJWKSEndpoint(t *testing.T) {
+	suite.Run(t, new(JWKSTestSuite))
+}
+
+func (suite *JWKSTestSuite) TestJWKS() {
+	JWKSHandler(suite.rec, httptest.NewRequest(http.MethodGet, "http://foobar/.well-known/jwks.json", nil))
+
+	//nolint:bodyclose
+	rsp := suite.rec.Result()
+	suite.Equal(http.StatusOK, rsp.StatusCode)
+	suite.Equal("public, max-age=300", rsp.Header.Get("Cache-Control"))
+
+	b, err := io.ReadAll(rsp.Body)
+	suite.Nil(err)
+
+	var jwks keyring.JWKS
+	suite.Nil(json.Unmarshal(b, &jwks))
+	suite.Len(jwks.Keys, 2)
+	suite.Equal("active", jwks.Keys[0].Kid)
+	suite.Equal("old", jwks.Keys[1].Kid)
+	for _, k := range jwks.Keys {
+		suite.Equal("RSA", k.Kty)
+		suite.Equal("RS256", k.Alg)
+		suite.Equal("sig", k.Use)
+		suite.Equal("AQAB", k.E)
+	}
+}
+
+func (suite *JWKSTestSuite) TestTokenSignedWithActiveKey() {
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/v1/registrations/token", nil).
+		WithContext(context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+			User:  identity.User{OrgAdmin: true, Username: "foobar"},
+			OrgID: "1234",
+		}}))
+	TokenHandler(suite.rec, req)
+
+	//nolint:bodyclose
+	rsp := suite.rec.Result()
+	suite.Equal(http.StatusOK, rsp.StatusCode)
+
+	var body TokenResp
+	suite.Nil(json.NewDecoder(rsp.Body).Decode(&body))
+
+	// verifying it the way a consumer of the jwks would
+	token, err := jwt.Parse(body.Token, func(t *jwt.Token) (interface{}, error) {
+		key, ok := suite.ring.Key(t.Header["kid"].(string))
+		suite.True(ok)
+		return key.Public, nil
+	})
+	suite.Nil(err)
+	suite.Equal("active", token.Header["kid"])
+	suite.Equal("1234", token.Claims.(jwt.MapClaims)["org_id"])
+}
@@ -2,61 +2,163 @@ package handlers
 
 import (
 	"encoding/json"
-	"io"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/redhatinsights/mbop/internal/config"
+	"github.com/redhatinsights/mbop/internal/service/jwks"
 
 	"github.com/RedHatInsights/jwk2pem"
 	l "github.com/redhatinsights/mbop/internal/logger"
 )
 
+// the formats /v1/jwt can respond with, picked through the Accept header
+const (
+	jwtMediaTypeJSON   = "application/json"
+	jwtMediaTypeJWK    = "application/jwk+json"
+	jwtMediaTypeJWKSet = "application/jwk-set+json"
+	jwtMediaTypePEM    = "application/x-pem-file"
+)
+
+// in order of preference, plain json first to keep the original response for
+// callers that don't ask for anything
+var jwtMediaTypes = []string{jwtMediaTypeJSON, jwtMediaTypeJWK, jwtMediaTypeJWKSet, jwtMediaTypePEM}
+
 type JWTResp struct {
 	Pubkey string `json:"pubkey"`
 }
 
+type jwkSet struct {
+	Keys []jwk2pem.JWKey `json:"keys"`
+}
+
+type JWTKeysResp struct {
+	Keys []JWTKey `json:"keys"`
+}
+
+type JWTKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+/*
+JWTV1Handler returns the public key for a kid, as either:
+  - application/json; the original `{"pubkey": "<pem>"}` response
+  - application/jwk+json; the JWK itself
+  - application/x-pem-file; the bare PEM
+  - application/jwk-set+json; every key, no kid needed, so gateways can fetch
+    them all in one go
+*/
 func JWTV1Handler(w http.ResponseWriter, r *http.Request) {
 	switch config.Get().JwtModule {
 	case awsModule, keycloakModule:
-		kid := r.URL.Query().Get("kid")
-		if kid == "" {
-			do400(w, "kid required to return correct pub key")
+		mediaType, ok := negotiate(r.Header.Get("Accept"), jwtMediaTypes)
+		if !ok {
+			doError(w, "unsupported Accept header, must be one of: "+strings.Join(jwtMediaTypes, ", "), http.StatusNotAcceptable)
 			return
 		}
 
-		resp, err := http.Get(config.Get().JwkURL)
+		cache, err := jwks.FromConfig()
 		if err != nil {
-			l.Log.Error(err, "error getting JWKs")
 			do500(w, "error getting JWKs: "+err.Error())
 			return
 		}
 
-		defer resp.Body.Close()
+		if mediaType == jwtMediaTypeJWKSet {
+			keys, err := cache.Keys(r.Context())
+			if err != nil {
+				l.Log.Error(err, "error getting JWKs")
+				do500(w, err.Error())
+				return
+			}
 
-		bdata, err := io.ReadAll(resp.Body)
-		if err != nil {
-			l.Log.Error(err, "error reading JWKs")
-			do500(w, "error reading JWKs: "+err.Error())
+			// jwk2pem.JWKeys' tag is malformed, so it'd marshal as "Keys"
+			sendJSONAs(w, mediaType, jwkSet{Keys: keys.Keys})
+			return
+		}
+
+		kid := r.URL.Query().Get("kid")
+		if kid == "" {
+			do400(w, "kid required to return correct pub key")
 			return
 		}
 
-		keys := jwk2pem.JWKeys{}
-		err = json.Unmarshal([]byte(bdata), &keys)
+		key, err := cache.Key(r.Context(), kid)
 		if err != nil {
-			do400(w, "failed to parse response: "+err.Error())
+			if errors.Is(err, jwks.ErrKeyNotFound) {
+				do404(w, "no JWK for kid: "+kid)
+				return
+			}
+
+			l.Log.Error(err, "error getting JWKs")
+			do500(w, err.Error())
 			return
 		}
 
-		pem := jwk2pem.JWKsToPem(keys, kid)
+		if mediaType == jwtMediaTypeJWK {
+			sendJSONAs(w, mediaType, key)
+			return
+		}
 
+		pem := jwk2pem.JWKToPem(*key)
 		if pem == nil {
 			do404(w, "no JWK for kid: "+kid)
 			return
 		}
 
+		if mediaType == jwtMediaTypePEM {
+			w.Header().Set("Content-Type", mediaType)
+			_, err = w.Write(pem)
+			if err != nil {
+				l.Log.Error(err, "error writing response")
+			}
+			return
+		}
+
 		sendJSON(w, JWTResp{Pubkey: strings.TrimSuffix(string(pem), "\n")})
 	default:
 		CatchAll(w, r)
 	}
 }
+
+// JWTKeysV1Handler lists every kid the JWK endpoint currently has
+func JWTKeysV1Handler(w http.ResponseWriter, r *http.Request) {
+	switch config.Get().JwtModule {
+	case awsModule, keycloakModule:
+		cache, err := jwks.FromConfig()
+		if err != nil {
+			do500(w, "error getting JWKs: "+err.Error())
+			return
+		}
+
+		keys, err := cache.Keys(r.Context())
+		if err != nil {
+			l.Log.Error(err, "error getting JWKs")
+			do500(w, err.Error())
+			return
+		}
+
+		resp := JWTKeysResp{Keys: make([]JWTKey, len(keys.Keys))}
+		for i, k := range keys.Keys {
+			resp.Keys[i] = JWTKey{Kid: k.Kid, Kty: k.Kty, Alg: k.Alg, Use: k.Use}
+		}
+
+		sendJSON(w, resp)
+	default:
+		CatchAll(w, r)
+	}
+}
+
+// like sendJSON, for the json based media types that aren't application/json
+func sendJSONAs(w http.ResponseWriter, mediaType string, data any) {
+	b, _ := json.Marshal(data)
+
+	w.Header().Set("Content-Type", mediaType)
+	_, err := w.Write(b)
+	if err != nil {
+		l.Log.Error(err, "error writing response")
+	}
+}
@@ -120,6 +120,120 @@ func (suite *TestSuite) TestAwsJWTGetKidMatch() {
 	defer resp.Body.Close()
 }
 
+func (suite *TestSuite) TestAwsJWTGetCached() {
+	calls := 0
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(suite.testData)
+	}))
+	defer mockServer.Close()
+	config.Reset()
+
+	os.Setenv("JWT_MODULE", "aws")
+	os.Setenv("JWK_URL", fmt.Sprintf("%s/v1/jwt", mockServer.URL))
+	kid := "b4OUzJFABPSRwxX5VN7lYswVj9qoc3tet0tsfG5MSME"
+
+	// dummy muxer for the test
+	mux := http.NewServeMux()
+	mux.Handle("/", http.HandlerFunc(JWTV1Handler))
+
+	sut := httptest.NewServer(mux)
+	defer sut.Close()
+
+	for i := 0; i < 3; i++ {
+		resp, err := http.Get(fmt.Sprintf("%s/v1/jwt?kid=%s", sut.URL, kid))
+		assert.Nil(suite.T(), err, "error was not nil")
+		assert.Equal(suite.T(), 200, resp.StatusCode, "status code not good")
+		resp.Body.Close()
+	}
+
+	assert.Equal(suite.T(), 1, calls, "JWKs fetched more than once")
+}
+
+// requests /v1/jwt against a fresh JWK endpoint serving the test data
+func (suite *TestSuite) getJWT(path, accept string) (*http.Response, string) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(suite.testData)
+	}))
+	suite.T().Cleanup(mockServer.Close)
+	config.Reset()
+
+	os.Setenv("JWT_MODULE", "aws")
+	os.Setenv("JWK_URL", fmt.Sprintf("%s/v1/jwt", mockServer.URL))
+
+	// dummy muxer for the test
+	mux := http.NewServeMux()
+	mux.Handle("/v1/jwt", http.HandlerFunc(JWTV1Handler))
+	mux.Handle("/v1/jwt/keys", http.HandlerFunc(JWTKeysV1Handler))
+
+	sut := httptest.NewServer(mux)
+	defer sut.Close()
+
+	req, err := http.NewRequest(http.MethodGet, sut.URL+path, nil)
+	assert.Nil(suite.T(), err, "error was not nil")
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(suite.T(), err, "error was not nil")
+	defer resp.Body.Close()
+
+	b, _ := io.ReadAll(resp.Body)
+	return resp, string(b)
+}
+
+func (suite *TestSuite) TestAwsJWTGetJWK() {
+	kid := "b4OUzJFABPSRwxX5VN7lYswVj9qoc3tet0tsfG5MSME"
+	resp, body := suite.getJWT("/v1/jwt?kid="+kid, "application/jwk+json")
+
+	assert.Equal(suite.T(), 200, resp.StatusCode, "status code not good")
+	assert.Equal(suite.T(), "application/jwk+json", resp.Header.Get("Content-Type"))
+
+	key := jwk2pem.JWKey{}
+	assert.Nil(suite.T(), json.Unmarshal([]byte(body), &key), "error was not nil")
+	assert.Equal(suite.T(), suite.testDataStruct.Keys[0], key)
+}
+
+func (suite *TestSuite) TestAwsJWTGetPEM() {
+	kid := "b4OUzJFABPSRwxX5VN7lYswVj9qoc3tet0tsfG5MSME"
+	resp, body := suite.getJWT("/v1/jwt?kid="+kid, "application/x-pem-file")
+
+	expected := JWTResp{}
+	assert.Nil(suite.T(), json.Unmarshal(suite.testPem, &expected), "error was not nil")
+
+	assert.Equal(suite.T(), 200, resp.StatusCode, "status code not good")
+	assert.Equal(suite.T(), "application/x-pem-file", resp.Header.Get("Content-Type"))
+	assert.Equal(suite.T(), expected.Pubkey+"\n", body)
+}
+
+func (suite *TestSuite) TestAwsJWTGetJWKSet() {
+	resp, body := suite.getJWT("/v1/jwt", "application/jwk-set+json")
+
+	assert.Equal(suite.T(), 200, resp.StatusCode, "status code not good")
+	assert.Equal(suite.T(), "application/jwk-set+json", resp.Header.Get("Content-Type"))
+
+	keys := jwkSet{}
+	assert.Nil(suite.T(), json.Unmarshal([]byte(body), &keys), "error was not nil")
+	assert.Equal(suite.T(), suite.testDataStruct.Keys, keys.Keys)
+}
+
+func (suite *TestSuite) TestAwsJWTGetNotAcceptable() {
+	resp, body := suite.getJWT("/v1/jwt?kid=123", "application/xml")
+
+	assert.Equal(suite.T(), 406, resp.StatusCode, "status code not good")
+	assert.Equal(suite.T(), `{"message":"unsupported Accept header, must be one of: application/json, application/jwk+json, application/jwk-set+json, application/x-pem-file"}`, body)
+}
+
+func (suite *TestSuite) TestAwsJWTKeys() {
+	resp, body := suite.getJWT("/v1/jwt/keys", "")
+
+	assert.Equal(suite.T(), 200, resp.StatusCode, "status code not good")
+	assert.Equal(suite.T(), `{"keys":[{"kid":"b4OUzJFABPSRwxX5VN7lYswVj9qoc3tet0tsfG5MSME","kty":"RSA","alg":"RS256","use":"sig"},{"kid":"1CJSmsQLwGgzOb5JrTVtPJJHVaXFj-VACjUpBGuAh3I","kty":"RSA","alg":"RS256","use":"sig"}]}`, body)
+}
+
 func (suite *TestSuite) TearDownSuite() {
 }
 
@@ -0,0 +1,428 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/redhatinsights/mbop/internal/config"
+	l "github.com/redhatinsights/mbop/internal/logger"
+	"github.com/redhatinsights/mbop/internal/service/events"
+	"github.com/redhatinsights/mbop/internal/store"
+	"github.com/redhatinsights/platform-go-middlewares/identity"
+)
+
+const (
+	// how many registrations are pulled from the store at a time while exporting
+	exportBatchSize = 100
+	// the most rows accepted in a single import
+	maxImportRows = 5000
+)
+
+var (
+	validExportFormats    = []string{"json", "csv"}
+	registrationCSVHeader = []string{"uid", "display_name", "username", "extra", "expires_at"}
+)
+
+// registrationExportRow is the format used for both export and import, so an
+// export from one environment can be imported as-is into another.
+type registrationExportRow struct {
+	UID         string                 `json:"uid"`
+	DisplayName string                 `json:"display_name"`
+	Username    string                 `json:"username"`
+	Extra       map[string]interface{} `json:"extra,omitempty"`
+	ExpiresAt   *time.Time             `json:"expires_at,omitempty"`
+}
+
+type registrationImportResponse struct {
+	Results []registrationImportResult `json:"results"`
+	Meta    registrationImportMeta     `json:"meta"`
+}
+
+type registrationImportResult struct {
+	Row    int    `json:"row"`
+	UID    string `json:"uid"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type registrationImportMeta struct {
+	Created  int `json:"created"`
+	Conflict int `json:"conflict"`
+	Invalid  int `json:"invalid"`
+}
+
+// RegistrationExportHandler streams every registration for the org, as json by
+// default or csv with `?format=csv`.
+func RegistrationExportHandler(w http.ResponseWriter, r *http.Request) {
+	id := identity.Get(r.Context())
+	if !id.Identity.User.OrgAdmin {
+		doError(w, "user must be org admin to export registrations", 403)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if !stringInSlice(format, validExportFormats) {
+		do400(w, fmt.Sprintf("format must be one of %v", validExportFormats))
+		return
+	}
+
+	db := store.GetStore()
+	// sorting on uid since it's unique per org, keeping the pages stable
+	filter := store.RegistrationFilter{SortBy: "uid", SortOrder: "asc"}
+
+	// fetching the first page up front so we can still send a proper error
+	// before any of the body has been written
+	regs, _, err := db.All(id.Identity.OrgID, exportBatchSize, 0, &filter)
+	if err != nil {
+		do500(w, "error exporting registrations: "+err.Error())
+		return
+	}
+
+	var exp registrationExporter
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		exp = &csvExporter{w: csv.NewWriter(w)}
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		exp = &jsonExporter{w: w}
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=registrations.%s", format))
+	w.WriteHeader(http.StatusOK)
+
+	if err := exportRegistrations(w, exp, regs, func(offset int) ([]store.Registration, error) {
+		regs, _, err := db.All(id.Identity.OrgID, exportBatchSize, offset, &filter)
+		return regs, err
+	}); err != nil {
+		// too late to change the status code at this point
+		l.Log.Error(err, "error streaming registration export", "org_id", id.Identity.OrgID)
+	}
+}
+
+// the uids being imported are cert CNs, unique across every org, so an org
+// admin isn't enough: it has to be an internal user that's been granted it
+func canImportRegistrations(id *identity.XRHID) bool {
+	user := id.Identity.User
+	if !user.Internal || user.Username == "" {
+		return false
+	}
+	return stringInSlice(user.Username, splitList(config.Get().RegistrationImporters))
+}
+
+func exportRegistrations(w http.ResponseWriter, exp registrationExporter, first []store.Registration, next func(offset int) ([]store.Registration, error)) error {
+	if err := exp.begin(); err != nil {
+		return err
+	}
+
+	regs, offset := first, 0
+	for {
+		for i := range regs {
+			if err := exp.write(newRegistrationExportRow(&regs[i])); err != nil {
+				return err
+			}
+		}
+		if err := exp.flush(); err != nil {
+			return err
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+
+		if len(regs) < exportBatchSize {
+			break
+		}
+
+		offset += len(regs)
+		var err error
+		regs, err = next(offset)
+		if err != nil {
+			return err
+		}
+	}
+
+	return exp.end()
+}
+
+// RegistrationImportHandler creates registrations in bulk from the same format
+// the export produces (csv when sent as `text/csv`, json otherwise). Unlike
+// creating a single registration there is no cert to check the uids against,
+// so only the internal users listed in REGISTRATION_IMPORTERS can import, and
+// every row is reported back as created, conflict or invalid.
+func RegistrationImportHandler(w http.ResponseWriter, r *http.Request) {
+	id := identity.Get(r.Context())
+	if !canImportRegistrations(&id) {
+		doError(w, "user is not allowed to import registrations", 403)
+		return
+	}
+
+	var rows []importRow
+	var err error
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/csv" {
+		rows, err = parseCSVImport(r.Body)
+	} else {
+		rows, err = parseJSONImport(r.Body)
+	}
+	if err != nil {
+		do400(w, err.Error())
+		return
+	}
+
+	if len(rows) == 0 {
+		do400(w, "no registrations found in body")
+		return
+	}
+	if len(rows) > maxImportRows {
+		do400(w, fmt.Sprintf("too many registrations, can import at most %d at a time", maxImportRows))
+		return
+	}
+
+	out := registrationImportResponse{Results: make([]registrationImportResult, len(rows))}
+	toCreate := make([]store.Registration, 0, len(rows))
+	// where each registration being created came from in the request
+	createdRows := make([]int, 0, len(rows))
+
+	for i := range rows {
+		out.Results[i] = registrationImportResult{Row: i + 1, UID: rows[i].UID, Status: store.ImportInvalid}
+
+		if rows[i].err != nil {
+			out.Results[i].Detail = rows[i].err.Error()
+			continue
+		}
+		if err := rows[i].validate(); err != nil {
+			out.Results[i].Detail = err.Error()
+			continue
+		}
+
+		username := rows[i].Username
+		if username == "" {
+			username = id.Identity.User.Username
+		}
+
+		toCreate = append(toCreate, store.Registration{
+			OrgID:       id.Identity.OrgID,
+			Username:    username,
+			UID:         rows[i].UID,
+			DisplayName: rows[i].DisplayName,
+			Extra:       rows[i].Extra,
+			ExpiresAt:   rows[i].ExpiresAt,
+		})
+		createdRows = append(createdRows, i)
+	}
+
+	if len(toCreate) > 0 {
+		db := store.GetStore()
+		results, err := db.Import(toCreate)
+		if err != nil {
+			do500(w, "error importing registrations: "+err.Error())
+			return
+		}
+
+		for i := range results {
+			out.Results[createdRows[i]].Status = results[i].Status
+			out.Results[createdRows[i]].Detail = results[i].Detail
+
+			if results[i].Status == store.ImportCreated {
+				publishEvent(r, events.Event{Type: events.RegistrationCreated, UID: results[i].UID})
+			}
+		}
+	}
+
+	for i := range out.Results {
+		switch out.Results[i].Status {
+		case store.ImportCreated:
+			out.Meta.Created++
+		case store.ImportConflict:
+			out.Meta.Conflict++
+		default:
+			out.Meta.Invalid++
+		}
+	}
+
+	if out.Meta.Created > 0 {
+		recordAudit(r, store.AuditRegistrationImport, fmt.Sprintf("%d registrations", out.Meta.Created))
+	}
+
+	sendJSON(w, &out)
+}
+
+func newRegistrationExportRow(r *store.Registration) registrationExportRow {
+	return registrationExportRow{
+		UID:         r.UID,
+		DisplayName: r.DisplayName,
+		Username:    r.Username,
+		Extra:       r.Extra,
+		ExpiresAt:   r.ExpiresAt,
+	}
+}
+
+// the writers for each of the export formats
+type registrationExporter interface {
+	begin() error
+	write(row registrationExportRow) error
+	flush() error
+	end() error
+}
+
+type jsonExporter struct {
+	w       io.Writer
+	written bool
+}
+
+func (e *jsonExporter) begin() error {
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+func (e *jsonExporter) write(row registrationExportRow) error {
+	b, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	if e.written {
+		b = append([]byte(","), b...)
+	}
+	e.written = true
+
+	_, err = e.w.Write(b)
+	return err
+}
+
+func (e *jsonExporter) flush() error { return nil }
+
+func (e *jsonExporter) end() error {
+	_, err := io.WriteString(e.w, "]")
+	return err
+}
+
+type csvExporter struct {
+	w *csv.Writer
+}
+
+func (e *csvExporter) begin() error {
+	return e.w.Write(registrationCSVHeader)
+}
+
+func (e *csvExporter) write(row registrationExportRow) error {
+	var extra, expiresAt string
+	if row.Extra != nil {
+		b, err := json.Marshal(row.Extra)
+		if err != nil {
+			return err
+		}
+		extra = string(b)
+	}
+	if row.ExpiresAt != nil {
+		expiresAt = row.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+
+	return e.w.Write([]string{row.UID, row.DisplayName, row.Username, extra, expiresAt})
+}
+
+func (e *csvExporter) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExporter) end() error {
+	return e.flush()
+}
+
+// importRow is a single parsed row from an import, err is set when the row
+// couldn't be parsed at all.
+type importRow struct {
+	registrationExportRow
+	err error
+}
+
+func (row *importRow) validate() error {
+	switch {
+	case row.UID == "":
+		return errors.New("required parameter [uid] not found")
+	case row.DisplayName == "":
+		return errors.New("required parameter [display_name] not found")
+	case row.ExpiresAt != nil && row.ExpiresAt.Before(time.Now()):
+		return errors.New("parameter [expires_at] must be in the future")
+	}
+	return nil
+}
+
+func parseJSONImport(body io.Reader) ([]importRow, error) {
+	var raw []json.RawMessage
+	if err := json.NewDecoder(body).Decode(&raw); err != nil {
+		return nil, errors.New("invalid body, need a json array of registrations to import")
+	}
+
+	rows := make([]importRow, len(raw))
+	for i := range raw {
+		if err := json.Unmarshal(raw[i], &rows[i].registrationExportRow); err != nil {
+			rows[i].err = errors.New("invalid registration: " + err.Error())
+		}
+	}
+
+	return rows, nil
+}
+
+func parseCSVImport(body io.Reader) ([]importRow, error) {
+	r := csv.NewReader(body)
+	r.FieldsPerRecord = len(registrationCSVHeader)
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, errors.New("invalid body, need a csv header of " + fmt.Sprint(registrationCSVHeader))
+	}
+	for i := range header {
+		if header[i] != registrationCSVHeader[i] {
+			return nil, errors.New("invalid body, need a csv header of " + fmt.Sprint(registrationCSVHeader))
+		}
+	}
+
+	rows := make([]importRow, 0)
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) || !errors.Is(parseErr.Err, csv.ErrFieldCount) {
+				return nil, errors.New("invalid csv: " + err.Error())
+			}
+			rows = append(rows, importRow{err: errors.New("invalid registration: " + parseErr.Err.Error())})
+			continue
+		}
+
+		row := importRow{registrationExportRow: registrationExportRow{
+			UID:         record[0],
+			DisplayName: record[1],
+			Username:    record[2],
+		}}
+		if record[3] != "" {
+			if err := json.Unmarshal([]byte(record[3]), &row.Extra); err != nil {
+				row.err = errors.New("invalid registration: extra must be a json object")
+			}
+		}
+		if record[4] != "" && row.err == nil {
+			t, err := time.Parse(time.RFC3339, record[4])
+			if err != nil {
+				row.err = errors.New("invalid registration: expires_at must be an RFC3339 timestamp")
+			} else {
+				t = t.UTC()
+				row.ExpiresAt = &t
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/redhatinsights/mbop/internal/store"
+	"github.com/redhatinsights/platform-go-middlewares/identity"
+)
+
+func (suite *RegistrationTestSuite) TestRegistrationExportJSON() {
+	_, err := suite.store.Create(&store.Registration{UID: "b", OrgID: "1234", DisplayName: "two", Username: "foobar"})
+	suite.Nil(err)
+	_, err = suite.store.Create(&store.Registration{UID: "a", OrgID: "1234", DisplayName: "one", Username: "foobar", Extra: map[string]interface{}{"env": "prod"}})
+	suite.Nil(err)
+	_, err = suite.store.Create(&store.Registration{UID: "c", OrgID: "4321", DisplayName: "three", Username: "foobar"})
+	suite.Nil(err)
+
+	RegistrationExportHandler(suite.rec, newBulkRequest(http.MethodGet, "http://foobar/registrations/export", nil, true))
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusOK, status)
+	suite.Equal(`[{"uid":"a","display_name":"one","username":"foobar","extra":{"env":"prod"}},{"uid":"b","display_name":"two","username":"foobar"}]`, rspBody)
+}
+
+func (suite *RegistrationTestSuite) TestRegistrationExportCSV() {
+	_, err := suite.store.Create(&store.Registration{UID: "a", OrgID: "1234", DisplayName: "one", Username: "foobar", Extra: map[string]interface{}{"env": "prod"}})
+	suite.Nil(err)
+
+	RegistrationExportHandler(suite.rec, newBulkRequest(http.MethodGet, "http://foobar/registrations/export?format=csv", nil, true))
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusOK, status)
+	suite.Equal("text/csv", suite.rec.Header().Get("Content-Type"))
+	suite.Equal("uid,display_name,username,extra,expires_at\na,one,foobar,\"{\"\"env\"\":\"\"prod\"\"}\",\n", rspBody)
+}
+
+func (suite *RegistrationTestSuite) TestRegistrationExportEmpty() {
+	RegistrationExportHandler(suite.rec, newBulkRequest(http.MethodGet, "http://foobar/registrations/export", nil, true))
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusOK, status)
+	suite.Equal("[]", rspBody)
+}
+
+func (suite *RegistrationTestSuite) TestRegistrationExportBadFormat() {
+	RegistrationExportHandler(suite.rec, newBulkRequest(http.MethodGet, "http://foobar/registrations/export?format=xml", nil, true))
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusBadRequest, status)
+	suite.Equal("{\"message\":\"format must be one of [json csv]\"}", rspBody)
+}
+
+func (suite *RegistrationTestSuite) TestNotOrgAdminExport() {
+	RegistrationExportHandler(suite.rec, newBulkRequest(http.MethodGet, "http://foobar/registrations/export", nil, false))
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusForbidden, status)
+	suite.Equal("{\"message\":\"user must be org admin to export registrations\"}", rspBody)
+}
+
+func (suite *RegistrationTestSuite) TestRegistrationImportJSON() {
+	_, err := suite.store.Create(&store.Registration{UID: "b", OrgID: "1234", DisplayName: "existing"})
+	suite.Nil(err)
+
+	body := []byte(`[
+		{"uid": "a", "display_name": "one", "extra": {"env": "prod"}},
+		{"uid": "b", "display_name": "two"},
+		{"uid": "c"},
+		{"uid": 1234}
+	]`)
+	RegistrationImportHandler(suite.rec, newImportRequest(body, true))
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusOK, status)
+
+	var rsp registrationImportResponse
+	suite.Nil(json.Unmarshal([]byte(rspBody), &rsp))
+	suite.Equal(registrationImportMeta{Created: 1, Conflict: 1, Invalid: 2}, rsp.Meta)
+	suite.Equal(store.ImportCreated, rsp.Results[0].Status)
+	suite.Equal(store.ImportConflict, rsp.Results[1].Status)
+	suite.Equal(store.ImportInvalid, rsp.Results[2].Status)
+	suite.Equal("required parameter [display_name] not found", rsp.Results[2].Detail)
+	suite.Equal(store.ImportInvalid, rsp.Results[3].Status)
+	suite.Equal(4, rsp.Results[3].Row)
+
+	reg, err := suite.store.Find("1234", "a")
+	suite.Nil(err)
+	suite.Equal("one", reg.DisplayName)
+	suite.Equal("foobar", reg.Username)
+	suite.Equal("prod", reg.Extra["env"])
+}
+
+func (suite *RegistrationTestSuite) TestRegistrationImportCSV() {
+	body := []byte("uid,display_name,username,extra,expires_at\na,one,someone,,\nb,two,,not json,\nc,three\n")
+	req := newImportRequest(body, true)
+	req.Header.Set("Content-Type", "text/csv; charset=utf-8")
+
+	RegistrationImportHandler(suite.rec, req)
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusOK, status)
+
+	var rsp registrationImportResponse
+	suite.Nil(json.Unmarshal([]byte(rspBody), &rsp))
+	suite.Equal(registrationImportMeta{Created: 1, Conflict: 0, Invalid: 2}, rsp.Meta)
+	suite.Equal("invalid registration: extra must be a json object", rsp.Results[1].Detail)
+
+	reg, err := suite.store.Find("1234", "a")
+	suite.Nil(err)
+	suite.Equal("someone", reg.Username)
+}
+
+func (suite *RegistrationTestSuite) TestRegistrationImportBadCSVHeader() {
+	req := newImportRequest([]byte("uid,name\na,one\n"), true)
+	req.Header.Set("Content-Type", "text/csv")
+
+	RegistrationImportHandler(suite.rec, req)
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusBadRequest, status)
+	suite.True(strings.Contains(rspBody, "need a csv header"))
+}
+
+func (suite *RegistrationTestSuite) TestRegistrationImportBadBody() {
+	RegistrationImportHandler(suite.rec, newImportRequest([]byte(`{"uid": "a"}`), true))
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusBadRequest, status)
+	suite.Equal("{\"message\":\"invalid body, need a json array of registrations to import\"}", rspBody)
+}
+
+func (suite *RegistrationTestSuite) TestRegistrationImportEmpty() {
+	RegistrationImportHandler(suite.rec, newImportRequest([]byte(`[]`), true))
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusBadRequest, status)
+	suite.Equal("{\"message\":\"no registrations found in body\"}", rspBody)
+}
+
+func (suite *RegistrationTestSuite) TestOrgAdminCannotImport() {
+	RegistrationImportHandler(suite.rec, newBulkRequest(http.MethodPost, "http://foobar/registrations/import", []byte(`[{"uid": "a", "display_name": "one"}]`), true))
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusForbidden, status)
+	suite.Equal("{\"message\":\"user is not allowed to import registrations\"}", rspBody)
+
+	_, err := suite.store.Find("1234", "a")
+	suite.ErrorIs(err, store.ErrRegistrationNotFound)
+}
+
+func (suite *RegistrationTestSuite) TestNotImporterCannotImport() {
+	req := newImportRequest([]byte(`[{"uid": "a", "display_name": "one"}]`), true)
+	id := identity.Get(req.Context())
+	id.Identity.User.Username = "someone-else"
+	req = req.WithContext(context.WithValue(req.Context(), identity.Key, id))
+
+	RegistrationImportHandler(suite.rec, req)
+
+	status, _ := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusForbidden, status)
+}
+
+func (suite *RegistrationTestSuite) TestNotInternalCannotImport() {
+	RegistrationImportHandler(suite.rec, newImportRequest([]byte(`[{"uid": "a", "display_name": "one"}]`), false))
+
+	status, _ := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusForbidden, status)
+}
+
+func (suite *RegistrationTestSuite) TestRegistrationExportImportRoundTrip() {
+	_, err := suite.store.Create(&store.Registration{UID: "a", OrgID: "1234", DisplayName: "one", Username: "foobar", Extra: map[string]interface{}{"env": "prod"}})
+	suite.Nil(err)
+
+	RegistrationExportHandler(suite.rec, newBulkRequest(http.MethodGet, "http://foobar/registrations/export?format=csv", nil, true))
+	_, exported := statusAndBodyFromReq(suite)
+
+	// clearing out the original, as if importing into another environment
+	suite.Nil(suite.store.Delete("1234", "a"))
+	suite.rec = httptest.NewRecorder()
+
+	req := newImportRequest([]byte(exported), true)
+	req.Header.Set("Content-Type", "text/csv")
+	RegistrationImportHandler(suite.rec, req)
+
+	status, _ := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusOK, status)
+
+	reg, err := suite.store.Find("1234", "a")
+	suite.Nil(err)
+	suite.Equal("one", reg.DisplayName)
+	suite.Equal("prod", reg.Extra["env"])
+}
+
+func newBulkRequest(method, url string, body []byte, orgAdmin bool) *http.Request {
+	req := httptest.NewRequest(method, url, bytes.NewReader(body))
+	return req.WithContext(context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+		User:  identity.User{OrgAdmin: orgAdmin, Username: "foobar"},
+		OrgID: "1234",
+	}}))
+}
+
+// an import from the internal "foobar" user, who's in REGISTRATION_IMPORTERS
+// for these tests
+func newImportRequest(body []byte, internal bool) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "http://foobar/registrations/import", bytes.NewReader(body))
+	return req.WithContext(context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+		User:  identity.User{Internal: internal, Username: "foobar"},
+		OrgID: "1234",
+	}}))
+}
@@ -8,14 +8,26 @@ import (
 	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/redhatinsights/mbop/internal/config"
+	"github.com/redhatinsights/mbop/internal/service/events"
 	"github.com/redhatinsights/mbop/internal/store"
 	"github.com/redhatinsights/platform-go-middlewares/identity"
 )
 
 type registationCreateRequest struct {
-	UID         *string `json:"uid,omitempty"`
-	DisplayName *string `json:"display_name,omitempty"`
+	UID         *string                `json:"uid,omitempty"`
+	DisplayName *string                `json:"display_name,omitempty"`
+	Extra       map[string]interface{} `json:"extra,omitempty"`
+	ExpiresAt   *time.Time             `json:"expires_at,omitempty"`
+}
+
+type registrationUpdateRequest struct {
+	DisplayName *string                 `json:"display_name,omitempty"`
+	Extra       *map[string]interface{} `json:"extra,omitempty"`
+	ExpiresAt   *time.Time              `json:"expires_at,omitempty"`
+}
+
+type registrationRotateRequest struct {
+	UID *string `json:"uid,omitempty"`
 }
 
 type registrationCollection struct {
@@ -24,14 +36,22 @@ type registrationCollection struct {
 }
 
 type registrationResponse struct {
-	UID         string    `json:"uid"`
-	DisplayName string    `json:"display_name"`
-	Username    string    `json:"username"`
-	CreatedAt   time.Time `json:"created_at"`
+	OrgID       string                 `json:"org_id"`
+	UID         string                 `json:"uid"`
+	DisplayName string                 `json:"display_name"`
+	Username    string                 `json:"username"`
+	Extra       map[string]interface{} `json:"extra"`
+	ExpiresAt   *time.Time             `json:"expires_at,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
 }
 
+// Usage and Limit are the org's registration quota, a limit of 0 meaning
+// unlimited.
 type registrationMeta struct {
 	Count int `json:"count"`
+	Usage int `json:"usage"`
+	Limit int `json:"limit"`
 }
 
 func RegistrationListHandler(w http.ResponseWriter, r *http.Request) {
@@ -52,8 +72,20 @@ func RegistrationListHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	filter, err := initRegistrationFilter(r)
+	if err != nil {
+		do400(w, err.Error())
+		return
+	}
+
 	db := store.GetStore()
-	regs, count, err := db.All(id.Identity.OrgID, limit, offset)
+	regs, count, err := db.All(id.Identity.OrgID, limit, offset, &filter)
+	if err != nil {
+		do500(w, err.Error())
+		return
+	}
+
+	quota, err := db.Quota(id.Identity.OrgID)
 	if err != nil {
 		do500(w, err.Error())
 		return
@@ -61,38 +93,75 @@ func RegistrationListHandler(w http.ResponseWriter, r *http.Request) {
 
 	out := make([]registrationResponse, len(regs))
 	for i := range regs {
-		out[i] = registrationResponse{
-			UID:         regs[i].UID,
-			DisplayName: regs[i].DisplayName,
-			Username:    regs[i].Username,
-			CreatedAt:   regs[i].CreatedAt,
-		}
+		out[i] = newRegistrationResponse(&regs[i])
 	}
 
 	sendJSON(w, &registrationCollection{
 		Registrations: out,
 		Meta: registrationMeta{
 			Count: count,
+			Usage: quota.Registrations,
+			Limit: quota.Limits.Registrations,
 		},
 	})
 }
 
-func RegistrationCreateHandler(w http.ResponseWriter, r *http.Request) {
+func RegistrationGetHandler(w http.ResponseWriter, r *http.Request) {
+	uid := chi.URLParam(r, "uid")
+	if uid == "" {
+		do400(w, "invalid uid passed in path")
+		return
+	}
+
 	id := identity.Get(r.Context())
+	if !id.Identity.User.OrgAdmin {
+		doError(w, "user must be org admin to get registration", 403)
+		return
+	}
+
 	db := store.GetStore()
 
-	if config.Get().AllowlistEnabled {
-		allowed, err := db.AllowedIP(r.Header.Get(config.Get().AllowlistHeader), id.Identity.OrgID)
-		if err != nil {
-			do500(w, "error listing ip addresses: "+err.Error())
-			return
+	reg, err := db.Find(id.Identity.OrgID, uid)
+	if err != nil {
+		if errors.Is(err, store.ErrRegistrationNotFound) {
+			do404(w, err.Error())
+		} else {
+			do500(w, "error fetching registration: "+err.Error())
 		}
-		if !allowed {
-			doError(w, "address is not allowlisted", 403)
-			return
+		return
+	}
+
+	sendJSON(w, newRegistrationResponse(reg))
+}
+
+// RegistrationSelfHandler looks up the registration belonging to the cert
+// passed through from the gateway, so a satellite can see its own registration
+func RegistrationSelfHandler(w http.ResponseWriter, r *http.Request) {
+	gatewayCN, err := getCertCN(r.Header.Get(CertHeader))
+	if err != nil {
+		do400(w, err.Error())
+		return
+	}
+
+	db := store.GetStore()
+
+	reg, err := db.FindByUID(gatewayCN)
+	if err != nil {
+		if errors.Is(err, store.ErrRegistrationNotFound) {
+			do404(w, err.Error())
+		} else {
+			do500(w, "error fetching registration: "+err.Error())
 		}
+		return
 	}
 
+	sendJSON(w, newRegistrationResponse(reg))
+}
+
+func RegistrationCreateHandler(w http.ResponseWriter, r *http.Request) {
+	id := identity.Get(r.Context())
+	db := store.GetStore()
+
 	b, err := io.ReadAll(r.Body)
 	if err != nil {
 		do500(w, "failed to read body bytes: "+err.Error())
@@ -116,6 +185,11 @@ func RegistrationCreateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if body.ExpiresAt != nil && body.ExpiresAt.Before(time.Now()) {
+		do400(w, "parameter [expires_at] must be in the future")
+		return
+	}
+
 	if !id.Identity.User.OrgAdmin {
 		doError(w, "user must be org admin to register satellite", 403)
 		return
@@ -141,19 +215,162 @@ func RegistrationCreateHandler(w http.ResponseWriter, r *http.Request) {
 		Username:    id.Identity.User.Username,
 		UID:         *body.UID,
 		DisplayName: *body.DisplayName,
+		Extra:       body.Extra,
+		ExpiresAt:   body.ExpiresAt,
 	})
 	if err != nil {
-		if errors.Is(err, store.ErrRegistrationAlreadyExists{}) {
+		switch {
+		case errors.Is(err, store.ErrRegistrationAlreadyExists{}):
 			doError(w, err.Error(), 409)
-		} else {
+		case errors.Is(err, store.ErrQuotaExceeded{}):
+			doError(w, err.Error(), 429)
+		default:
 			do500(w, "failed to create registration: "+err.Error())
 		}
 		return
 	}
 
+	recordAudit(r, store.AuditRegistrationCreate, *body.UID)
+	publishEvent(r, events.Event{Type: events.RegistrationCreated, UID: *body.UID})
 	sendJSONWithStatusCode(w, newResponse("Successfully registered"), 201)
 }
 
+func RegistrationUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	uid := chi.URLParam(r, "uid")
+	if uid == "" {
+		do400(w, "invalid uid passed in path")
+		return
+	}
+
+	id := identity.Get(r.Context())
+	if !id.Identity.User.OrgAdmin {
+		doError(w, "user must be org admin to update registration", 403)
+		return
+	}
+
+	var body registrationUpdateRequest
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		do400(w, "invalid body, need a json object with [display_name], [extra] and/or [expires_at] to update registration")
+		return
+	}
+
+	if body.DisplayName == nil && body.Extra == nil && body.ExpiresAt == nil {
+		do400(w, "nothing to update, need [display_name], [extra] and/or [expires_at] in body")
+		return
+	}
+
+	if body.DisplayName != nil && *body.DisplayName == "" {
+		do400(w, "parameter [display_name] cannot be empty")
+		return
+	}
+
+	if body.ExpiresAt != nil && body.ExpiresAt.Before(time.Now()) {
+		do400(w, "parameter [expires_at] must be in the future")
+		return
+	}
+
+	db := store.GetStore()
+
+	err = db.Update(
+		&store.Registration{OrgID: id.Identity.OrgID, UID: uid},
+		&store.RegistrationUpdate{DisplayName: body.DisplayName, Extra: body.Extra, ExpiresAt: body.ExpiresAt},
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRegistrationNotFound):
+			do404(w, err.Error())
+		case errors.Is(err, store.ErrRegistrationAlreadyExists{}):
+			doError(w, err.Error(), 409)
+		default:
+			do500(w, "error updating registration: "+err.Error())
+		}
+		return
+	}
+
+	recordAudit(r, store.AuditRegistrationUpdate, uid)
+
+	reg, err := db.Find(id.Identity.OrgID, uid)
+	if err != nil {
+		do500(w, "error fetching updated registration: "+err.Error())
+		return
+	}
+
+	sendJSON(w, newRegistrationResponse(reg))
+}
+
+// RegistrationRotateHandler moves a registration over to the new cert's CN,
+// which has to be passed through from the gateway just like on create.
+func RegistrationRotateHandler(w http.ResponseWriter, r *http.Request) {
+	uid := chi.URLParam(r, "uid")
+	if uid == "" {
+		do400(w, "invalid uid passed in path")
+		return
+	}
+
+	id := identity.Get(r.Context())
+	if !id.Identity.User.OrgAdmin {
+		doError(w, "user must be org admin to rotate registration", 403)
+		return
+	}
+
+	var body registrationRotateRequest
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		do400(w, "invalid body, need a json object with the new [uid] to rotate registration")
+		return
+	}
+
+	if body.UID == nil || *body.UID == "" {
+		do400(w, "required parameter [uid] not found in body")
+		return
+	}
+
+	gatewayCN, err := getCertCN(r.Header.Get(CertHeader))
+	if err != nil {
+		do400(w, err.Error())
+		return
+	}
+
+	if gatewayCN != *body.UID {
+		do400(w, "x-rh-certauth-cn does not match uid")
+		return
+	}
+
+	if *body.UID == uid {
+		do400(w, "new uid is the same as the current uid")
+		return
+	}
+
+	db := store.GetStore()
+
+	err = db.RotateUID(id.Identity.OrgID, uid, *body.UID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRegistrationNotFound):
+			do404(w, err.Error())
+		case errors.Is(err, store.ErrRegistrationAlreadyExists{}):
+			doError(w, err.Error(), 409)
+		default:
+			do500(w, "error rotating registration: "+err.Error())
+		}
+		return
+	}
+
+	recordAudit(r, store.AuditRegistrationRotate, uid+" -> "+*body.UID)
+	// to anything downstream the old uid is gone and the new one registered
+	publishEvent(r, events.Event{Type: events.RegistrationDeleted, UID: uid})
+	publishEvent(r, events.Event{Type: events.RegistrationCreated, UID: *body.UID})
+
+	reg, err := db.Find(id.Identity.OrgID, *body.UID)
+	if err != nil {
+		do500(w, "error fetching rotated registration: "+err.Error())
+		return
+	}
+
+	sendJSON(w, newRegistrationResponse(reg))
+}
+
 func RegistrationDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	uid := chi.URLParam(r, "uid")
 	if uid == "" {
@@ -178,5 +395,62 @@ func RegistrationDeleteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recordAudit(r, store.AuditRegistrationDelete, uid)
+	publishEvent(r, events.Event{Type: events.RegistrationDeleted, UID: uid})
 	w.WriteHeader(204)
 }
+
+func newRegistrationResponse(r *store.Registration) registrationResponse {
+	return registrationResponse{
+		OrgID:       r.OrgID,
+		UID:         r.UID,
+		DisplayName: r.DisplayName,
+		Username:    r.Username,
+		Extra:       r.Extra,
+		ExpiresAt:   r.ExpiresAt,
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+}
+
+func RegistrationRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	uid := chi.URLParam(r, "uid")
+	if uid == "" {
+		do400(w, "invalid uid passed in path")
+		return
+	}
+
+	id := identity.Get(r.Context())
+	if !id.Identity.User.OrgAdmin {
+		doError(w, "user must be org admin to restore registration", 403)
+		return
+	}
+
+	db := store.GetStore()
+
+	err := db.Restore(id.Identity.OrgID, uid)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRegistrationNotFound):
+			do404(w, "no deleted registration found to restore")
+		case errors.Is(err, store.ErrRegistrationAlreadyExists{}):
+			doError(w, err.Error(), 409)
+		case errors.Is(err, store.ErrQuotaExceeded{}):
+			doError(w, err.Error(), 429)
+		default:
+			do500(w, "error restoring registration: "+err.Error())
+		}
+		return
+	}
+
+	recordAudit(r, store.AuditRegistrationRestore, uid)
+	publishEvent(r, events.Event{Type: events.RegistrationCreated, UID: uid})
+
+	reg, err := db.Find(id.Identity.OrgID, uid)
+	if err != nil {
+		do500(w, "error fetching restored registration: "+err.Error())
+		return
+	}
+
+	sendJSON(w, newRegistrationResponse(reg))
+}
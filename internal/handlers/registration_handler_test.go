@@ -29,6 +29,7 @@ func (suite *RegistrationTestSuite) SetupSuite() {
 	_ = logger.Init()
 	config.Reset()
 	os.Setenv("STORE_BACKEND", "memory")
+	os.Setenv("REGISTRATION_IMPORTERS", "foobar")
 }
 
 func (suite *RegistrationTestSuite) BeforeTest(_, _ string) {
@@ -301,6 +302,80 @@ func (suite *RegistrationTestSuite) TestRegistrationNotFoundDelete() {
 	suite.Equal("{\"message\":\"registration not found\"}", rspBody)
 }
 
+func (suite *RegistrationTestSuite) TestSuccessfulRegistrationUpdate() {
+	_, err := suite.store.Create(&store.Registration{UID: "abc1234", OrgID: "1234", DisplayName: "before"})
+	suite.Nil(err)
+
+	body := []byte(`{"display_name": "after", "extra": {"version": "6.13"}}`)
+	req := newUpdateRequest("abc1234", body, true)
+
+	RegistrationUpdateHandler(suite.rec, req)
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusOK, status)
+
+	var reg registrationResponse
+	suite.Nil(json.Unmarshal([]byte(rspBody), &reg))
+	suite.Equal("after", reg.DisplayName)
+	suite.Equal("abc1234", reg.UID)
+
+	found, err := suite.store.Find("1234", "abc1234")
+	suite.Nil(err)
+	suite.Equal("after", found.DisplayName)
+	suite.Equal("6.13", found.Extra["version"])
+}
+
+func (suite *RegistrationTestSuite) TestNotOrgAdminUpdate() {
+	_, err := suite.store.Create(&store.Registration{UID: "abc1234", OrgID: "1234", DisplayName: "before"})
+	suite.Nil(err)
+
+	req := newUpdateRequest("abc1234", []byte(`{"display_name": "after"}`), false)
+
+	RegistrationUpdateHandler(suite.rec, req)
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusForbidden, status)
+	suite.Equal("{\"message\":\"user must be org admin to update registration\"}", rspBody)
+}
+
+func (suite *RegistrationTestSuite) TestEmptyBodyUpdate() {
+	_, err := suite.store.Create(&store.Registration{UID: "abc1234", OrgID: "1234", DisplayName: "before"})
+	suite.Nil(err)
+
+	req := newUpdateRequest("abc1234", []byte(`{}`), true)
+
+	RegistrationUpdateHandler(suite.rec, req)
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusBadRequest, status)
+	suite.Equal("{\"message\":\"nothing to update, need [display_name], [extra] and/or [expires_at] in body\"}", rspBody)
+}
+
+func (suite *RegistrationTestSuite) TestDuplicateDisplayNameUpdate() {
+	_, err := suite.store.Create(&store.Registration{UID: "abc1234", OrgID: "1234", DisplayName: "one"})
+	suite.Nil(err)
+	_, err = suite.store.Create(&store.Registration{UID: "abc2345", OrgID: "1234", DisplayName: "two"})
+	suite.Nil(err)
+
+	req := newUpdateRequest("abc1234", []byte(`{"display_name": "two"}`), true)
+
+	RegistrationUpdateHandler(suite.rec, req)
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusConflict, status)
+	suite.Equal("{\"message\":\"existing registration found: display_name already exists\"}", rspBody)
+}
+
+func (suite *RegistrationTestSuite) TestRegistrationNotFoundUpdate() {
+	req := newUpdateRequest("abc1234", []byte(`{"display_name": "after"}`), true)
+
+	RegistrationUpdateHandler(suite.rec, req)
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusNotFound, status)
+	suite.Equal("{\"message\":\"registration not found\"}", rspBody)
+}
+
 func (suite *RegistrationTestSuite) TestRegistrationList() {
 	_, err := suite.store.Create(&store.Registration{
 		UID:         "abc1234",
@@ -330,6 +405,9 @@ func (suite *RegistrationTestSuite) TestRegistrationList() {
 	suite.Equal("foobar", body.Registrations[0].Username)
 	suite.Equal("abc1234", body.Registrations[0].UID)
 	suite.Equal(1, body.Meta.Count)
+	suite.Equal(1, body.Meta.Usage)
+	// no quota by default
+	suite.Equal(0, body.Meta.Limit)
 
 	regs := raw["registrations"].([]any)
 	r := regs[0].(map[string]any)
@@ -339,9 +417,433 @@ func (suite *RegistrationTestSuite) TestRegistrationList() {
 	suite.WithinDuration(time.Now(), t, 5*time.Second)
 }
 
+func (suite *RegistrationTestSuite) TestSuccessfulRegistrationCreateWithExtra() {
+	body := []byte(`{"uid": "abc1234", "display_name": "foobar", "extra": {"version": "6.13", "location": "rdu"}}`)
+	req := httptest.NewRequest("POST", "http://foobar/registrations", bytes.NewReader(body)).
+		WithContext(context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+			User:  identity.User{OrgAdmin: true, Username: "foobar"},
+			OrgID: "1234",
+		}}))
+	req.Header.Set("x-rh-certauth-cn", "/CN=abc1234")
+
+	RegistrationCreateHandler(suite.rec, req)
+
+	status, _ := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusCreated, status)
+
+	found, err := suite.store.Find("1234", "abc1234")
+	suite.Nil(err)
+	suite.Equal("6.13", found.Extra["version"])
+	suite.Equal("rdu", found.Extra["location"])
+}
+
+func (suite *RegistrationTestSuite) TestRegistrationListExtraFilter() {
+	_, err := suite.store.Create(&store.Registration{
+		UID:         "abc1234",
+		OrgID:       "1234",
+		DisplayName: "one",
+		Extra:       map[string]interface{}{"location": "rdu"},
+	})
+	suite.Nil(err)
+	_, err = suite.store.Create(&store.Registration{
+		UID:         "abc2345",
+		OrgID:       "1234",
+		DisplayName: "two",
+		Extra:       map[string]interface{}{"location": "bos"},
+	})
+	suite.Nil(err)
+
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/registrations?extra[location]=bos", nil)
+	req = req.WithContext(context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+		User:  identity.User{OrgAdmin: true, Username: "foobar"},
+		OrgID: "1234",
+	}}))
+
+	RegistrationListHandler(suite.rec, req)
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusOK, status)
+
+	var body registrationCollection
+	suite.Nil(json.Unmarshal([]byte(rspBody), &body))
+	suite.Equal(1, body.Meta.Count)
+	suite.Equal("abc2345", body.Registrations[0].UID)
+	suite.Equal("bos", body.Registrations[0].Extra["location"])
+	suite.False(body.Registrations[0].UpdatedAt.IsZero())
+}
+
+func (suite *RegistrationTestSuite) TestSuccessfulRegistrationGet() {
+	_, err := suite.store.Create(&store.Registration{UID: "abc1234", OrgID: "1234", DisplayName: "one", Username: "foobar"})
+	suite.Nil(err)
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("uid", "abc1234")
+
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/registrations/{uid}", nil)
+	req = req.WithContext(context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+		User:  identity.User{OrgAdmin: true, Username: "foobar"},
+		OrgID: "1234",
+	}}))
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	RegistrationGetHandler(suite.rec, req)
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusOK, status)
+
+	var reg registrationResponse
+	suite.Nil(json.Unmarshal([]byte(rspBody), &reg))
+	suite.Equal("abc1234", reg.UID)
+	suite.Equal("1234", reg.OrgID)
+	suite.Equal("one", reg.DisplayName)
+	suite.Equal("foobar", reg.Username)
+}
+
+func (suite *RegistrationTestSuite) TestOtherOrgRegistrationGet() {
+	_, err := suite.store.Create(&store.Registration{UID: "abc1234", OrgID: "2345", DisplayName: "one"})
+	suite.Nil(err)
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("uid", "abc1234")
+
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/registrations/{uid}", nil)
+	req = req.WithContext(context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+		User:  identity.User{OrgAdmin: true, Username: "foobar"},
+		OrgID: "1234",
+	}}))
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	RegistrationGetHandler(suite.rec, req)
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusNotFound, status)
+	suite.Equal("{\"message\":\"registration not found\"}", rspBody)
+}
+
+func (suite *RegistrationTestSuite) TestNotOrgAdminGet() {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("uid", "abc1234")
+
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/registrations/{uid}", nil)
+	req = req.WithContext(context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+		User:  identity.User{OrgAdmin: false, Username: "foobar"},
+		OrgID: "1234",
+	}}))
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	RegistrationGetHandler(suite.rec, req)
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusForbidden, status)
+	suite.Equal("{\"message\":\"user must be org admin to get registration\"}", rspBody)
+}
+
+func (suite *RegistrationTestSuite) TestSuccessfulRegistrationSelf() {
+	_, err := suite.store.Create(&store.Registration{UID: "abc1234", OrgID: "1234", DisplayName: "one"})
+	suite.Nil(err)
+
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/registrations/self", nil)
+	req.Header.Set(CertHeader, "/CN=abc1234")
+
+	RegistrationSelfHandler(suite.rec, req)
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusOK, status)
+
+	var reg registrationResponse
+	suite.Nil(json.Unmarshal([]byte(rspBody), &reg))
+	suite.Equal("abc1234", reg.UID)
+	suite.Equal("1234", reg.OrgID)
+}
+
+func (suite *RegistrationTestSuite) TestNoGatewayCNSelf() {
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/registrations/self", nil)
+
+	RegistrationSelfHandler(suite.rec, req)
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusBadRequest, status)
+	suite.Equal("{\"message\":\"[x-rh-certauth-cn] header not present\"}", rspBody)
+}
+
+func (suite *RegistrationTestSuite) TestRegistrationNotFoundSelf() {
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/registrations/self", nil)
+	req.Header.Set(CertHeader, "/CN=abc1234")
+
+	RegistrationSelfHandler(suite.rec, req)
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusNotFound, status)
+	suite.Equal("{\"message\":\"registration not found\"}", rspBody)
+}
+
+func (suite *RegistrationTestSuite) TestRegistrationListSearch() {
+	for _, name := range []string{"rdu-2", "bos-1", "rdu-1"} {
+		_, err := suite.store.Create(&store.Registration{UID: name, OrgID: "1234", DisplayName: name, Username: "foobar"})
+		suite.Nil(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/registrations?display_name=rdu&sort_by=display_name&sort_order=asc&limit=1", nil)
+	req = req.WithContext(context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+		User:  identity.User{OrgAdmin: true, Username: "foobar"},
+		OrgID: "1234",
+	}}))
+
+	RegistrationListHandler(suite.rec, req)
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusOK, status)
+
+	var body registrationCollection
+	suite.Nil(json.Unmarshal([]byte(rspBody), &body))
+	suite.Equal(2, body.Meta.Count)
+	suite.Equal(1, len(body.Registrations))
+	suite.Equal("rdu-1", body.Registrations[0].DisplayName)
+}
+
+func (suite *RegistrationTestSuite) TestRegistrationListBadSort() {
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/registrations?sort_by=extra", nil)
+	req = req.WithContext(context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+		User:  identity.User{OrgAdmin: true, Username: "foobar"},
+		OrgID: "1234",
+	}}))
+
+	RegistrationListHandler(suite.rec, req)
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusBadRequest, status)
+	suite.Equal("{\"message\":\"sort_by must be one of created_at, updated_at, display_name, uid, username\"}", rspBody)
+}
+
+func (suite *RegistrationTestSuite) TestRegistrationListNegativeLimit() {
+	for _, query := range []string{"limit=-5", "offset=-5"} {
+		suite.rec = httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "http://foobar/registrations?"+query, nil)
+		req = req.WithContext(context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+			User:  identity.User{OrgAdmin: true, Username: "foobar"},
+			OrgID: "1234",
+		}}))
+
+		RegistrationListHandler(suite.rec, req)
+
+		status, _ := statusAndBodyFromReq(suite)
+		suite.Equal(http.StatusBadRequest, status, query)
+	}
+}
+
+func (suite *RegistrationTestSuite) TestRegistrationListBadCreatedAfter() {
+	req := httptest.NewRequest(http.MethodGet, "http://foobar/registrations?created_after=yesterday", nil)
+	req = req.WithContext(context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+		User:  identity.User{OrgAdmin: true, Username: "foobar"},
+		OrgID: "1234",
+	}}))
+
+	RegistrationListHandler(suite.rec, req)
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusBadRequest, status)
+	suite.Equal("{\"message\":\"created_after must be an RFC3339 timestamp\"}", rspBody)
+}
+
+func (suite *RegistrationTestSuite) TestSuccessfulRegistrationRestore() {
+	_, err := suite.store.Create(&store.Registration{UID: "abc1234", OrgID: "1234", DisplayName: "one"})
+	suite.Nil(err)
+	suite.Nil(suite.store.Delete("1234", "abc1234"))
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("uid", "abc1234")
+
+	req := httptest.NewRequest(http.MethodPost, "http://foobar/registrations/{uid}/restore", nil)
+	req = req.WithContext(context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+		User:  identity.User{OrgAdmin: true, Username: "foobar"},
+		OrgID: "1234",
+	}}))
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	RegistrationRestoreHandler(suite.rec, req)
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusOK, status)
+
+	var reg registrationResponse
+	suite.Nil(json.Unmarshal([]byte(rspBody), &reg))
+	suite.Equal("abc1234", reg.UID)
+}
+
+func (suite *RegistrationTestSuite) TestNothingToRestore() {
+	_, err := suite.store.Create(&store.Registration{UID: "abc1234", OrgID: "1234", DisplayName: "one"})
+	suite.Nil(err)
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("uid", "abc1234")
+
+	req := httptest.NewRequest(http.MethodPost, "http://foobar/registrations/{uid}/restore", nil)
+	req = req.WithContext(context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+		User:  identity.User{OrgAdmin: true, Username: "foobar"},
+		OrgID: "1234",
+	}}))
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	RegistrationRestoreHandler(suite.rec, req)
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusNotFound, status)
+	suite.Equal("{\"message\":\"no deleted registration found to restore\"}", rspBody)
+}
+
+func (suite *RegistrationTestSuite) TestRegistrationCreateExpiresInPast() {
+	body := []byte(`{"uid": "abc1234", "display_name": "foobar", "expires_at": "2001-01-01T00:00:00Z"}`)
+	req := httptest.NewRequest("POST", "http://foobar/registrations", bytes.NewReader(body)).
+		WithContext(context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+			User:  identity.User{OrgAdmin: true, Username: "foobar"},
+			OrgID: "1234",
+		}}))
+	req.Header.Set("x-rh-certauth-cn", "/CN=abc1234")
+
+	RegistrationCreateHandler(suite.rec, req)
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusBadRequest, status)
+	suite.Equal("{\"message\":\"parameter [expires_at] must be in the future\"}", rspBody)
+}
+
+func (suite *RegistrationTestSuite) TestSuccessfulRegistrationCreateWithExpiry() {
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	body := []byte(`{"uid": "abc1234", "display_name": "foobar", "expires_at": "` + expiresAt.Format(time.RFC3339) + `"}`)
+	req := httptest.NewRequest("POST", "http://foobar/registrations", bytes.NewReader(body)).
+		WithContext(context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+			User:  identity.User{OrgAdmin: true, Username: "foobar"},
+			OrgID: "1234",
+		}}))
+	req.Header.Set("x-rh-certauth-cn", "/CN=abc1234")
+
+	RegistrationCreateHandler(suite.rec, req)
+
+	status, _ := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusCreated, status)
+
+	reg, err := suite.store.Find("1234", "abc1234")
+	suite.Nil(err)
+	suite.NotNil(reg.ExpiresAt)
+	suite.True(expiresAt.Equal(*reg.ExpiresAt))
+}
+
+func (suite *RegistrationTestSuite) TestSuccessfulRegistrationRotate() {
+	_, err := suite.store.Create(&store.Registration{UID: "abc1234", OrgID: "1234", DisplayName: "one"})
+	suite.Nil(err)
+
+	req := newRotateRequest("abc1234", []byte(`{"uid": "def5678"}`), true)
+	req.Header.Set(CertHeader, "/CN=def5678")
+
+	RegistrationRotateHandler(suite.rec, req)
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusOK, status)
+
+	var reg registrationResponse
+	suite.Nil(json.Unmarshal([]byte(rspBody), &reg))
+	suite.Equal("def5678", reg.UID)
+	suite.Equal("one", reg.DisplayName)
+
+	_, err = suite.store.Find("1234", "abc1234")
+	suite.ErrorIs(err, store.ErrRegistrationNotFound)
+}
+
+func (suite *RegistrationTestSuite) TestNotOrgAdminRotate() {
+	req := newRotateRequest("abc1234", []byte(`{"uid": "def5678"}`), false)
+	req.Header.Set(CertHeader, "/CN=def5678")
+
+	RegistrationRotateHandler(suite.rec, req)
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusForbidden, status)
+	suite.Equal("{\"message\":\"user must be org admin to rotate registration\"}", rspBody)
+}
+
+func (suite *RegistrationTestSuite) TestNotMatchingCNRotate() {
+	req := newRotateRequest("abc1234", []byte(`{"uid": "def5678"}`), true)
+	req.Header.Set(CertHeader, "/CN=abc1234")
+
+	RegistrationRotateHandler(suite.rec, req)
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusBadRequest, status)
+	suite.Equal("{\"message\":\"x-rh-certauth-cn does not match uid\"}", rspBody)
+}
+
+func (suite *RegistrationTestSuite) TestRegistrationNotFoundRotate() {
+	req := newRotateRequest("abc1234", []byte(`{"uid": "def5678"}`), true)
+	req.Header.Set(CertHeader, "/CN=def5678")
+
+	RegistrationRotateHandler(suite.rec, req)
+
+	status, _ := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusNotFound, status)
+}
+
+func (suite *RegistrationTestSuite) TestExistingUIDRotate() {
+	_, err := suite.store.Create(&store.Registration{UID: "abc1234", OrgID: "1234", DisplayName: "one"})
+	suite.Nil(err)
+	_, err = suite.store.Create(&store.Registration{UID: "def5678", OrgID: "1234", DisplayName: "two"})
+	suite.Nil(err)
+
+	req := newRotateRequest("abc1234", []byte(`{"uid": "def5678"}`), true)
+	req.Header.Set(CertHeader, "/CN=def5678")
+
+	RegistrationRotateHandler(suite.rec, req)
+
+	status, _ := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusConflict, status)
+}
+
+func (suite *RegistrationTestSuite) TestQuotaExceededCreate() {
+	limit := 1
+	suite.Nil(suite.store.SetQuotaOverride(&store.QuotaOverride{OrgID: "1234", Registrations: &limit}))
+	_, err := suite.store.Create(&store.Registration{UID: "def5678", OrgID: "1234", DisplayName: "existing"})
+	suite.Nil(err)
+
+	body := []byte(`{"uid": "abc1234", "display_name": "foobar"}`)
+	req := httptest.NewRequest("POST", "http://foobar/registrations", bytes.NewReader(body)).
+		WithContext(context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+			User:  identity.User{OrgAdmin: true, Username: "foobar"},
+			OrgID: "1234",
+		}}))
+	req.Header.Set("x-rh-certauth-cn", "/CN=abc1234")
+
+	RegistrationCreateHandler(suite.rec, req)
+
+	status, rspBody := statusAndBodyFromReq(suite)
+	suite.Equal(http.StatusTooManyRequests, status)
+	suite.Equal("{\"message\":\"quota exceeded: org is limited to 1 registrations\"}", rspBody)
+}
+
 func statusAndBodyFromReq(suite *RegistrationTestSuite) (int, string) {
 	//nolint:bodyclose
 	rsp := suite.rec.Result()
 	body, _ := io.ReadAll(rsp.Body)
 	return rsp.StatusCode, string(body)
 }
+
+func newUpdateRequest(uid string, body []byte, orgAdmin bool) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("uid", uid)
+
+	req := httptest.NewRequest(http.MethodPatch, "http://foobar/registrations/{uid}", bytes.NewReader(body))
+	req = req.WithContext(context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+		User:  identity.User{OrgAdmin: orgAdmin, Username: "foobar"},
+		OrgID: "1234",
+	}}))
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+func newRotateRequest(uid string, body []byte, orgAdmin bool) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("uid", uid)
+
+	req := httptest.NewRequest(http.MethodPost, "http://foobar/registrations/{uid}/rotate", bytes.NewReader(body))
+	req = req.WithContext(context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+		User:  identity.User{OrgAdmin: orgAdmin, Username: "foobar"},
+		OrgID: "1234",
+	}}))
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/redhatinsights/mbop/internal/config"
 	"github.com/redhatinsights/mbop/internal/models"
+	"github.com/redhatinsights/mbop/internal/service/jwks"
 )
 
 func Status(w http.ResponseWriter, _ *http.Request) {
@@ -16,5 +17,26 @@ func Status(w http.ResponseWriter, _ *http.Request) {
 		},
 	}
 
+	switch config.Get().JwtModule {
+	case awsModule, keycloakModule:
+		if cache, err := jwks.FromConfig(); err == nil {
+			status.JWKS = jwksStatus(cache.Status())
+		}
+	}
+
 	sendJSON(w, status)
 }
+
+func jwksStatus(s jwks.Status) *models.JWKSStatus {
+	out := &models.JWKSStatus{
+		AgeSeconds: int64(s.Age.Seconds()),
+		Stale:      s.Stale,
+	}
+	if !s.FetchedAt.IsZero() {
+		out.FetchedAt = &s.FetchedAt
+	}
+	if s.LastError != nil {
+		out.LastError = s.LastError.Error()
+	}
+	return out
+}
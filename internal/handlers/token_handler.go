@@ -1,11 +1,16 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/redhatinsights/mbop/internal/config"
+	"github.com/redhatinsights/mbop/internal/keyring"
 	"github.com/redhatinsights/mbop/internal/models"
+	"github.com/redhatinsights/mbop/internal/store"
 	"github.com/redhatinsights/platform-go-middlewares/identity"
 )
 
@@ -13,6 +18,9 @@ type TokenResp struct {
 	Token string `json:"token"`
 }
 
+// TokenHandler issues a token for the org admin, optionally for an audience
+// (`?audience=`) and space separated scopes (`?scope=`) out of the ones allowed
+// in the config.
 func TokenHandler(w http.ResponseWriter, r *http.Request) {
 	xrhid := identity.Get(r.Context()).Identity
 	if !xrhid.User.OrgAdmin {
@@ -31,17 +39,40 @@ func TokenHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	c := config.Get()
-	privateKey := []byte(c.PrivateKey)
-	pubKey := []byte(c.PublicKey)
 
-	token := models.Token{PrivateKey: privateKey, PublicKey: pubKey}
+	audience := r.URL.Query().Get("audience")
+	if audience != "" && !stringInSlice(audience, splitList(c.TokenAudiences)) {
+		do400(w, fmt.Sprintf("audience [%s] is not allowed", audience))
+		return
+	}
+
+	scopes := strings.Fields(r.URL.Query().Get("scope"))
+	for _, scope := range scopes {
+		if !stringInSlice(scope, splitList(c.TokenScopes)) {
+			do400(w, fmt.Sprintf("scope [%s] is not allowed", scope))
+			return
+		}
+	}
+
+	ring, err := keyring.Get()
+	if err != nil {
+		do500(w, "Error loading signing keys")
+		return
+	}
+	key, err := ring.Active()
+	if err != nil {
+		do500(w, "No signing key configured")
+		return
+	}
+
+	token := models.Token{Key: key, Issuer: c.TokenIssuer}
 	ttl, err := time.ParseDuration(c.TokenTTL)
 	if err != nil {
 		do500(w, "Error setting TTL")
 		return
 	}
 
-	signedToken, err := token.Create(ttl, xrhid)
+	signedToken, err := token.Create(ttl, xrhid, audience, scopes)
 	if err != nil {
 		do500(w, "Error creating token")
 		return
@@ -49,3 +80,117 @@ func TokenHandler(w http.ResponseWriter, r *http.Request) {
 
 	sendJSON(w, TokenResp{Token: signedToken})
 }
+
+// the RFC 7662 introspection response, only `active` is set for tokens that
+// aren't (or are someone else's)
+type tokenIntrospectResponse struct {
+	Active     bool     `json:"active"`
+	TokenType  string   `json:"token_type,omitempty"`
+	Scope      string   `json:"scope,omitempty"`
+	JTI        string   `json:"jti,omitempty"`
+	Iss        string   `json:"iss,omitempty"`
+	Sub        string   `json:"sub,omitempty"`
+	Aud        []string `json:"aud,omitempty"`
+	Exp        int64    `json:"exp,omitempty"`
+	Iat        int64    `json:"iat,omitempty"`
+	Nbf        int64    `json:"nbf,omitempty"`
+	OrgID      string   `json:"org_id,omitempty"`
+	Username   string   `json:"username,omitempty"`
+	IsOrgAdmin bool     `json:"is_org_admin,omitempty"`
+}
+
+// TokenIntrospectHandler tells consumers whether a token (sent as the form
+// parameter `token`) is still valid, i.e. signed by one of our keys, unexpired
+// and not revoked. Tokens for other orgs are reported as inactive.
+func TokenIntrospectHandler(w http.ResponseWriter, r *http.Request) {
+	xrhid := identity.Get(r.Context()).Identity
+
+	tokenStr := r.PostFormValue("token")
+	if tokenStr == "" {
+		do400(w, "required parameter [token] not found")
+		return
+	}
+
+	ring, err := keyring.Get()
+	if err != nil {
+		do500(w, "Error loading signing keys")
+		return
+	}
+
+	claims, err := models.ParseToken(ring, tokenStr)
+	if err != nil || claims.OrgID != xrhid.OrgID {
+		sendJSON(w, tokenIntrospectResponse{Active: false})
+		return
+	}
+
+	revoked, err := store.GetStore().TokenRevoked(claims.OrgID, claims.ID)
+	if err != nil {
+		do500(w, "error checking token revocation: "+err.Error())
+		return
+	}
+	if revoked {
+		sendJSON(w, tokenIntrospectResponse{Active: false})
+		return
+	}
+
+	out := tokenIntrospectResponse{
+		Active:     true,
+		TokenType:  "Bearer",
+		Scope:      claims.Scope,
+		JTI:        claims.ID,
+		Iss:        claims.Issuer,
+		Sub:        claims.Subject,
+		Aud:        claims.Audience,
+		OrgID:      claims.OrgID,
+		Username:   claims.Username,
+		IsOrgAdmin: claims.IsOrgAdmin,
+	}
+	if claims.ExpiresAt != nil {
+		out.Exp = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		out.Iat = claims.IssuedAt.Unix()
+	}
+	if claims.NotBefore != nil {
+		out.Nbf = claims.NotBefore.Unix()
+	}
+
+	sendJSON(w, out)
+}
+
+// TokenRevokeHandler revokes one of the org's tokens by its jti
+func TokenRevokeHandler(w http.ResponseWriter, r *http.Request) {
+	xrhid := identity.Get(r.Context()).Identity
+	if !xrhid.User.OrgAdmin {
+		doError(w, "user must be org admin to revoke satellite tokens", 403)
+		return
+	}
+
+	jti := chi.URLParam(r, "jti")
+	if jti == "" {
+		do400(w, "need jti in path in the form `/v1/registrations/token/{jti}`")
+		return
+	}
+
+	ttl, err := time.ParseDuration(config.Get().TokenTTL)
+	if err != nil {
+		do500(w, "Error setting TTL")
+		return
+	}
+
+	// we don't keep track of the tokens we've issued, but any with this jti
+	// was issued at most a TTL ago so will have expired by then
+	err = store.GetStore().RevokeToken(&store.TokenRevocation{
+		JTI:       jti,
+		OrgID:     xrhid.OrgID,
+		RevokedBy: xrhid.User.Username,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		do500(w, "error revoking token: "+err.Error())
+		return
+	}
+
+	recordAudit(r, store.AuditTokenRevoke, jti)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redhatinsights/mbop/internal/config"
+	"github.com/redhatinsights/mbop/internal/keyring"
+	"github.com/redhatinsights/mbop/internal/logger"
+	"github.com/redhatinsights/mbop/internal/models"
+	"github.com/redhatinsights/mbop/internal/store"
+	"github.com/redhatinsights/platform-go-middlewares/identity"
+	"github.com/stretchr/testify/suite"
+)
+
+type TokenTestSuite struct {
+	suite.Suite
+	rec   *httptest.ResponseRecorder
+	store store.Store
+	ring  *keyring.Ring
+}
+
+func (suite *TokenTestSuite) SetupSuite() {
+	_ = logger.Init()
+	config.Reset()
+	os.Setenv("STORE_BACKEND", "memory")
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	suite.Nil(err)
+	suite.ring, err = keyring.New([]keyring.Key{
+		{ID: "active", Algorithm: "RS256", Private: key, Public: &key.PublicKey},
+	}, "active")
+	suite.Nil(err)
+}
+
+func (suite *TokenTestSuite) BeforeTest(_, _ string) {
+	suite.rec = httptest.NewRecorder()
+	suite.Nil(store.SetupStore())
+
+	// creating a new store for every test and overriding the dep injection function
+	suite.store = store.GetStore()
+	store.GetStore = func() store.Store { return suite.store }
+	keyring.Get = func() (*keyring.Ring, error) { return suite.ring, nil }
+}
+
+func (suite *TokenTestSuite) AfterTest(_, _ string) {
+	suite.rec.Result().Body.Close()
+}
+
+func TestTokenEndpoints(t *testing.T) {
+	suite.Run(t, new(TokenTestSuite))
+}
+
+func (suite *TokenTestSuite) TestIntrospectActive() {
+	token, claims := suite.newToken("1234", time.Minute)
+
+	TokenIntrospectHandler(suite.rec, newIntrospectRequest(token, "1234"))
+
+	status, body := suite.statusAndBody()
+	suite.Equal(http.StatusOK, status)
+
+	var out tokenIntrospectResponse
+	suite.Nil(json.Unmarshal([]byte(body), &out))
+	suite.True(out.Active)
+	suite.Equal("Bearer", out.TokenType)
+	suite.Equal(claims.ID, out.JTI)
+	suite.Equal("1234", out.OrgID)
+	suite.Equal("foobar", out.Username)
+	suite.Equal(claims.ExpiresAt.Unix(), out.Exp)
+}
+
+func (suite *TokenTestSuite) TestIntrospectExpired() {
+	token, _ := suite.newToken("1234", -time.Minute)
+
+	TokenIntrospectHandler(suite.rec, newIntrospectRequest(token, "1234"))
+
+	status, body := suite.statusAndBody()
+	suite.Equal(http.StatusOK, status)
+	suite.Equal(`{"active":false}`, body)
+}
+
+func (suite *TokenTestSuite) TestIntrospectOtherOrg() {
+	token, _ := suite.newToken("4321", time.Minute)
+
+	TokenIntrospectHandler(suite.rec, newIntrospectRequest(token, "1234"))
+
+	_, body := suite.statusAndBody()
+	suite.Equal(`{"active":false}`, body)
+}
+
+func (suite *TokenTestSuite) TestIntrospectGarbage() {
+	TokenIntrospectHandler(suite.rec, newIntrospectRequest("not.a.token", "1234"))
+
+	_, body := suite.statusAndBody()
+	suite.Equal(`{"active":false}`, body)
+}
+
+func (suite *TokenTestSuite) TestIntrospectNoToken() {
+	TokenIntrospectHandler(suite.rec, newIntrospectRequest("", "1234"))
+
+	status, body := suite.statusAndBody()
+	suite.Equal(http.StatusBadRequest, status)
+	suite.Equal(`{"message":"required parameter [token] not found"}`, body)
+}
+
+func (suite *TokenTestSuite) TestRevoke() {
+	token, claims := suite.newToken("1234", time.Minute)
+
+	TokenRevokeHandler(suite.rec, newRevokeRequest(claims.ID, true))
+
+	status, _ := suite.statusAndBody()
+	suite.Equal(http.StatusNoContent, status)
+
+	revoked, err := suite.store.TokenRevoked("1234", claims.ID)
+	suite.Nil(err)
+	suite.True(revoked)
+
+	suite.rec = httptest.NewRecorder()
+	TokenIntrospectHandler(suite.rec, newIntrospectRequest(token, "1234"))
+
+	_, body := suite.statusAndBody()
+	suite.Equal(`{"active":false}`, body)
+
+	entries, _, err := suite.store.AuditEntries("1234", 10, 0)
+	suite.Nil(err)
+	suite.Equal(store.AuditTokenRevoke, entries[0].Action)
+	suite.Equal(claims.ID, entries[0].Target)
+}
+
+func (suite *TokenTestSuite) TestNotOrgAdminRevoke() {
+	TokenRevokeHandler(suite.rec, newRevokeRequest("abc", false))
+
+	status, body := suite.statusAndBody()
+	suite.Equal(http.StatusForbidden, status)
+	suite.Equal(`{"message":"user must be org admin to revoke satellite tokens"}`, body)
+}
+
+func (suite *TokenTestSuite) TestTokenClaims() {
+	config.Get().TokenAudiences = "satellite, other"
+	config.Get().TokenScopes = "read,write"
+	defer func() { config.Get().TokenAudiences, config.Get().TokenScopes = "", "" }()
+
+	TokenHandler(suite.rec, newTokenRequest("audience=satellite&scope=read+write"))
+
+	status, body := suite.statusAndBody()
+	suite.Equal(http.StatusOK, status)
+
+	var rsp TokenResp
+	suite.Nil(json.Unmarshal([]byte(body), &rsp))
+
+	claims, err := models.ParseToken(suite.ring, rsp.Token, jwt.WithAudience("satellite"), jwt.WithIssuer("mbop"))
+	suite.Nil(err)
+	suite.Equal("foobar", claims.Subject)
+	suite.Equal([]string{"read", "write"}, claims.Scopes())
+	suite.NotEmpty(claims.ID)
+
+	// and the audience is pinned
+	_, err = models.ParseToken(suite.ring, rsp.Token, jwt.WithAudience("other"))
+	suite.NotNil(err)
+
+	suite.rec = httptest.NewRecorder()
+	TokenIntrospectHandler(suite.rec, newIntrospectRequest(rsp.Token, "1234"))
+
+	_, body = suite.statusAndBody()
+	var out tokenIntrospectResponse
+	suite.Nil(json.Unmarshal([]byte(body), &out))
+	suite.Equal([]string{"satellite"}, out.Aud)
+	suite.Equal("read write", out.Scope)
+	suite.Equal("mbop", out.Iss)
+	suite.Equal("foobar", out.Sub)
+}
+
+func (suite *TokenTestSuite) TestTokenAudienceNotAllowed() {
+	config.Get().TokenAudiences = "satellite"
+	defer func() { config.Get().TokenAudiences = "" }()
+
+	TokenHandler(suite.rec, newTokenRequest("audience=elsewhere"))
+
+	status, body := suite.statusAndBody()
+	suite.Equal(http.StatusBadRequest, status)
+	suite.Equal(`{"message":"audience [elsewhere] is not allowed"}`, body)
+}
+
+func (suite *TokenTestSuite) TestTokenScopeNotAllowed() {
+	config.Get().TokenScopes = "read"
+	defer func() { config.Get().TokenScopes = "" }()
+
+	TokenHandler(suite.rec, newTokenRequest("scope=read+admin"))
+
+	status, body := suite.statusAndBody()
+	suite.Equal(http.StatusBadRequest, status)
+	suite.Equal(`{"message":"scope [admin] is not allowed"}`, body)
+}
+
+func (suite *TokenTestSuite) newToken(orgID string, ttl time.Duration) (string, *models.TokenClaims) {
+	key, err := suite.ring.Active()
+	suite.Nil(err)
+
+	token, err := models.Token{Key: key, Issuer: "mbop"}.Create(ttl, identity.Identity{
+		OrgID: orgID,
+		User:  identity.User{Username: "foobar", OrgAdmin: true},
+	}, "", nil)
+	suite.Nil(err)
+
+	// pulling the claims back out without validating, as the token may have expired
+	var claims models.TokenClaims
+	_, _, err = jwt.NewParser().ParseUnverified(token, &claims)
+	suite.Nil(err)
+
+	return token, &claims
+}
+
+func (suite *TokenTestSuite) statusAndBody() (int, string) {
+	//nolint:bodyclose
+	rsp := suite.rec.Result()
+	b, err := io.ReadAll(rsp.Body)
+	suite.Nil(err)
+	return rsp.StatusCode, string(b)
+}
+
+func newIntrospectRequest(token, orgID string) *http.Request {
+	form := url.Values{}
+	if token != "" {
+		form.Set("token", token)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "http://foobar/v1/registrations/token/introspect", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req.WithContext(context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+		User:  identity.User{Username: "foobar"},
+		OrgID: orgID,
+	}}))
+}
+
+func newRevokeRequest(jti string, orgAdmin bool) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("jti", jti)
+
+	ctx := context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+		User:  identity.User{OrgAdmin: orgAdmin, Username: "foobar"},
+		OrgID: "1234",
+	}})
+	return httptest.NewRequest(http.MethodDelete, "http://foobar/v1/registrations/token/{jti}", nil).
+		WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+}
+
+func newTokenRequest(query string) *http.Request {
+	return httptest.NewRequest(http.MethodGet, "http://foobar/v1/registrations/token?"+query, nil).
+		WithContext(context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{
+			User:  identity.User{OrgAdmin: true, Username: "foobar"},
+			OrgID: "1234",
+		}}))
+}
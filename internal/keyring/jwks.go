@@ -0,0 +1,59 @@
+package keyring
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+)
+
+// JWK is the public half of a key as a RFC 7517 json web key
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is the key's public half as a json web key
+func (k *Key) JWK() JWK {
+	jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Algorithm}
+
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encodeInt(pub.N)
+		jwk.E = encodeInt(big.NewInt(int64(pub.E)))
+	case *ecdsa.PublicKey:
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = encodeCoord(pub.X, pub.Curve)
+		jwk.Y = encodeCoord(pub.Y, pub.Curve)
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+
+	return jwk
+}
+
+// JWKS is every public key in the ring, including the ones only kept around
+// to verify tokens signed before a rotation
+func (r *Ring) JWKS() JWKS {
+	out := JWKS{Keys: make([]JWK, len(r.keys))}
+	for i := range r.keys {
+		out.Keys[i] = r.keys[i].JWK()
+	}
+	return out
+}
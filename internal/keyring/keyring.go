@@ -0,0 +1,363 @@
+package keyring
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redhatinsights/mbop/internal/config"
+)
+
+var (
+	ErrNoSigningKey = errors.New("no token signing key configured")
+	ErrUnknownKey   = errors.New("unknown kid")
+)
+
+/*
+Key is one of the keys tokens are signed/verified with:
+- ID; the kid tokens signed with it carry in their header
+- Private; only set for keys that can still sign, keys being rotated out only
+have their public half
+*/
+type Key struct {
+	ID        string
+	Algorithm string
+	Private   crypto.Signer
+	Public    crypto.PublicKey
+}
+
+// SigningMethod is the jwt signing method for the key's algorithm
+func (k *Key) SigningMethod() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// Ring holds every key tokens may have been signed with, and which one new
+// tokens are signed with.
+type Ring struct {
+	keys   []Key
+	active string
+}
+
+// New builds a ring from keys, signing with the one with the active kid
+func New(keys []Key, active string) (*Ring, error) {
+	r := &Ring{keys: keys, active: active}
+	sort.Slice(r.keys, func(i, j int) bool { return r.keys[i].ID < r.keys[j].ID })
+
+	for i := 1; i < len(r.keys); i++ {
+		if r.keys[i].ID == r.keys[i-1].ID {
+			return nil, fmt.Errorf("duplicate kid %q", r.keys[i].ID)
+		}
+	}
+
+	if active != "" {
+		k, ok := r.Key(active)
+		if !ok {
+			return nil, fmt.Errorf("active kid %q not found", active)
+		}
+		if k.Private == nil {
+			return nil, fmt.Errorf("active kid %q has no private key to sign with", active)
+		}
+	}
+
+	return r, nil
+}
+
+// Active is the key new tokens are signed with
+func (r *Ring) Active() (*Key, error) {
+	if r.active == "" {
+		return nil, ErrNoSigningKey
+	}
+	k, _ := r.Key(r.active)
+	return k, nil
+}
+
+// Key finds the key with the kid
+func (r *Ring) Key(kid string) (*Key, bool) {
+	for i := range r.keys {
+		if r.keys[i].ID == kid {
+			return &r.keys[i], true
+		}
+	}
+	return nil, false
+}
+
+// Keyfunc finds the key a token was signed with for jwt.Parse, making sure
+// it was signed with that key's algorithm
+func (r *Ring) Keyfunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	k, ok := r.Key(kid)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, kid)
+	}
+	if t.Method.Alg() != k.Algorithm {
+		return nil, fmt.Errorf("kid %q is a %s key, token signed with %s", kid, k.Algorithm, t.Method.Alg())
+	}
+
+	return k.Public, nil
+}
+
+// Algorithms is every algorithm the keys in the ring sign with
+func (r *Ring) Algorithms() []string {
+	algs := make([]string, 0)
+	seen := make(map[string]bool)
+	for i := range r.keys {
+		if !seen[r.keys[i].Algorithm] {
+			seen[r.keys[i].Algorithm] = true
+			algs = append(algs, r.keys[i].Algorithm)
+		}
+	}
+	return algs
+}
+
+// Keys is every key in the ring, sorted by kid
+func (r *Ring) Keys() []Key {
+	return r.keys
+}
+
+var (
+	ring    *Ring
+	ringErr error
+	once    sync.Once
+)
+
+// Get returns the ring loaded from the config, loaded once on first use.
+// Overridden in tests the same way as store.GetStore.
+var Get = func() (*Ring, error) {
+	once.Do(func() {
+		ring, ringErr = Load()
+	})
+	return ring, ringErr
+}
+
+/*
+Load builds the ring from the config, either:
+  - TOKEN_KEY_DIR; a directory of pem files named `<kid>.pem`, holding either a
+    private key, or just a public key for one that's being rotated out. The
+    active key is picked with TOKEN_KID, which can be left out if there's only
+    the one private key
+  - TOKEN_PRIVATE_KEY/TOKEN_KID; a single signing key, with the previous key
+    optionally still published through TOKEN_PREVIOUS_PUBLIC_KEY and
+    TOKEN_PREVIOUS_KID while tokens signed by it are still around. Without a
+    private key, TOKEN_PUBLIC_KEY is loaded so tokens can still be verified
+
+A kid that isn't configured is the key's RFC 7638 thumbprint.
+*/
+func Load() (*Ring, error) {
+	c := config.Get()
+	if c.TokenKeyDir != "" {
+		return loadDir(c.TokenKeyDir, c.TokenKID)
+	}
+
+	var keys []Key
+	switch {
+	case c.PrivateKey != "":
+		k, err := parseKey(c.TokenKID, []byte(c.PrivateKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid TOKEN_PRIVATE_KEY: %w", err)
+		}
+		keys = append(keys, *k)
+	case c.PublicKey != "":
+		// only verifying tokens signed elsewhere
+		k, err := parseKey(c.TokenKID, []byte(c.PublicKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid TOKEN_PUBLIC_KEY: %w", err)
+		}
+		keys = append(keys, *k)
+	}
+	if c.TokenPreviousPublicKey != "" {
+		k, err := parseKey(c.TokenPreviousKID, []byte(c.TokenPreviousPublicKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid TOKEN_PREVIOUS_PUBLIC_KEY: %w", err)
+		}
+		keys = append(keys, *k)
+	}
+
+	var active string
+	if len(keys) > 0 && keys[0].Private != nil {
+		active = keys[0].ID
+	}
+
+	return New(keys, active)
+}
+
+func loadDir(dir, active string) (*Ring, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key directory: %w", err)
+	}
+
+	var keys []Key
+	for _, f := range files {
+		// skipping the hidden entries kubernetes puts in mounted secrets
+		if f.IsDir() || strings.HasPrefix(f.Name(), ".") || filepath.Ext(f.Name()) != ".pem" {
+			continue
+		}
+
+		b, err := os.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key %s: %w", f.Name(), err)
+		}
+
+		k, err := parseKey(strings.TrimSuffix(f.Name(), ".pem"), b)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s: %w", f.Name(), err)
+		}
+		keys = append(keys, *k)
+	}
+
+	if active == "" {
+		for i := range keys {
+			if keys[i].Private == nil {
+				continue
+			}
+			if active != "" {
+				return nil, errors.New("more than one private key, need TOKEN_KID set to pick which one signs")
+			}
+			active = keys[i].ID
+		}
+	}
+
+	return New(keys, active)
+}
+
+// parses a pem encoded private or public key
+func parseKey(kid string, b []byte) (*Key, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("no pem block found")
+	}
+
+	k := &Key{ID: kid}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		k.Private = priv
+	case "PRIVATE KEY":
+		priv, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := priv.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported private key")
+		}
+		k.Private = signer
+	case "EC PRIVATE KEY":
+		priv, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		k.Private = priv
+	case "RSA PUBLIC KEY":
+		pub, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		k.Public = pub
+	case "PUBLIC KEY":
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		k.Public = pub
+	default:
+		return nil, fmt.Errorf("unsupported pem block %q", block.Type)
+	}
+
+	if k.Private != nil {
+		k.Public = k.Private.Public()
+	}
+
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		k.Algorithm = "RS256"
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("unsupported curve %s, only P-256 (ES256) keys are supported", pub.Curve.Params().Name)
+		}
+		k.Algorithm = "ES256"
+	case ed25519.PublicKey:
+		k.Algorithm = "EdDSA"
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", k.Public)
+	}
+
+	if k.ID == "" {
+		thumbprint, err := Thumbprint(k.Public)
+		if err != nil {
+			return nil, err
+		}
+		k.ID = thumbprint
+	}
+
+	return k, nil
+}
+
+// Thumbprint is the RFC 7638 thumbprint of a public key
+func Thumbprint(pub crypto.PublicKey) (string, error) {
+	var members interface{}
+
+	switch pub := pub.(type) {
+	// only the required members, which encoding/json writes in the
+	// lexicographic order the thumbprint needs
+	case *rsa.PublicKey:
+		members = map[string]string{
+			"e":   encodeInt(big.NewInt(int64(pub.E))),
+			"kty": "RSA",
+			"n":   encodeInt(pub.N),
+		}
+	case *ecdsa.PublicKey:
+		members = map[string]string{
+			"crv": pub.Curve.Params().Name,
+			"kty": "EC",
+			"x":   encodeCoord(pub.X, pub.Curve),
+			"y":   encodeCoord(pub.Y, pub.Curve),
+		}
+	case ed25519.PublicKey:
+		members = map[string]string{
+			"crv": "Ed25519",
+			"kty": "OKP",
+			"x":   base64.RawURLEncoding.EncodeToString(pub),
+		}
+	default:
+		return "", fmt.Errorf("unsupported key type %T", pub)
+	}
+
+	b, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+func encodeInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+// elliptic curve coordinates are padded out to the curve's size
+func encodeCoord(i *big.Int, curve elliptic.Curve) string {
+	b := make([]byte, (curve.Params().BitSize+7)/8)
+	return base64.RawURLEncoding.EncodeToString(i.FillBytes(b))
+}
@@ -0,0 +1,261 @@
+package keyring
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redhatinsights/mbop/internal/config"
+)
+
+func TestThumbprint(t *testing.T) {
+	// the example key from RFC 7638 section 3.1
+	n, _ := new(big.Int).SetString("26634547600177008912365441464036882611104634136430581696102639463075266436216946316053845642300166320042915031924501272705275043130211783228252369194856949397782880847235143381529207382262647906987655738647387007320361149854766523417293323739185308113373529512728932838100141612048712597178695720651344295450174895369923383396704334331627261565907266749863744707920606364678231639106403854977302183719246256958550651555767664134467706614553219592981545363271425781391262006405169505726523023628770285432062044391310047445749287563161668548354322560223509946990827691654627968182167826397015368836435965354956581554819", 10)
+	pub := &rsa.PublicKey{N: n, E: 65537}
+
+	thumbprint, err := Thumbprint(pub)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if thumbprint != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" {
+		t.Errorf("unexpected thumbprint %q", thumbprint)
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "new.pem", privatePEM(t))
+	writeKey(t, dir, "old.pem", publicPEM(t))
+	writeKey(t, dir, ".hidden.pem", []byte("not a key"))
+	writeKey(t, dir, "README", []byte("not a key"))
+
+	ring, err := loadDir(dir, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(ring.Keys()) != 2 {
+		t.Fatalf("expected 2 keys, got %d", len(ring.Keys()))
+	}
+	active, err := ring.Active()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if active.ID != "new" || active.Algorithm != "RS256" {
+		t.Errorf("unexpected active key %s/%s", active.ID, active.Algorithm)
+	}
+
+	jwks := ring.JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].Kid != "new" || jwks.Keys[1].Kid != "old" || jwks.Keys[1].N == "" {
+		t.Errorf("unexpected jwks %+v", jwks)
+	}
+
+	// a public key can't be the one that signs
+	if _, err := loadDir(dir, "old"); err == nil {
+		t.Error("expected error activating a public key")
+	}
+	if _, err := loadDir(dir, "missing"); err == nil {
+		t.Error("expected error activating a missing key")
+	}
+}
+
+func TestLoadDirNeedsActive(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "one.pem", privatePEM(t))
+	writeKey(t, dir, "two.pem", privatePEM(t))
+
+	if _, err := loadDir(dir, ""); err == nil {
+		t.Error("expected error with two private keys and no active kid")
+	}
+
+	ring, err := loadDir(dir, "two")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	active, _ := ring.Active()
+	if active.ID != "two" {
+		t.Errorf("unexpected active key %s", active.ID)
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	c := config.Get()
+	defer func(priv, kid, prevPub, prevKID string) {
+		c.PrivateKey, c.TokenKID, c.TokenPreviousPublicKey, c.TokenPreviousKID = priv, kid, prevPub, prevKID
+	}(c.PrivateKey, c.TokenKID, c.TokenPreviousPublicKey, c.TokenPreviousKID)
+
+	c.PrivateKey = string(privatePEM(t))
+	c.TokenKID = ""
+	c.TokenPreviousPublicKey = string(publicPEM(t))
+	c.TokenPreviousKID = "previous"
+
+	ring, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	active, err := ring.Active()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	thumbprint, _ := Thumbprint(active.Public)
+	if active.ID != thumbprint {
+		t.Errorf("expected kid to default to the thumbprint, got %q", active.ID)
+	}
+	if _, ok := ring.Key("previous"); !ok {
+		t.Error("expected previous key in the ring")
+	}
+}
+
+func TestLoadPublicOnly(t *testing.T) {
+	c := config.Get()
+	defer func(priv, pub, kid string) {
+		c.PrivateKey, c.PublicKey, c.TokenKID = priv, pub, kid
+	}(c.PrivateKey, c.PublicKey, c.TokenKID)
+
+	c.PrivateKey = ""
+	c.PublicKey = string(publicPEM(t))
+	c.TokenKID = "verify"
+
+	ring, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := ring.Key("verify"); !ok {
+		t.Error("expected the public key in the ring")
+	}
+	if _, err := ring.Active(); err != ErrNoSigningKey {
+		t.Errorf("expected ErrNoSigningKey, got %v", err)
+	}
+}
+
+func TestNoSigningKey(t *testing.T) {
+	ring, err := New(nil, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := ring.Active(); err != ErrNoSigningKey {
+		t.Errorf("expected ErrNoSigningKey, got %v", err)
+	}
+}
+
+func TestLoadOtherAlgorithms(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	cases := map[string]struct {
+		key interface{}
+		alg string
+		kty string
+	}{
+		"es256": {ecKey, "ES256", "EC"},
+		"eddsa": {edKey, "EdDSA", "OKP"},
+	}
+
+	for kid, c := range cases {
+		b, err := x509.MarshalPKCS8PrivateKey(c.key)
+		if err != nil {
+			t.Fatalf("failed to marshal key: %v", err)
+		}
+
+		k, err := parseKey(kid, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b}))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", kid, err)
+		}
+		if k.Algorithm != c.alg {
+			t.Errorf("%s: expected algorithm %s, got %s", kid, c.alg, k.Algorithm)
+		}
+
+		jwk := k.JWK()
+		if jwk.Kty != c.kty || jwk.X == "" {
+			t.Errorf("%s: unexpected jwk %+v", kid, jwk)
+		}
+
+		// signing and verifying through the ring
+		ring, err := New([]Key{*k}, kid)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", kid, err)
+		}
+		token := jwt.NewWithClaims(k.SigningMethod(), jwt.RegisteredClaims{Subject: "foobar"})
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(k.Private)
+		if err != nil {
+			t.Fatalf("%s: failed to sign: %v", kid, err)
+		}
+		if _, err := jwt.Parse(signed, ring.Keyfunc, jwt.WithValidMethods(ring.Algorithms())); err != nil {
+			t.Errorf("%s: failed to verify: %v", kid, err)
+		}
+	}
+
+	b, err := x509.MarshalECPrivateKey(p384Key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	if _, err := parseKey("p384", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b})); err == nil {
+		t.Error("expected error for a P-384 key")
+	}
+}
+
+func TestKeyfuncAlgorithmMismatch(t *testing.T) {
+	k := generateKey(t)
+	ring, err := New([]Key{{ID: "rsa", Algorithm: "RS256", Private: k, Public: &k.PublicKey}}, "rsa")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// an HMAC token using the kid of an RSA key
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{})
+	token.Header["kid"] = "rsa"
+	signed, err := token.SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	if _, err := jwt.Parse(signed, ring.Keyfunc); err == nil {
+		t.Error("expected error verifying a token signed with the wrong algorithm")
+	}
+}
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return k
+}
+
+func privatePEM(t *testing.T) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(generateKey(t))})
+}
+
+func publicPEM(t *testing.T) []byte {
+	b, err := x509.MarshalPKIXPublicKey(&generateKey(t).PublicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: b})
+}
+
+func writeKey(t *testing.T, dir, name string, b []byte) {
+	if err := os.WriteFile(filepath.Join(dir, name), b, 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+}
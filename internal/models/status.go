@@ -1,9 +1,22 @@
 package models
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Status struct {
 	ConfiguredModules ConfiguredModules `json:"configured_modules"`
+	JWKS              *JWKSStatus       `json:"jwks,omitempty"`
+}
+
+// JWKSStatus is how fresh the cached keys from JWK_URL are, only there for the
+// jwt modules that fetch them
+type JWKSStatus struct {
+	FetchedAt  *time.Time `json:"fetched_at"`
+	AgeSeconds int64      `json:"age_seconds"`
+	Stale      bool       `json:"stale"`
+	LastError  string     `json:"last_error,omitempty"`
 }
 
 type ConfiguredModules struct {
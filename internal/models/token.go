@@ -2,40 +2,86 @@ package models
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/redhatinsights/mbop/internal/config"
+	"github.com/google/uuid"
+	"github.com/redhatinsights/mbop/internal/keyring"
 	"github.com/redhatinsights/platform-go-middlewares/identity"
 )
 
 type Token struct {
-	PublicKey  []byte `json:"public_key"`
-	PrivateKey []byte `json:"private_key"`
+	Key    *keyring.Key
+	Issuer string
 }
 
-func (t Token) Create(ttl time.Duration, xrhid identity.Identity) (string, error) {
-	key, err := jwt.ParseRSAPrivateKeyFromPEM(t.PrivateKey)
-	if err != nil {
-		fmt.Println(string(t.PrivateKey))
-		return "", fmt.Errorf("Failed to parse key: %w", err)
+// TokenClaims are the claims in the tokens mbop issues, the jti is what
+// they're revoked by. Scope is space separated as in RFC 8693.
+type TokenClaims struct {
+	OrgID      string `json:"org_id"`
+	Username   string `json:"username"`
+	IsOrgAdmin bool   `json:"is_org_admin"`
+	Scope      string `json:"scope,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Scopes is the scope claim split back out
+func (c *TokenClaims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// Create signs a token for the identity, the audience and scopes are optional
+func (t Token) Create(ttl time.Duration, xrhid identity.Identity, audience string, scopes []string) (string, error) {
+	if t.Key == nil || t.Key.Private == nil {
+		return "", fmt.Errorf("Failed to sign token: %w", keyring.ErrNoSigningKey)
+	}
+
+	// the user id is the stable identifier if we have it
+	sub := xrhid.User.UserID
+	if sub == "" {
+		sub = xrhid.User.Username
 	}
 
 	now := time.Now().UTC()
-	claims := make(jwt.MapClaims)
-	claims["exp"] = now.Add(ttl).Unix()
-	claims["iat"] = now.Unix()
-	claims["nbf"] = now.Unix()
-	claims["org_id"] = xrhid.OrgID
-	claims["username"] = xrhid.User.Username
-	claims["is_org_admin"] = xrhid.User.OrgAdmin
-
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	token.Header["kid"] = config.Get().TokenKID
-	tokenStr, err := token.SignedString(key)
+	claims := TokenClaims{
+		OrgID:      xrhid.OrgID,
+		Username:   xrhid.User.Username,
+		IsOrgAdmin: xrhid.User.OrgAdmin,
+		Scope:      strings.Join(scopes, " "),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    t.Issuer,
+			Subject:   sub,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+	if audience != "" {
+		claims.Audience = jwt.ClaimStrings{audience}
+	}
+
+	token := jwt.NewWithClaims(t.Key.SigningMethod(), claims)
+	token.Header["kid"] = t.Key.ID
+	tokenStr, err := token.SignedString(t.Key.Private)
 	if err != nil {
 		return "", fmt.Errorf("Failed to sign token: %w", err)
 	}
 
 	return tokenStr, nil
 }
+
+// ParseToken verifies a token against the keys in the ring, checking that
+// it's unexpired and not being used before its nbf, along with anything
+// extra in opts (e.g. the audience)
+func ParseToken(ring *keyring.Ring, tokenStr string, opts ...jwt.ParserOption) (*TokenClaims, error) {
+	var claims TokenClaims
+	opts = append(opts, jwt.WithValidMethods(ring.Algorithms()))
+	_, err := jwt.ParseWithClaims(tokenStr, &claims, ring.Keyfunc, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &claims, nil
+}
@@ -2,6 +2,8 @@ package models
 
 type Users struct {
 	Users []User `json:"users,omitempty"`
+	// how many users matched, across every page
+	Total int `json:"total,omitempty"`
 }
 
 type UserV3Responses struct {
@@ -94,10 +96,6 @@ func (u *Users) AddUser(user User) {
 	u.Users = append(u.Users, user)
 }
 
-func (u *Users) RemoveUser(index int) {
-	u.Users = append(u.Users[:index], u.Users[index+1:]...)
-}
-
 func (r *UserV3Responses) AddV3Response(response UserV3Response) {
 	r.Responses = append(r.Responses, response)
 }
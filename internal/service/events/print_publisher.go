@@ -0,0 +1,22 @@
+package events
+
+import (
+	"context"
+	"fmt"
+)
+
+// this is the default publisher - it just prints the event to stdout (not logging it)
+type printPublisher struct{}
+
+var _ = (Publisher)(&printPublisher{})
+
+func (p printPublisher) Publish(_ context.Context, e *Event) error {
+	fmt.Printf(`Event: %v
+OrgID: %v
+UID: %v
+IPBlock: %v
+Actor: %v
+`, e.Type, e.OrgID, e.UID, e.IPBlock, e.Actor)
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/redhatinsights/mbop/internal/config"
+)
+
+// the types of events published
+const (
+	RegistrationCreated = "registration.created"
+	RegistrationDeleted = "registration.deleted"
+	AllowlistChanged    = "allowlist.changed"
+)
+
+/*
+Event is a change downstream services can react to:
+- OrgID; the org the change was made in
+- UID; the registration's uid, empty for allowlist changes
+- IPBlock; the block that was added/removed for allowlist changes
+- Actor; the username that made the change
+*/
+type Event struct {
+	Type      string    `json:"type"`
+	OrgID     string    `json:"org_id"`
+	UID       string    `json:"uid,omitempty"`
+	IPBlock   string    `json:"ip_block,omitempty"`
+	Actor     string    `json:"actor"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, e *Event) error
+}
+
+func NewPublisher() (Publisher, error) {
+	var pub Publisher
+
+	c := config.Get()
+	switch c.EventsModule {
+	case "webhook":
+		if c.EventsWebhookURL == "" {
+			return nil, errors.New("webhook events module needs EVENTS_WEBHOOK_URL set")
+		}
+
+		backoff, err := time.ParseDuration(c.EventsRetryBackoff)
+		if err != nil {
+			return nil, fmt.Errorf("invalid events retry backoff: %w", err)
+		}
+
+		pub = &webhookPublisher{
+			url:        c.EventsWebhookURL,
+			client:     &http.Client{Timeout: 10 * time.Second},
+			maxRetries: c.EventsMaxRetries,
+			backoff:    backoff,
+		}
+	case "print":
+		pub = &printPublisher{}
+	default:
+		return nil, fmt.Errorf("unsupported events module %q", c.EventsModule)
+	}
+
+	return pub, nil
+}
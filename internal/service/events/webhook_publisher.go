@@ -0,0 +1,84 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	l "github.com/redhatinsights/mbop/internal/logger"
+)
+
+// posts each event as json to the configured url, retrying failed deliveries
+// with an exponential backoff
+type webhookPublisher struct {
+	url        string
+	client     *http.Client
+	maxRetries int
+	backoff    time.Duration
+}
+
+var _ = (Publisher)(&webhookPublisher{})
+
+// error for a delivery that failed in a way that retrying won't fix
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (w *webhookPublisher) Publish(ctx context.Context, e *Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	wait := w.backoff
+	for attempt := 0; ; attempt++ {
+		err = w.deliver(ctx, body)
+		if err == nil {
+			return nil
+		}
+
+		if _, ok := err.(permanentError); ok || attempt >= w.maxRetries {
+			return fmt.Errorf("failed to deliver %s event after %d attempt(s): %w", e.Type, attempt+1, err)
+		}
+
+		l.Log.Info("failed to deliver event, retrying", "type", e.Type, "attempt", attempt+1, "wait", wait, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+func (w *webhookPublisher) deliver(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	// the receiver is overloaded or having issues, worth trying again
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	default:
+		return permanentError{err: fmt.Errorf("webhook returned status %d", resp.StatusCode)}
+	}
+}
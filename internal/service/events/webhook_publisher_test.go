@@ -0,0 +1,119 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/redhatinsights/mbop/internal/config"
+	"github.com/redhatinsights/mbop/internal/logger"
+	"github.com/stretchr/testify/suite"
+)
+
+type WebhookTestSuite struct {
+	suite.Suite
+	calls  int
+	status []int
+	events []Event
+	server *httptest.Server
+}
+
+func TestWebhookPublisher(t *testing.T) {
+	suite.Run(t, new(WebhookTestSuite))
+}
+
+func (suite *WebhookTestSuite) SetupSuite() {
+	_ = logger.Init()
+}
+
+func (suite *WebhookTestSuite) BeforeTest(_, _ string) {
+	suite.calls = 0
+	suite.events = nil
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e Event
+		suite.Nil(json.NewDecoder(r.Body).Decode(&e))
+		suite.events = append(suite.events, e)
+
+		status := http.StatusOK
+		if suite.calls < len(suite.status) {
+			status = suite.status[suite.calls]
+		}
+		suite.calls++
+		w.WriteHeader(status)
+	}))
+}
+
+func (suite *WebhookTestSuite) AfterTest(_, _ string) {
+	suite.server.Close()
+	config.Reset()
+}
+
+func (suite *WebhookTestSuite) newPublisher() *webhookPublisher {
+	return &webhookPublisher{
+		url:        suite.server.URL,
+		client:     suite.server.Client(),
+		maxRetries: 2,
+		backoff:    time.Millisecond,
+	}
+}
+
+func (suite *WebhookTestSuite) TestPublish() {
+	suite.status = nil
+
+	err := suite.newPublisher().Publish(context.Background(), &Event{Type: RegistrationCreated, OrgID: "1234", UID: "abc", Actor: "foobar"})
+	suite.Nil(err)
+	suite.Equal(1, suite.calls)
+	suite.Equal(RegistrationCreated, suite.events[0].Type)
+	suite.Equal("1234", suite.events[0].OrgID)
+	suite.Equal("abc", suite.events[0].UID)
+	suite.Equal("foobar", suite.events[0].Actor)
+}
+
+func (suite *WebhookTestSuite) TestPublishRetries() {
+	suite.status = []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}
+
+	err := suite.newPublisher().Publish(context.Background(), &Event{Type: RegistrationDeleted})
+	suite.Nil(err)
+	suite.Equal(3, suite.calls)
+}
+
+func (suite *WebhookTestSuite) TestPublishGivesUp() {
+	suite.status = []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusOK}
+
+	err := suite.newPublisher().Publish(context.Background(), &Event{Type: RegistrationDeleted})
+	suite.Error(err)
+	suite.Equal(3, suite.calls)
+}
+
+func (suite *WebhookTestSuite) TestPublishNoRetryOnClientError() {
+	suite.status = []int{http.StatusBadRequest}
+
+	err := suite.newPublisher().Publish(context.Background(), &Event{Type: AllowlistChanged})
+	suite.Error(err)
+	suite.Equal(1, suite.calls)
+}
+
+func (suite *WebhookTestSuite) TestNewPublisherWebhook() {
+	os.Setenv("EVENTS_MODULE", "webhook")
+	os.Setenv("EVENTS_WEBHOOK_URL", suite.server.URL)
+	defer os.Unsetenv("EVENTS_MODULE")
+	defer os.Unsetenv("EVENTS_WEBHOOK_URL")
+	config.Reset()
+
+	pub, err := NewPublisher()
+	suite.Nil(err)
+	suite.IsType(&webhookPublisher{}, pub)
+}
+
+func (suite *WebhookTestSuite) TestNewPublisherWebhookNoURL() {
+	os.Setenv("EVENTS_MODULE", "webhook")
+	defer os.Unsetenv("EVENTS_MODULE")
+	config.Reset()
+
+	_, err := NewPublisher()
+	suite.Error(err)
+}
@@ -0,0 +1,307 @@
+package jwks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/jwk2pem"
+	"github.com/redhatinsights/mbop/internal/config"
+	l "github.com/redhatinsights/mbop/internal/logger"
+)
+
+var ErrKeyNotFound = errors.New("no JWK for kid")
+
+/*
+Cache keeps the keys from a JWK endpoint around, so a slow or failing
+endpoint doesn't hold up every request for a key:
+  - keys are kept for the endpoint's Cache-Control max-age, falling back to
+    the configured TTL when there isn't one
+  - Start refreshes them in the background before they expire
+  - when a refresh fails the keys already fetched keep being served (stale)
+    until one succeeds, without trying again more than once per minRefresh
+  - asking for a kid that isn't in the set forces a refresh, in case the
+    endpoint has rotated keys, at most once per minRefresh
+*/
+type Cache struct {
+	url        string
+	client     *http.Client
+	ttl        time.Duration
+	minRefresh time.Duration
+
+	// only the one fetch at a time
+	fetchMu sync.Mutex
+
+	mu          sync.RWMutex
+	keys        *jwk2pem.JWKeys
+	fetchedAt   time.Time
+	expiresAt   time.Time
+	lastErr     error
+	lastForced  time.Time
+	lastAttempt time.Time
+}
+
+// Status is how fresh the cached keys are
+type Status struct {
+	FetchedAt time.Time
+	Age       time.Duration
+	Stale     bool
+	LastError error
+}
+
+func New(url string, ttl, minRefresh, timeout time.Duration) *Cache {
+	return &Cache{
+		url:        url,
+		client:     &http.Client{Timeout: timeout},
+		ttl:        ttl,
+		minRefresh: minRefresh,
+	}
+}
+
+var (
+	caches   = make(map[string]*Cache)
+	cachesMu sync.Mutex
+)
+
+// FromConfig returns the cache for JWK_URL, shared by everything using the
+// same url
+func FromConfig() (*Cache, error) {
+	c := config.Get()
+
+	ttl, err := time.ParseDuration(c.JwksCacheTTL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWKS_CACHE_TTL: %w", err)
+	}
+	minRefresh, err := time.ParseDuration(c.JwksMinRefreshInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWKS_MIN_REFRESH_INTERVAL: %w", err)
+	}
+	timeout, err := time.ParseDuration(c.JwksFetchTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWKS_FETCH_TIMEOUT: %w", err)
+	}
+
+	cachesMu.Lock()
+	defer cachesMu.Unlock()
+
+	cache, ok := caches[c.JwkURL]
+	if !ok {
+		cache = New(c.JwkURL, ttl, minRefresh, timeout)
+		caches[c.JwkURL] = cache
+	}
+	return cache, nil
+}
+
+// Keys returns the cached keys, fetching them first if they've expired (or
+// were never fetched). Expired keys are still returned if fetching fails, and
+// straight away while the last fetch failed less than minRefresh ago, leaving
+// it to the background refresh to get new ones rather than have every request
+// wait on a failing endpoint.
+func (c *Cache) Keys(ctx context.Context) (*jwk2pem.JWKeys, error) {
+	c.mu.RLock()
+	keys, expiresAt := c.keys, c.expiresAt
+	failing := c.lastErr != nil && time.Since(c.lastAttempt) < c.minRefresh
+	c.mu.RUnlock()
+
+	if keys != nil && (failing || time.Now().Before(expiresAt)) {
+		return keys, nil
+	}
+
+	return c.refresh(ctx, false)
+}
+
+// Key finds the key with the kid, forcing a refresh if it isn't there
+func (c *Cache) Key(ctx context.Context, kid string) (*jwk2pem.JWKey, error) {
+	keys, err := c.Keys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if key := findKey(keys, kid); key != nil {
+		return key, nil
+	}
+
+	c.mu.Lock()
+	allowed := time.Since(c.lastForced) >= c.minRefresh
+	if allowed {
+		c.lastForced = time.Now()
+	}
+	c.mu.Unlock()
+
+	if allowed {
+		keys, err = c.refresh(ctx, true)
+		if err != nil {
+			return nil, err
+		}
+		if key := findKey(keys, kid); key != nil {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, kid)
+}
+
+// Start fetches the keys and then refreshes them shortly before they expire,
+// until the context is done. Failed refreshes are retried every minRefresh.
+func (c *Cache) Start(ctx context.Context) {
+	if _, err := c.Keys(ctx); err != nil {
+		l.Log.Error(err, "failed to fetch JWKs", "url", c.url)
+	}
+
+	for {
+		c.mu.RLock()
+		wait := time.Until(c.expiresAt) - c.ttl/10
+		if c.lastErr != nil {
+			wait = c.minRefresh
+		}
+		c.mu.RUnlock()
+
+		if wait < c.minRefresh {
+			wait = c.minRefresh
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+			// errors are logged and kept for the status already
+			_, _ = c.refresh(ctx, true)
+		}
+	}
+}
+
+// Status is the state of the cache, a zero FetchedAt meaning nothing has been
+// fetched yet
+func (c *Cache) Status() Status {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	s := Status{FetchedAt: c.fetchedAt, LastError: c.lastErr}
+	if !c.fetchedAt.IsZero() {
+		s.Age = time.Since(c.fetchedAt)
+		s.Stale = time.Now().After(c.expiresAt)
+	}
+	return s
+}
+
+// fetches the keys, unless someone else did while we were waiting. force
+// skips that check, for when the current keys are known to be no good.
+func (c *Cache) refresh(ctx context.Context, force bool) (*jwk2pem.JWKeys, error) {
+	started := time.Now()
+
+	if !c.fetchMu.TryLock() {
+		// someone else is already fetching, no need to wait on them when
+		// there's (stale) keys to serve in the meantime
+		c.mu.RLock()
+		keys := c.keys
+		c.mu.RUnlock()
+		if !force && keys != nil {
+			return keys, nil
+		}
+		c.fetchMu.Lock()
+	}
+	defer c.fetchMu.Unlock()
+
+	c.mu.RLock()
+	keys, expiresAt, lastAttempt, lastErr := c.keys, c.expiresAt, c.lastAttempt, c.lastErr
+	c.mu.RUnlock()
+
+	// another request already fetched (or failed to) while this one waited
+	if lastAttempt.After(started) {
+		if keys != nil {
+			return keys, nil
+		}
+		return nil, lastErr
+	}
+	if !force && keys != nil && time.Now().Before(expiresAt) {
+		return keys, nil
+	}
+
+	fetched, maxAge, err := c.fetch(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastAttempt = time.Now()
+
+	if err != nil {
+		c.lastErr = err
+		if c.keys != nil {
+			l.Log.Error(err, "failed to refresh JWKs, serving stale keys", "url", c.url, "age", time.Since(c.fetchedAt).String())
+			return c.keys, nil
+		}
+		return nil, err
+	}
+
+	c.keys = fetched
+	c.lastErr = nil
+	c.fetchedAt = time.Now()
+	c.expiresAt = c.fetchedAt.Add(maxAge)
+	return c.keys, nil
+}
+
+func (c *Cache) fetch(ctx context.Context) (*jwk2pem.JWKeys, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error getting JWKs: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error getting JWKs: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("error getting JWKs: unexpected status %d", resp.StatusCode)
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading JWKs: %w", err)
+	}
+
+	keys := &jwk2pem.JWKeys{}
+	if err := json.Unmarshal(b, keys); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return keys, c.maxAge(resp.Header.Get("Cache-Control")), nil
+}
+
+// how long to keep the keys for going by the Cache-Control header, never
+// less than minRefresh so a `no-cache` endpoint isn't hit on every request
+func (c *Cache) maxAge(cacheControl string) time.Duration {
+	maxAge := c.ttl
+
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(strings.ToLower(directive)), "=")
+		switch name {
+		case "no-cache", "no-store":
+			maxAge = 0
+		case "max-age":
+			if secs, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil && secs >= 0 {
+				maxAge = time.Duration(secs) * time.Second
+			}
+		}
+	}
+
+	if maxAge < c.minRefresh {
+		maxAge = c.minRefresh
+	}
+	return maxAge
+}
+
+func findKey(keys *jwk2pem.JWKeys, kid string) *jwk2pem.JWKey {
+	for i := range keys.Keys {
+		if keys.Keys[i].Kid == kid {
+			return &keys.Keys[i]
+		}
+	}
+	return nil
+}
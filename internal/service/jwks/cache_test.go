@@ -0,0 +1,239 @@
+package jwks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/jwk2pem"
+	"github.com/redhatinsights/mbop/internal/logger"
+	"github.com/stretchr/testify/suite"
+)
+
+type CacheTestSuite struct {
+	suite.Suite
+	mu           sync.Mutex
+	calls        int
+	kids         []string
+	status       int
+	cacheControl string
+	delay        time.Duration
+	server       *httptest.Server
+}
+
+func TestCache(t *testing.T) {
+	suite.Run(t, new(CacheTestSuite))
+}
+
+func (suite *CacheTestSuite) SetupSuite() {
+	_ = logger.Init()
+}
+
+func (suite *CacheTestSuite) BeforeTest(_, _ string) {
+	suite.calls = 0
+	suite.kids = []string{"one"}
+	suite.status = http.StatusOK
+	suite.cacheControl = ""
+	suite.delay = 0
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		suite.mu.Lock()
+		suite.calls++
+		delay := suite.delay
+		suite.mu.Unlock()
+		time.Sleep(delay)
+
+		suite.mu.Lock()
+		defer suite.mu.Unlock()
+
+		if suite.status != http.StatusOK {
+			w.WriteHeader(suite.status)
+			return
+		}
+
+		keys := jwk2pem.JWKeys{}
+		for _, kid := range suite.kids {
+			keys.Keys = append(keys.Keys, jwk2pem.JWKey{Kid: kid, Kty: "RSA"})
+		}
+		if suite.cacheControl != "" {
+			w.Header().Set("Cache-Control", suite.cacheControl)
+		}
+		_ = json.NewEncoder(w).Encode(keys)
+	}))
+}
+
+func (suite *CacheTestSuite) AfterTest(_, _ string) {
+	suite.server.Close()
+}
+
+func (suite *CacheTestSuite) set(f func()) {
+	suite.mu.Lock()
+	defer suite.mu.Unlock()
+	f()
+}
+
+func (suite *CacheTestSuite) callCount() int {
+	suite.mu.Lock()
+	defer suite.mu.Unlock()
+	return suite.calls
+}
+
+func (suite *CacheTestSuite) TestCachesKeys() {
+	c := New(suite.server.URL, time.Hour, time.Hour, time.Second)
+
+	for i := 0; i < 3; i++ {
+		key, err := c.Key(context.Background(), "one")
+		suite.Nil(err)
+		suite.Equal("one", key.Kid)
+	}
+	suite.Equal(1, suite.callCount())
+
+	s := c.Status()
+	suite.False(s.FetchedAt.IsZero())
+	suite.False(s.Stale)
+	suite.Nil(s.LastError)
+}
+
+func (suite *CacheTestSuite) TestRefetchesWhenExpired() {
+	c := New(suite.server.URL, 10*time.Millisecond, 0, time.Second)
+
+	_, err := c.Keys(context.Background())
+	suite.Nil(err)
+	time.Sleep(20 * time.Millisecond)
+	suite.True(c.Status().Stale)
+
+	_, err = c.Keys(context.Background())
+	suite.Nil(err)
+	suite.Equal(2, suite.callCount())
+}
+
+func (suite *CacheTestSuite) TestHonoursCacheControl() {
+	suite.cacheControl = "public, max-age=0"
+	c := New(suite.server.URL, time.Hour, 0, time.Second)
+
+	_, err := c.Keys(context.Background())
+	suite.Nil(err)
+	_, err = c.Keys(context.Background())
+	suite.Nil(err)
+	suite.Equal(2, suite.callCount())
+}
+
+func (suite *CacheTestSuite) TestMaxAge() {
+	c := New(suite.server.URL, time.Hour, time.Minute, time.Second)
+
+	suite.Equal(time.Hour, c.maxAge(""))
+	suite.Equal(10*time.Minute, c.maxAge("public, max-age=600"))
+	suite.Equal(10*time.Minute, c.maxAge(`max-age="600"`))
+	suite.Equal(time.Minute, c.maxAge("max-age=5"))
+	suite.Equal(time.Minute, c.maxAge("no-cache"))
+	suite.Equal(time.Minute, c.maxAge("no-store"))
+	suite.Equal(time.Hour, c.maxAge("max-age=bad"))
+}
+
+func (suite *CacheTestSuite) TestServesStaleOnError() {
+	c := New(suite.server.URL, 10*time.Millisecond, 0, time.Second)
+
+	_, err := c.Keys(context.Background())
+	suite.Nil(err)
+
+	suite.set(func() { suite.status = http.StatusInternalServerError })
+	time.Sleep(20 * time.Millisecond)
+
+	key, err := c.Key(context.Background(), "one")
+	suite.Nil(err)
+	suite.Equal("one", key.Kid)
+
+	s := c.Status()
+	suite.True(s.Stale)
+	suite.EqualError(s.LastError, "error getting JWKs: unexpected status 500")
+}
+
+func (suite *CacheTestSuite) TestNoRetryWhileFailing() {
+	// the keys expire after minRefresh, and aren't fetched again for as long
+	c := New(suite.server.URL, 0, 100*time.Millisecond, time.Second)
+
+	_, err := c.Keys(context.Background())
+	suite.Nil(err)
+
+	suite.set(func() { suite.status = http.StatusInternalServerError })
+	time.Sleep(110 * time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		keys, err := c.Keys(context.Background())
+		suite.Nil(err)
+		suite.Equal("one", keys.Keys[0].Kid)
+	}
+
+	// the first fetch, then the one that failed, leaving it to Start after that
+	suite.Equal(2, suite.callCount())
+}
+
+func (suite *CacheTestSuite) TestStaleNotHeldUpBySlowFetch() {
+	c := New(suite.server.URL, 10*time.Millisecond, 0, time.Second)
+
+	_, err := c.Keys(context.Background())
+	suite.Nil(err)
+
+	suite.set(func() { suite.delay = 500 * time.Millisecond })
+	time.Sleep(20 * time.Millisecond)
+
+	go func() { _, _ = c.Keys(context.Background()) }()
+	suite.Eventually(func() bool { return suite.callCount() == 2 }, time.Second, time.Millisecond)
+
+	started := time.Now()
+	keys, err := c.Keys(context.Background())
+	suite.Nil(err)
+	suite.Equal("one", keys.Keys[0].Kid)
+	suite.Less(time.Since(started), 100*time.Millisecond)
+}
+
+func (suite *CacheTestSuite) TestErrorWithNothingCached() {
+	suite.status = http.StatusBadGateway
+	c := New(suite.server.URL, time.Hour, 0, time.Second)
+
+	_, err := c.Key(context.Background(), "one")
+	suite.EqualError(err, "error getting JWKs: unexpected status 502")
+	suite.True(c.Status().FetchedAt.IsZero())
+}
+
+func (suite *CacheTestSuite) TestUnknownKidForcesRefresh() {
+	c := New(suite.server.URL, time.Hour, time.Hour, time.Second)
+
+	_, err := c.Keys(context.Background())
+	suite.Nil(err)
+
+	suite.set(func() { suite.kids = []string{"one", "two"} })
+
+	key, err := c.Key(context.Background(), "two")
+	suite.Nil(err)
+	suite.Equal("two", key.Kid)
+	suite.Equal(2, suite.callCount())
+}
+
+func (suite *CacheTestSuite) TestForcedRefreshRateLimited() {
+	c := New(suite.server.URL, time.Hour, time.Hour, time.Second)
+
+	for i := 0; i < 5; i++ {
+		_, err := c.Key(context.Background(), "missing")
+		suite.True(errors.Is(err, ErrKeyNotFound))
+	}
+
+	// the first fetch, then only the one forced refresh
+	suite.Equal(2, suite.callCount())
+}
+
+func (suite *CacheTestSuite) TestStartRefreshes() {
+	c := New(suite.server.URL, 20*time.Millisecond, 10*time.Millisecond, time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go c.Start(ctx)
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	suite.GreaterOrEqual(suite.callCount(), 3)
+	suite.False(c.Status().FetchedAt.IsZero())
+}
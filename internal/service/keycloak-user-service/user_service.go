@@ -67,7 +67,10 @@ func (userService *UserServiceClient) GetAccountV3Users(orgID string, token stri
 		return users, err
 	}
 
-	return keycloakResponseToUsers(unmarshaledResponse.Users), nil
+	users = keycloakResponseToUsers(unmarshaledResponse.Users)
+	users.Total = unmarshaledResponse.Meta.Total
+
+	return users, nil
 }
 
 func (userService *UserServiceClient) GetAccountV3UsersBy(orgID string, token string, q models.UserV3Query, usersByBody models.UsersByBody) (models.Users, error) {
@@ -89,7 +92,10 @@ func (userService *UserServiceClient) GetAccountV3UsersBy(orgID string, token st
 		return users, err
 	}
 
-	return keycloakResponseToUsers(unmarshaledResponse.Users), nil
+	users = keycloakResponseToUsers(unmarshaledResponse.Users)
+	users.Total = unmarshaledResponse.Meta.Total
+
+	return users, nil
 }
 
 func (userService *UserServiceClient) sendKeycloakGetRequest(url *url.URL, token string) ([]byte, error) {
@@ -162,6 +168,11 @@ func createV3UsersRequestURL(orgID string, q models.UserV3Query) (*url.URL, erro
 	queryParams.Add("limit", strconv.Itoa(q.Limit))
 	queryParams.Add("offset", strconv.Itoa(q.Offset))
 
+	// filtered by the user service so the page and total only count admins
+	if q.AdminOnly {
+		queryParams.Add("admin_only", "true")
+	}
+
 	url.RawQuery = queryParams.Encode()
 
 	return url, err
@@ -198,6 +209,11 @@ func createV3UsersByRequestURL(orgID string, q models.UserV3Query, usersByBody m
 	queryParams.Add("limit", strconv.Itoa(q.Limit))
 	queryParams.Add("offset", strconv.Itoa(q.Offset))
 
+	// filtered by the user service so the page and total only count admins
+	if q.AdminOnly {
+		queryParams.Add("admin_only", "true")
+	}
+
 	url.RawQuery = queryParams.Encode()
 
 	return url, err
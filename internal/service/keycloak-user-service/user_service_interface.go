@@ -1,9 +1,6 @@
 package keycloakuserservice
 
 import (
-	"fmt"
-
-	"github.com/redhatinsights/mbop/internal/config"
 	"github.com/redhatinsights/mbop/internal/models"
 )
 
@@ -13,19 +10,3 @@ type KeyCloakUserService interface {
 	GetAccountV3Users(orgID string, token string, q models.UserV3Query) (models.Users, error)
 	GetAccountV3UsersBy(orgID string, token string, q models.UserV3Query, usersByBody models.UsersByBody) (models.Users, error)
 }
-
-// re-declaring keycloak constant here to avoid circular module importing
-const keyCloakModule = "keycloak"
-
-func NewKeyCloakUserServiceClient() (KeyCloakUserService, error) {
-	var client KeyCloakUserService
-
-	switch config.Get().UsersModule {
-	case keyCloakModule:
-		client = &UserServiceClient{}
-	default:
-		return nil, fmt.Errorf("unsupported users module %q", config.Get().UsersModule)
-	}
-
-	return client, nil
-}
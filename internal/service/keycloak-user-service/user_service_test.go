@@ -0,0 +1,37 @@
+package keycloakuserservice
+
+import (
+	"testing"
+
+	"github.com/redhatinsights/mbop/internal/models"
+)
+
+func TestCreateV3UsersRequestURLAdminOnly(t *testing.T) {
+	tests := []struct {
+		adminOnly bool
+		want      string
+	}{
+		{adminOnly: false, want: ""},
+		{adminOnly: true, want: "true"},
+	}
+
+	for _, test := range tests {
+		q := models.UserV3Query{Limit: 10, Offset: 0, AdminOnly: test.adminOnly}
+
+		url, err := createV3UsersRequestURL("123", q)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got := url.Query().Get("admin_only"); got != test.want {
+			t.Errorf(`users url admin_only = "%s", want "%s"`, got, test.want)
+		}
+
+		url, err = createV3UsersByRequestURL("123", q, models.UsersByBody{PrimaryEmail: "me@example.com"})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got := url.Query().Get("admin_only"); got != test.want {
+			t.Errorf(`usersBy url admin_only = "%s", want "%s"`, got, test.want)
+		}
+	}
+}
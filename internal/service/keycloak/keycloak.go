@@ -1,16 +1,13 @@
 package keycloak
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"net/url"
-	"strings"
 	"time"
 
 	"github.com/redhatinsights/mbop/internal/config"
-	"github.com/redhatinsights/mbop/internal/models"
 )
 
 type Client struct {
@@ -18,43 +15,29 @@ type Client struct {
 }
 
 func (keycloak *Client) InitKeycloakConnection() error {
-	keycloak.client = &http.Client{
-		Timeout: time.Duration(config.Get().KeyCloakTimeout * int64(time.Second)),
-	}
+	keycloak.client = newHTTPClient()
 
 	return nil
 }
 
+// GetAccessToken returns the service account's token, shared by every client
+// and only fetched again when it's about to expire
 func (keycloak *Client) GetAccessToken() (string, error) {
-	token := models.KeycloakTokenObject{}
-	url, err := createTokenURL()
+	source, err := sharedTokenSource()
 	if err != nil {
 		return "", err
 	}
 
-	body := createEncodedTokenBody()
-
-	resp, err := http.Post(url.String(), "application/x-www-form-urlencoded", body)
-	if err != nil {
-		return "", fmt.Errorf("error fetching keycloak token response: %s", err)
-	}
-
-	defer resp.Body.Close()
-
-	responseBody, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("error reading keycloak token response body: %s", err)
-	}
+	return source.Token(context.Background())
+}
 
-	err = json.Unmarshal(responseBody, &token)
-	if err != nil {
-		return "", fmt.Errorf("error unmarshling keycloak token response: %s", err)
+func newHTTPClient() *http.Client {
+	return &http.Client{
+		Timeout: time.Duration(config.Get().KeyCloakTimeout * int64(time.Second)),
 	}
-
-	return token.AccessToken, nil
 }
 
-func createEncodedTokenBody() *strings.Reader {
+func createTokenForm() url.Values {
 	data := url.Values{}
 	data.Set("username", config.Get().KeyCloakTokenUsername)
 	data.Set("grant_type", config.Get().KeyCloakTokenGrantType)
@@ -66,7 +49,7 @@ func createEncodedTokenBody() *strings.Reader {
 		data.Set("client_secret", config.Get().KeyCloakTokenPassword)
 	}
 
-	return strings.NewReader(data.Encode())
+	return data
 }
 
 func createTokenURL() (*url.URL, error) {
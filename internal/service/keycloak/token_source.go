@@ -0,0 +1,201 @@
+package keycloak
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	l "github.com/redhatinsights/mbop/internal/logger"
+	"github.com/redhatinsights/mbop/internal/models"
+)
+
+// how long before a token expires it's replaced, so it doesn't expire while a
+// request is in flight
+const tokenExpiryMargin = 30 * time.Second
+
+// TokenError is a non-200 response from the token endpoint, with the OAuth
+// error from the body when there is one
+type TokenError struct {
+	StatusCode  int
+	Code        string
+	Description string
+}
+
+func (e *TokenError) Error() string {
+	msg := fmt.Sprintf("keycloak token request failed with status %d", e.StatusCode)
+	if e.Code != "" {
+		msg += ": " + e.Code
+	}
+	if e.Description != "" {
+		msg += " (" + e.Description + ")"
+	}
+	return msg
+}
+
+/*
+TokenSource hands out the service account's access token, only going back to
+keycloak when it's about to expire:
+  - the token is kept until tokenExpiryMargin before its expires_in
+  - when keycloak issued a refresh_token that's still good it's used to get the
+    next token, falling back to the configured grant if the refresh is rejected
+  - concurrent callers wait on the one request rather than each making their own
+*/
+type TokenSource struct {
+	client   *http.Client
+	tokenURL string
+	form     url.Values
+	now      func() time.Time
+
+	mu               sync.Mutex
+	accessToken      string
+	expiresAt        time.Time
+	refreshToken     string
+	refreshExpiresAt time.Time
+}
+
+// NewTokenSource builds a source requesting tokens from tokenURL with the grant
+// in form, e.g. the username/password or client credentials
+func NewTokenSource(client *http.Client, tokenURL string, form url.Values) *TokenSource {
+	return &TokenSource{
+		client:   client,
+		tokenURL: tokenURL,
+		form:     form,
+		now:      time.Now,
+	}
+}
+
+var (
+	defaultSource    *TokenSource
+	defaultSourceErr error
+	defaultOnce      sync.Once
+)
+
+// the source for the configured service account, shared by every client
+func sharedTokenSource() (*TokenSource, error) {
+	defaultOnce.Do(func() {
+		tokenURL, err := createTokenURL()
+		if err != nil {
+			defaultSourceErr = err
+			return
+		}
+
+		defaultSource = NewTokenSource(newHTTPClient(), tokenURL.String(), createTokenForm())
+	})
+
+	return defaultSource, defaultSourceErr
+}
+
+// Token returns an access token that's good for at least tokenExpiryMargin
+func (s *TokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.accessToken != "" && now.Before(s.expiresAt) {
+		return s.accessToken, nil
+	}
+
+	if s.refreshToken != "" && (s.refreshExpiresAt.IsZero() || now.Before(s.refreshExpiresAt)) {
+		form := url.Values{}
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", s.refreshToken)
+		form.Set("client_id", s.form.Get("client_id"))
+		if secret := s.form.Get("client_secret"); secret != "" {
+			form.Set("client_secret", secret)
+		}
+
+		token, err := s.request(ctx, form)
+		if err == nil {
+			s.store(token, now)
+			return s.accessToken, nil
+		}
+
+		// the refresh token may have been revoked, or the session ended
+		l.Log.Info("failed to refresh keycloak token, requesting a new one", "error", err.Error())
+	}
+
+	token, err := s.request(ctx, s.form)
+	if err != nil {
+		return "", err
+	}
+
+	s.store(token, now)
+	return s.accessToken, nil
+}
+
+func (s *TokenSource) store(token *models.KeycloakTokenObject, issued time.Time) {
+	s.accessToken = token.AccessToken
+	s.expiresAt = expiry(issued, token.ExpiresIn)
+
+	// a refresh_expires_in of 0 means the refresh token lasts as long as the
+	// session does, so it's tried until it's rejected
+	s.refreshToken = token.RefreshToken
+	s.refreshExpiresAt = time.Time{}
+	if token.RefreshExpiresIn > 0 {
+		s.refreshExpiresAt = expiry(issued, token.RefreshExpiresIn)
+	}
+}
+
+// when a token lasting expiresIn seconds should be replaced, never more than
+// half its life early so short lived tokens still get used
+func expiry(issued time.Time, expiresIn int32) time.Time {
+	lifetime := time.Duration(expiresIn) * time.Second
+
+	margin := tokenExpiryMargin
+	if lifetime/2 < margin {
+		margin = lifetime / 2
+	}
+
+	return issued.Add(lifetime - margin)
+}
+
+func (s *TokenSource) request(ctx context.Context, form url.Values) (*models.KeycloakTokenObject, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("error creating keycloak token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching keycloak token response: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading keycloak token response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		tokenErr := &TokenError{StatusCode: resp.StatusCode}
+
+		oauthErr := struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}{}
+		if json.Unmarshal(body, &oauthErr) == nil {
+			tokenErr.Code = oauthErr.Error
+			tokenErr.Description = oauthErr.Description
+		}
+
+		return nil, tokenErr
+	}
+
+	token := &models.KeycloakTokenObject{}
+	err = json.Unmarshal(body, token)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshling keycloak token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("no access token in keycloak token response")
+	}
+
+	return token, nil
+}
@@ -0,0 +1,190 @@
+package keycloak
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redhatinsights/mbop/internal/logger"
+	"github.com/stretchr/testify/suite"
+)
+
+type TokenSourceTestSuite struct {
+	suite.Suite
+	mu            sync.Mutex
+	grants        []string
+	status        int
+	rejectRefresh bool
+	refreshToken  string
+	server        *httptest.Server
+	now           time.Time
+	source        *TokenSource
+}
+
+func TestTokenSource(t *testing.T) {
+	suite.Run(t, new(TokenSourceTestSuite))
+}
+
+func (suite *TokenSourceTestSuite) SetupSuite() {
+	_ = logger.Init()
+}
+
+func (suite *TokenSourceTestSuite) BeforeTest(_, _ string) {
+	suite.grants = nil
+	suite.status = http.StatusOK
+	suite.rejectRefresh = false
+	suite.refreshToken = ""
+	suite.now = time.Now()
+
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		suite.mu.Lock()
+		defer suite.mu.Unlock()
+
+		suite.Nil(r.ParseForm())
+		grant := r.PostForm.Get("grant_type")
+		suite.grants = append(suite.grants, grant)
+
+		if suite.status != http.StatusOK {
+			w.WriteHeader(suite.status)
+			_, _ = w.Write([]byte(`{"error":"invalid_client","error_description":"Invalid client credentials"}`))
+			return
+		}
+		if grant == "refresh_token" && (suite.rejectRefresh || r.PostForm.Get("refresh_token") != suite.refreshToken) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+
+		token := map[string]interface{}{
+			"access_token": grant + "-" + strconv.Itoa(len(suite.grants)),
+			"expires_in":   300,
+		}
+		if suite.refreshToken != "" {
+			token["refresh_token"] = suite.refreshToken
+			token["refresh_expires_in"] = 1800
+		}
+		_ = json.NewEncoder(w).Encode(token)
+	}))
+
+	form := url.Values{}
+	form.Set("grant_type", "password")
+	form.Set("client_id", "admin-cli")
+	form.Set("username", "admin")
+	form.Set("password", "admin")
+
+	suite.source = NewTokenSource(suite.server.Client(), suite.server.URL, form)
+	suite.source.now = func() time.Time { return suite.now }
+}
+
+func (suite *TokenSourceTestSuite) AfterTest(_, _ string) {
+	suite.server.Close()
+}
+
+func (suite *TokenSourceTestSuite) TestCachesToken() {
+	for i := 0; i < 3; i++ {
+		token, err := suite.source.Token(context.Background())
+		suite.Nil(err)
+		suite.Equal("password-1", token)
+	}
+	suite.Equal([]string{"password"}, suite.grants)
+}
+
+func (suite *TokenSourceTestSuite) TestReplacedBeforeExpiry() {
+	_, err := suite.source.Token(context.Background())
+	suite.Nil(err)
+
+	// still more than the margin left
+	suite.now = suite.now.Add(4 * time.Minute)
+	token, err := suite.source.Token(context.Background())
+	suite.Nil(err)
+	suite.Equal("password-1", token)
+
+	// within the margin of the 5 minute expiry
+	suite.now = suite.now.Add(40 * time.Second)
+	token, err = suite.source.Token(context.Background())
+	suite.Nil(err)
+	suite.Equal("password-2", token)
+}
+
+func (suite *TokenSourceTestSuite) TestUsesRefreshToken() {
+	suite.refreshToken = "refresh"
+
+	_, err := suite.source.Token(context.Background())
+	suite.Nil(err)
+
+	suite.now = suite.now.Add(5 * time.Minute)
+	token, err := suite.source.Token(context.Background())
+	suite.Nil(err)
+	suite.Equal("refresh_token-2", token)
+	suite.Equal([]string{"password", "refresh_token"}, suite.grants)
+}
+
+func (suite *TokenSourceTestSuite) TestRefreshTokenExpired() {
+	suite.refreshToken = "refresh"
+
+	_, err := suite.source.Token(context.Background())
+	suite.Nil(err)
+
+	suite.now = suite.now.Add(time.Hour)
+	token, err := suite.source.Token(context.Background())
+	suite.Nil(err)
+	suite.Equal("password-2", token)
+	suite.Equal([]string{"password", "password"}, suite.grants)
+}
+
+func (suite *TokenSourceTestSuite) TestRefreshRejected() {
+	suite.refreshToken = "refresh"
+
+	_, err := suite.source.Token(context.Background())
+	suite.Nil(err)
+
+	suite.rejectRefresh = true
+	suite.now = suite.now.Add(5 * time.Minute)
+	token, err := suite.source.Token(context.Background())
+	suite.Nil(err)
+	suite.Equal("password-3", token)
+	suite.Equal([]string{"password", "refresh_token", "password"}, suite.grants)
+}
+
+func (suite *TokenSourceTestSuite) TestTokenError() {
+	suite.status = http.StatusUnauthorized
+
+	_, err := suite.source.Token(context.Background())
+
+	var tokenErr *TokenError
+	suite.True(errors.As(err, &tokenErr))
+	suite.Equal(http.StatusUnauthorized, tokenErr.StatusCode)
+	suite.Equal("invalid_client", tokenErr.Code)
+	suite.EqualError(err, "keycloak token request failed with status 401: invalid_client (Invalid client credentials)")
+}
+
+func (suite *TokenSourceTestSuite) TestConcurrent() {
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := suite.source.Token(context.Background())
+			suite.Nil(err)
+			suite.Equal("password-1", token)
+		}()
+	}
+	wg.Wait()
+
+	suite.Len(suite.grants, 1)
+}
+
+func (suite *TokenSourceTestSuite) TestExpiry() {
+	issued := time.Now()
+
+	suite.Equal(issued.Add(270*time.Second), expiry(issued, 300))
+	suite.Equal(issued.Add(20*time.Second), expiry(issued, 40))
+	suite.Equal(issued, expiry(issued, 0))
+}
@@ -8,9 +8,7 @@ import (
 	"github.com/redhatinsights/mbop/internal/config"
 	l "github.com/redhatinsights/mbop/internal/logger"
 	"github.com/redhatinsights/mbop/internal/models"
-	"github.com/redhatinsights/mbop/internal/service/keycloak"
-	keycloakuserservice "github.com/redhatinsights/mbop/internal/service/keycloak-user-service"
-	"github.com/redhatinsights/mbop/internal/service/ocm"
+	"github.com/redhatinsights/mbop/internal/service/userprovider"
 	"golang.org/x/exp/maps"
 )
 
@@ -40,64 +38,20 @@ func LookupEmailsForUsernames(ctx context.Context, email *models.Email) error {
 
 	l.Log.Info("Looking up usernames", "user_module", config.Get().UsersModule, "usernames", maps.Keys(toLookup))
 
-	// using the appropriate AMS Module - search and look up the emails from the
-	// usernames
-	switch config.Get().UsersModule {
-	case "ams":
-		ocm, err := ocm.NewOcmClient()
-		if err != nil {
-			return err
-		}
-
-		err = ocm.InitSdkConnection(ctx)
-		if err != nil {
-			return err
-		}
-
-		users, err := ocm.GetUsers(models.UserBody{Users: maps.Keys(toLookup)}, models.UserV1Query{})
-		if err != nil {
-			return err
-		}
-
-		for _, user := range users.Users {
-			toLookup[user.Username] = user.Email
-		}
-	case "keycloak":
-		keycloakClient := keycloak.NewKeyCloakClient()
-		err := keycloakClient.InitKeycloakConnection()
-		if err != nil {
-			return err
-		}
-
-		token, err := keycloakClient.GetAccessToken()
-		if err != nil {
-			return err
-		}
-
-		userServiceClient, err := keycloakuserservice.NewKeyCloakUserServiceClient()
-		if err != nil {
-			return err
-		}
-
-		err = userServiceClient.InitKeycloakUserServiceConnection()
-		if err != nil {
-			return err
-		}
+	// search and look up the emails from the usernames in the configured
+	// users module
+	provider, err := userprovider.NewUserProvider()
+	if err != nil {
+		return fmt.Errorf("no configured user module for username translations: %w", err)
+	}
 
-		u, err := userServiceClient.GetUsers(token, models.UserBody{Users: maps.Keys(toLookup)}, models.UserV1Query{})
-		if err != nil {
-			return err
-		}
+	users, err := provider.GetUsers(ctx, models.UserBody{Users: maps.Keys(toLookup)}, models.UserV1Query{})
+	if err != nil {
+		return err
+	}
 
-		for _, user := range u.Users {
-			toLookup[user.Username] = user.Email
-		}
-	case "mock":
-		for k := range toLookup {
-			toLookup[k] = k + "@mocked.biz"
-		}
-	default:
-		return fmt.Errorf("no configured user module for username translations")
+	for _, user := range users.Users {
+		toLookup[user.Username] = user.Email
 	}
 
 	// ...and finally, replace the usernames -> in the lists on the email objects
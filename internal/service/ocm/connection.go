@@ -0,0 +1,134 @@
+package ocm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	sdk "github.com/openshift-online/ocm-sdk-go"
+	sdkerrors "github.com/openshift-online/ocm-sdk-go/errors"
+	"github.com/openshift-online/ocm-sdk-go/logging"
+	"github.com/redhatinsights/mbop/internal/config"
+	l "github.com/redhatinsights/mbop/internal/logger"
+)
+
+/*
+The sdk connection is shared by every request for the life of the process. It's
+safe for concurrent use and holds on to its access token until it's about to
+expire, so sharing it means a token exchange against OAUTH_TOKEN_URL every so
+often rather than one per request.
+
+When AMS rejects the token (or a new one can't be fetched) the connection is
+dropped and rebuilt, in case the token was revoked or the credentials rotated.
+*/
+var (
+	shared   *sdk.Connection
+	sharedMu sync.Mutex
+)
+
+// connection returns the shared connection, building it on first use. It's
+// built outside of any one request's context, as it outlives the request.
+func connection() (*sdk.Connection, error) {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+
+	if shared != nil {
+		return shared, nil
+	}
+
+	conn, err := buildConnection(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	shared = conn
+	return shared, nil
+}
+
+// drops the shared connection if it's still the one that failed, so the next
+// request builds a new one. Requests that failed on the same connection at the
+// same time only drop it the once.
+func resetConnection(failed *sdk.Connection) {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+
+	if shared != failed {
+		return
+	}
+	shared = nil
+
+	if err := failed.Close(); err != nil {
+		l.Log.Error(err, "error closing sdk connection")
+	}
+}
+
+// Shutdown closes the shared connection, for when the server is stopping
+func Shutdown() error {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+
+	if shared == nil {
+		return nil
+	}
+
+	err := shared.Close()
+	shared = nil
+	return err
+}
+
+func buildConnection(ctx context.Context) (*sdk.Connection, error) {
+	// Create a logger that has the debug level enabled:
+	logger, err := logging.NewGoLoggerBuilder().
+		Debug(config.Get().Debug).
+		Build()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sdk.NewConnectionBuilder().
+		Logger(logger).
+
+		// SA Auth:
+		Client(config.Get().CognitoAppClientID, config.Get().CognitoAppClientSecret).
+
+		// Offline Token Auth:
+		// Tokens(<token>).
+
+		// Oauth Token URL:
+		TokenURL(config.Get().OauthTokenURL).
+
+		// Route to hit for AMS:
+		URL(config.Get().AmsURL).
+
+		// SA Scopes:
+		Scopes(config.Get().CognitoScope).
+		BuildContext(ctx)
+}
+
+// errNoToken is returned when the connection can't get a token, which the sdk
+// itself only reports as a message
+var errNoToken = errors.New("can't get access token")
+
+// fetches the connection's token before a request is sent, so a failure to
+// get one can be told apart from AMS failing. The sdk caches the token, the
+// request goes out with this same one.
+func ensureToken(ctx context.Context, conn *sdk.Connection) error {
+	if _, _, err := conn.TokensContext(ctx); err != nil {
+		return fmt.Errorf("%w: %s", errNoToken, err.Error())
+	}
+	return nil
+}
+
+// whether the request failed because of the token, either AMS rejecting it or
+// a new one not being issued
+func isAuthFailure(err error) bool {
+	if errors.Is(err, errNoToken) {
+		return true
+	}
+
+	var sdkErr *sdkerrors.Error
+	return errors.As(err, &sdkErr) && sdkErr.Status() == http.StatusUnauthorized
+}
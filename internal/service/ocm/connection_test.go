@@ -0,0 +1,454 @@
+package ocm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redhatinsights/mbop/internal/config"
+	"github.com/redhatinsights/mbop/internal/logger"
+	"github.com/redhatinsights/mbop/internal/models"
+	"github.com/stretchr/testify/suite"
+)
+
+// stands in for both the token endpoint and AMS
+type ConnectionTestSuite struct {
+	suite.Suite
+	mu            sync.Mutex
+	tokenCalls    int
+	accountsCalls int
+	unauthorized  int
+	tokenRejects  int
+	server        *httptest.Server
+
+	bindingsCalls int
+	inFlight      int
+	maxInFlight   int
+	maxBatch      int
+	omitTotal     bool
+	accountsQuery []string
+	orgAdmins     int
+}
+
+func TestConnection(t *testing.T) {
+	suite.Run(t, new(ConnectionTestSuite))
+}
+
+func (suite *ConnectionTestSuite) SetupSuite() {
+	_ = logger.Init()
+}
+
+func (suite *ConnectionTestSuite) BeforeTest(_, _ string) {
+	suite.tokenCalls = 0
+	suite.accountsCalls = 0
+	suite.unauthorized = 0
+	suite.tokenRejects = 0
+	suite.bindingsCalls = 0
+	suite.inFlight = 0
+	suite.maxInFlight = 0
+	suite.maxBatch = 0
+	suite.omitTotal = false
+	suite.accountsQuery = nil
+	suite.orgAdmins = 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", suite.token)
+	mux.HandleFunc("/api/accounts_mgmt/v1/accounts", suite.accounts)
+	mux.HandleFunc("/api/accounts_mgmt/v1/role_bindings", suite.roleBindings)
+	suite.server = httptest.NewServer(mux)
+
+	config.Reset()
+	os.Setenv("OAUTH_TOKEN_URL", suite.server.URL+"/token")
+	os.Setenv("AMS_URL", suite.server.URL)
+	os.Setenv("COGNITO_APP_CLIENT_ID", "client")
+	os.Setenv("COGNITO_APP_CLIENT_SECRET", "secret")
+
+	suite.Nil(Shutdown())
+}
+
+func (suite *ConnectionTestSuite) AfterTest(_, _ string) {
+	suite.Nil(Shutdown())
+	suite.server.Close()
+
+	os.Unsetenv("OAUTH_TOKEN_URL")
+	os.Unsetenv("AMS_URL")
+	os.Unsetenv("COGNITO_APP_CLIENT_ID")
+	os.Unsetenv("COGNITO_APP_CLIENT_SECRET")
+	config.Reset()
+}
+
+func (suite *ConnectionTestSuite) token(w http.ResponseWriter, r *http.Request) {
+	suite.mu.Lock()
+	suite.tokenCalls++
+	reject := suite.tokenRejects > 0
+	if reject {
+		suite.tokenRejects--
+	}
+	suite.mu.Unlock()
+
+	if reject {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": "invalid_client"})
+		return
+	}
+
+	suite.Nil(r.ParseForm())
+	suite.Equal("client_credentials", r.PostForm.Get("grant_type"))
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"typ": "Bearer",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte("test"))
+	suite.Nil(err)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": token,
+		"token_type":   "bearer",
+		"expires_in":   3600,
+	})
+}
+
+func (suite *ConnectionTestSuite) accounts(w http.ResponseWriter, r *http.Request) {
+	suite.mu.Lock()
+	suite.accountsCalls++
+	suite.accountsQuery = append(suite.accountsQuery, r.URL.Query().Get("search"))
+	reject := suite.unauthorized > 0
+	if reject {
+		suite.unauthorized--
+	}
+	suite.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if reject || r.Header.Get("Authorization") == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"kind":"Error","id":"401","code":"ACCT-MGMT-401","reason":"token rejected"}`))
+		return
+	}
+
+	// searching by ids gets back an account for each of them, paged with a
+	// max of 10 per page
+	if m := accountIDsPattern.FindStringSubmatch(r.URL.Query().Get("search")); m != nil {
+		var ids []string
+		for _, id := range strings.Split(m[1], ", ") {
+			ids = append(ids, strings.Trim(id, "'"))
+		}
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		if size > 10 {
+			size = 10
+		}
+		start, end := (page-1)*size, page*size
+		if start > len(ids) {
+			start = len(ids)
+		}
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		items := make([]map[string]interface{}, 0)
+		for _, id := range ids[start:end] {
+			items = append(items, map[string]interface{}{
+				"kind":         "Account",
+				"id":           id,
+				"username":     "user-" + id,
+				"organization": map[string]interface{}{"id": "123", "name": "org"},
+			})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"kind": "AccountList", "page": page, "size": len(items), "total": len(ids), "items": items})
+		return
+	}
+
+	_, _ = w.Write([]byte(`{"kind":"AccountList","page":1,"size":1,"total":1,"items":[` +
+		`{"kind":"Account","id":"1","username":"me","email":"me@example.com","organization":{"id":"123","name":"org"}}]}`))
+}
+
+var accountIDsPattern = regexp.MustCompile(`id in \(([^)]*)\)`)
+
+var accountIDPattern = regexp.MustCompile(`account.id='([^']+)'`)
+
+// every user with an even id is an org admin, paged with a max of 10 per page.
+// Org 123's admins are 2 and 4 (or 0 to orgAdmins when that's set), other
+// orgs have none.
+func (suite *ConnectionTestSuite) roleBindings(w http.ResponseWriter, r *http.Request) {
+	suite.mu.Lock()
+	suite.bindingsCalls++
+	suite.inFlight++
+	if suite.inFlight > suite.maxInFlight {
+		suite.maxInFlight = suite.inFlight
+	}
+	omitTotal := suite.omitTotal
+	orgAdmins := suite.orgAdmins
+	suite.mu.Unlock()
+
+	// long enough for the batches to overlap
+	time.Sleep(10 * time.Millisecond)
+
+	var admins []string
+	if strings.HasPrefix(r.URL.Query().Get("search"), "organization.id='123'") {
+		admins = []string{"4", "2"}
+		if orgAdmins > 0 {
+			admins = nil
+			for i := 0; i < orgAdmins; i++ {
+				admins = append(admins, fmt.Sprintf("%03d", i))
+			}
+		}
+	}
+	matches := accountIDPattern.FindAllStringSubmatch(r.URL.Query().Get("search"), -1)
+	for _, m := range matches {
+		if id, _ := strconv.Atoi(m[1]); id%2 == 0 {
+			admins = append(admins, m[1])
+		}
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+	if size > 10 {
+		size = 10
+	}
+	start, end := (page-1)*size, page*size
+	if start > len(admins) {
+		start = len(admins)
+	}
+	if end > len(admins) {
+		end = len(admins)
+	}
+
+	items := make([]map[string]interface{}, 0)
+	for _, id := range admins[start:end] {
+		items = append(items, map[string]interface{}{
+			"kind":    "RoleBinding",
+			"account": map[string]interface{}{"kind": "Account", "id": id},
+			"role":    map[string]interface{}{"kind": "Role", "id": "OrganizationAdmin"},
+		})
+	}
+	list := map[string]interface{}{"kind": "RoleBindingList", "page": page, "size": len(items), "items": items}
+	if !omitTotal {
+		list["total"] = len(admins)
+	}
+
+	suite.mu.Lock()
+	suite.inFlight--
+	if len(matches) > suite.maxBatch {
+		suite.maxBatch = len(matches)
+	}
+	suite.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+func (suite *ConnectionTestSuite) getOrgAdmins(count int) (models.OrgAdminResponse, error) {
+	users := make([]models.User, count)
+	for i := range users {
+		users[i].ID = strconv.Itoa(i)
+	}
+
+	client := &SDK{}
+	err := client.Connect(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return client.GetOrgAdmin(users)
+}
+
+func (suite *ConnectionTestSuite) TestGetOrgAdminBatched() {
+	admins, err := suite.getOrgAdmins(500)
+	suite.Nil(err)
+
+	suite.Len(admins, 250)
+	for i := 0; i < 500; i += 2 {
+		suite.Equal(models.OrgAdmin{ID: strconv.Itoa(i), IsOrgAdmin: true}, admins[strconv.Itoa(i)])
+	}
+
+	// 10 batches of 50 users, each with 25 admins over 3 pages
+	suite.Equal(orgAdminBatchSize, suite.maxBatch)
+	suite.Equal(30, suite.bindingsCalls)
+	suite.LessOrEqual(suite.maxInFlight, orgAdminConcurrency)
+	suite.Greater(suite.maxInFlight, 1)
+}
+
+func (suite *ConnectionTestSuite) TestGetOrgAdminPaged() {
+	// without a total the pages are read until an empty one
+	suite.omitTotal = true
+
+	admins, err := suite.getOrgAdmins(50)
+	suite.Nil(err)
+	suite.Len(admins, 25)
+	suite.Equal(4, suite.bindingsCalls)
+}
+
+func (suite *ConnectionTestSuite) TestGetOrgAdminNoUsers() {
+	admins, err := suite.getOrgAdmins(0)
+	suite.Nil(err)
+	suite.Len(admins, 0)
+	suite.Equal(0, suite.bindingsCalls)
+}
+
+func (suite *ConnectionTestSuite) listOrgUsers(orgID string, q models.UserV3Query) (models.Users, error) {
+	client := &SDK{}
+	err := client.Connect(context.Background())
+	if err != nil {
+		return models.Users{}, err
+	}
+
+	return client.GetAccountV3Users(orgID, q)
+}
+
+func (suite *ConnectionTestSuite) TestListOrgUsers() {
+	users, err := suite.listOrgUsers("123", models.UserV3Query{Limit: 10, Offset: 1})
+	suite.Nil(err)
+	suite.Len(users.Users, 1)
+	suite.Equal(1, users.Total)
+
+	suite.Equal([]string{"organization.id='123'"}, suite.accountsQuery)
+	suite.Equal(0, suite.bindingsCalls)
+}
+
+func (suite *ConnectionTestSuite) TestListOrgUsersAdminOnly() {
+	users, err := suite.listOrgUsers("123", models.UserV3Query{Limit: 10, Offset: 1, AdminOnly: true})
+	suite.Nil(err)
+	suite.Len(users.Users, 2)
+	suite.Equal(2, users.Total)
+	suite.Equal("2", users.Users[0].ID)
+	suite.Equal("4", users.Users[1].ID)
+
+	// joined with the admins before the accounts are paged
+	suite.Equal([]string{"organization.id='123' and id in ('2', '4')"}, suite.accountsQuery)
+	suite.Equal(1, suite.bindingsCalls)
+}
+
+func (suite *ConnectionTestSuite) TestListOrgUsersAdminOnlyBatched() {
+	suite.orgAdmins = 120
+
+	users, err := suite.listOrgUsers("123", models.UserV3Query{Limit: 25, Offset: 3, SortOrder: "asc", AdminOnly: true})
+	suite.Nil(err)
+	suite.Equal(120, users.Total)
+	suite.Len(users.Users, 25)
+	suite.Equal("050", users.Users[0].ID)
+	suite.Equal("074", users.Users[24].ID)
+
+	// no more than a batch of ids in each search, every page of each read
+	batches := make(map[string]bool)
+	for _, search := range suite.accountsQuery {
+		m := accountIDsPattern.FindStringSubmatch(search)
+		suite.NotNil(m)
+		suite.LessOrEqual(len(strings.Split(m[1], ", ")), orgAdminBatchSize)
+		batches[search] = true
+	}
+	suite.Len(batches, 3)
+	suite.Equal(12, suite.accountsCalls)
+
+	users, err = suite.listOrgUsers("123", models.UserV3Query{Limit: 25, Offset: 5, SortOrder: "des", AdminOnly: true})
+	suite.Nil(err)
+	suite.Len(users.Users, 20)
+	suite.Equal("019", users.Users[0].ID)
+	suite.Equal("000", users.Users[19].ID)
+}
+
+func (suite *ConnectionTestSuite) TestListOrgUsersAdminOnlyNoAdmins() {
+	users, err := suite.listOrgUsers("456", models.UserV3Query{Limit: 10, Offset: 1, AdminOnly: true})
+	suite.Nil(err)
+	suite.Len(users.Users, 0)
+	suite.Equal(0, users.Total)
+	suite.Equal(0, suite.accountsCalls)
+}
+
+func (suite *ConnectionTestSuite) getUsers() (models.Users, error) {
+	client := &SDK{}
+	err := client.Connect(context.Background())
+	if err != nil {
+		return models.Users{}, err
+	}
+
+	return client.GetUsers(models.UserBody{Users: []string{"me"}}, models.UserV1Query{})
+}
+
+func (suite *ConnectionTestSuite) TestSharedConnection() {
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			users, err := suite.getUsers()
+			suite.Nil(err)
+			suite.Len(users.Users, 1)
+		}()
+	}
+	wg.Wait()
+
+	// the one token for every request
+	suite.Equal(1, suite.tokenCalls)
+	suite.Equal(5, suite.accountsCalls)
+}
+
+func (suite *ConnectionTestSuite) TestReconnectOnUnauthorized() {
+	_, err := suite.getUsers()
+	suite.Nil(err)
+
+	suite.unauthorized = 1
+	users, err := suite.getUsers()
+	suite.Nil(err)
+	suite.Equal("me@example.com", users.Users[0].Email)
+
+	// a new connection with a new token for the retry
+	suite.Equal(2, suite.tokenCalls)
+	suite.Equal(3, suite.accountsCalls)
+}
+
+func (suite *ConnectionTestSuite) TestUnauthorizedAfterReconnect() {
+	suite.unauthorized = 2
+
+	_, err := suite.getUsers()
+	suite.NotNil(err)
+	suite.True(isAuthFailure(err))
+
+	// only the one retry
+	suite.Equal(2, suite.accountsCalls)
+}
+
+func (suite *ConnectionTestSuite) TestReconnectOnTokenFailure() {
+	suite.tokenRejects = 1
+
+	users, err := suite.getUsers()
+	suite.Nil(err)
+	suite.Equal("me@example.com", users.Users[0].Email)
+
+	// AMS is only called once there's a token
+	suite.Equal(2, suite.tokenCalls)
+	suite.Equal(1, suite.accountsCalls)
+}
+
+func (suite *ConnectionTestSuite) TestTokenFailureAfterReconnect() {
+	suite.tokenRejects = 2
+
+	_, err := suite.getUsers()
+	suite.ErrorIs(err, errNoToken)
+	suite.Equal(0, suite.accountsCalls)
+}
+
+func (suite *ConnectionTestSuite) TestShutdown() {
+	_, err := suite.getUsers()
+	suite.Nil(err)
+
+	suite.Nil(Shutdown())
+	suite.Nil(shared)
+
+	_, err = suite.getUsers()
+	suite.Nil(err)
+	suite.Equal(2, suite.tokenCalls)
+}
@@ -3,69 +3,107 @@ package ocm
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 
 	sdk "github.com/openshift-online/ocm-sdk-go"
 	v1 "github.com/openshift-online/ocm-sdk-go/accountsmgmt/v1"
-	"github.com/openshift-online/ocm-sdk-go/logging"
 	"github.com/redhatinsights/mbop/internal/config"
+	l "github.com/redhatinsights/mbop/internal/logger"
 	"github.com/redhatinsights/mbop/internal/models"
 )
 
 const OrganizationID = "organization.id"
 
+// the role binding lookups for GetOrgAdmin, and the admin_only account
+// lookups
+const (
+	orgAdminBatchSize   = 50
+	orgAdminConcurrency = 4
+	orgAdminPageSize    = 100
+)
+
 type SDK struct {
+	ctx context.Context
+
+	mu     sync.Mutex
 	client *sdk.Connection
 }
 
-func (ocm *SDK) InitSdkConnection(ctx context.Context) error {
-	// Create a logger that has the debug level enabled:
-	logger, err := logging.NewGoLoggerBuilder().
-		Debug(config.Get().Debug).
-		Build()
-
+// Connect picks up the shared connection for requests made with ctx, building
+// it if this is the first request to need it. There's nothing to close after,
+// the shared connection is closed by Shutdown.
+func (ocm *SDK) Connect(ctx context.Context) error {
+	client, err := connection()
 	if err != nil {
 		return err
 	}
 
-	ocm.client, err = sdk.NewConnectionBuilder().
-		Logger(logger).
+	ocm.ctx = ctx
+	ocm.setClient(client)
+	return nil
+}
+
+func (ocm *SDK) getClient() *sdk.Connection {
+	ocm.mu.Lock()
+	defer ocm.mu.Unlock()
+	return ocm.client
+}
 
-		// SA Auth:
-		Client(config.Get().CognitoAppClientID, config.Get().CognitoAppClientSecret).
+func (ocm *SDK) setClient(client *sdk.Connection) {
+	ocm.mu.Lock()
+	defer ocm.mu.Unlock()
+	ocm.client = client
+}
 
-		// Offline Token Auth:
-		// Tokens(<token>).
+// runs send with the connection, and again on a new connection if it failed
+// because of the token
+func (ocm *SDK) retryAuth(send func(client *sdk.Connection) error) error {
+	attempt := func(client *sdk.Connection) error {
+		if err := ensureToken(ocm.ctx, client); err != nil {
+			return err
+		}
+		return send(client)
+	}
 
-		// Oauth Token URL:
-		TokenURL(config.Get().OauthTokenURL).
+	client := ocm.getClient()
 
-		// Route to hit for AMS:
-		URL(config.Get().AmsURL).
+	err := attempt(client)
+	if !isAuthFailure(err) {
+		return err
+	}
 
-		// SA Scopes:
-		Scopes(config.Get().CognitoScope).
-		BuildContext(ctx)
+	l.Log.Info("sdk request unauthorized, reconnecting", "error", err.Error())
+	resetConnection(client)
 
+	client, err = connection()
 	if err != nil {
 		return err
 	}
+	ocm.setClient(client)
 
-	return nil
+	return attempt(client)
 }
 
 func (ocm *SDK) GetUsers(usernames models.UserBody, q models.UserV1Query) (models.Users, error) {
 	search := createSearchString(usernames)
-	collection := ocm.client.AccountsMgmt().
-		V1().
-		Accounts().
-		List().
-		Parameter("fetchLabels", true).
-		Search(search)
-
-	collection = collection.Order(createQueryOrder(q))
+	sortOrder := createQueryOrder(q)
+
+	var usersResponse *v1.AccountsListResponse
+	err := ocm.retryAuth(func(client *sdk.Connection) (err error) {
+		usersResponse, err = client.AccountsMgmt().
+			V1().
+			Accounts().
+			List().
+			Parameter("fetchLabels", true).
+			Search(search).
+			Order(sortOrder).
+			SendContext(ocm.ctx)
+		return err
+	})
 
 	users := models.Users{Users: []models.User{}}
-	usersResponse, err := collection.Send()
 	if err != nil {
 		return users, err
 	}
@@ -79,74 +117,251 @@ func (ocm *SDK) GetUsers(usernames models.UserBody, q models.UserV1Query) (model
 	return users, err
 }
 
+/*
+GetOrgAdmin looks up which of the users are org admins. The users are split
+into batches of orgAdminBatchSize, keeping each search string well short of
+URL length limits, with up to orgAdminConcurrency batches looked up at once.
+Every page of each batch's role bindings is read, so nothing's silently left
+out.
+*/
 func (ocm *SDK) GetOrgAdmin(u []models.User) (models.OrgAdminResponse, error) {
-	search := createOrgAdminSearchString(u)
+	orgAdminResponse := models.OrgAdminResponse{}
+	var mu sync.Mutex
 
-	collection := ocm.client.AccountsMgmt().V1().RoleBindings()
-	roleBindings, err := collection.List().Search(search).Send()
+	err := inBatches(len(u), func(start, end int) error {
+		admins, err := ocm.getOrgAdminBatch(u[start:end])
+		if err != nil {
+			return err
+		}
 
-	orgAdminResponse := models.OrgAdminResponse{}
+		mu.Lock()
+		defer mu.Unlock()
+		for id, admin := range admins {
+			orgAdminResponse[id] = admin
+		}
+		return nil
+	})
 	if err != nil {
-		return orgAdminResponse, err
+		return models.OrgAdminResponse{}, err
 	}
 
-	if roleBindings.Items().Empty() {
-		return orgAdminResponse, err
-	}
+	return orgAdminResponse, nil
+}
 
-	bindingSlice := roleBindings.Items().Slice()
-	for _, binding := range bindingSlice {
-		orgAdminResponse[binding.Account().ID()] = models.OrgAdmin{
-			ID:         binding.Account().ID(),
-			IsOrgAdmin: true,
+// calls each with the bounds of every orgAdminBatchSize batch of n items, up
+// to orgAdminConcurrency at once, returning the first error
+func inBatches(n int, each func(start, end int) error) error {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	limit := make(chan struct{}, orgAdminConcurrency)
+
+	for start := 0; start < n; start += orgAdminBatchSize {
+		end := start + orgAdminBatchSize
+		if end > n {
+			end = n
 		}
+
+		wg.Add(1)
+		limit <- struct{}{}
+		go func(start, end int) {
+			defer wg.Done()
+			defer func() { <-limit }()
+
+			if err := each(start, end); err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}(start, end)
 	}
 
-	return orgAdminResponse, err
+	wg.Wait()
+	return firstErr
 }
 
-func (ocm *SDK) GetAccountV3Users(orgID string, q models.UserV3Query) (models.Users, error) {
-	search := createAccountsV3UsersSearchString(orgID)
+// reads the org admin role bindings for one batch of users
+func (ocm *SDK) getOrgAdminBatch(u []models.User) (models.OrgAdminResponse, error) {
+	return ocm.listOrgAdminBindings(createOrgAdminSearchString(u))
+}
 
-	collection := ocm.client.AccountsMgmt().V1().Accounts().List().Search(search)
+// reads every page of the org admin role bindings matching the search
+func (ocm *SDK) listOrgAdminBindings(search string) (models.OrgAdminResponse, error) {
+	orgAdminResponse := models.OrgAdminResponse{}
 
-	collection = collection.Order(createV3QueryOrder(q))
-	collection = collection.Size(q.Limit)
-	collection = collection.Page(q.Offset)
+	for page, read := 1, 0; ; page++ {
+		var roleBindings *v1.RoleBindingsListResponse
+		err := ocm.retryAuth(func(client *sdk.Connection) (err error) {
+			roleBindings, err = client.AccountsMgmt().V1().RoleBindings().List().
+				Search(search).
+				Page(page).
+				Size(orgAdminPageSize).
+				SendContext(ocm.ctx)
+			return err
+		})
+		if err != nil {
+			return orgAdminResponse, err
+		}
 
-	users := models.Users{Users: []models.User{}}
-	AccountV3UsersResponse, err := collection.Send()
-	if err != nil {
-		return users, err
-	}
+		items := roleBindings.Items()
+		items.Each(func(binding *v1.RoleBinding) bool {
+			orgAdminResponse[binding.Account().ID()] = models.OrgAdmin{
+				ID:         binding.Account().ID(),
+				IsOrgAdmin: true,
+			}
+			return true
+		})
 
-	users = responseToUsers(AccountV3UsersResponse)
+		// going by the total when AMS sends one, otherwise until a page comes
+		// back empty (AMS can cap the page size below what was asked for)
+		read += items.Len()
+		total, ok := roleBindings.GetTotal()
+		if items.Empty() || (ok && read >= total) {
+			return orgAdminResponse, nil
+		}
+	}
+}
 
-	return users, err
+func (ocm *SDK) GetAccountV3Users(orgID string, q models.UserV3Query) (models.Users, error) {
+	return ocm.listAccounts(orgID, createAccountsV3UsersSearchString(orgID), q)
 }
 
 func (ocm *SDK) GetAccountV3UsersBy(orgID string, q models.UserV3Query, body models.UsersByBody) (models.Users, error) {
-	search := createAccountsV3UsersBySearchString(orgID, body)
+	return ocm.listAccounts(orgID, createAccountsV3UsersBySearchString(orgID, body), q)
+}
+
+// a page of the accounts matching the search. For admin_only the search is
+// joined with the org's admin role bindings before paging, so the pages and
+// total only count admins.
+func (ocm *SDK) listAccounts(orgID string, search string, q models.UserV3Query) (models.Users, error) {
+	users := models.Users{Users: []models.User{}}
+
+	if q.AdminOnly {
+		admins, err := ocm.listOrgAdminBindings(createOrgAdminsForOrgSearchString(orgID))
+		if err != nil {
+			return users, err
+		}
+		if len(admins) == 0 {
+			return users, nil
+		}
 
-	collection := ocm.client.AccountsMgmt().V1().Accounts().List().Search(search)
+		return ocm.listAdminAccounts(search, admins, q)
+	}
 
-	collection = collection.Order(createV3QueryOrder(q))
-	collection = collection.Size(q.Limit)
-	collection = collection.Page(q.Offset)
+	sortOrder := createV3QueryOrder(q)
 
-	users := models.Users{Users: []models.User{}}
-	AccountV3UsersResponse, err := collection.Send()
+	var AccountV3UsersResponse *v1.AccountsListResponse
+	err := ocm.retryAuth(func(client *sdk.Connection) (err error) {
+		AccountV3UsersResponse, err = client.AccountsMgmt().V1().Accounts().List().
+			Search(search).
+			Order(sortOrder).
+			Size(q.Limit).
+			Page(q.Offset).
+			SendContext(ocm.ctx)
+		return err
+	})
 	if err != nil {
 		return users, err
 	}
 
 	users = responseToUsers(AccountV3UsersResponse)
+	users.Total = AccountV3UsersResponse.Total()
 
 	return users, err
 }
 
-func (ocm *SDK) CloseSdkConnection() {
-	ocm.client.Close()
+/*
+listAdminAccounts is listAccounts for admin_only. A search with every admin's
+id in it would run into the same URL length limits GetOrgAdmin avoids, so the
+ids are looked up in the same batches, with every matching account read and
+the page cut out of them here rather than by AMS.
+*/
+func (ocm *SDK) listAdminAccounts(search string, admins models.OrgAdminResponse, q models.UserV3Query) (models.Users, error) {
+	ids := make([]string, 0, len(admins))
+	for id := range admins {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var (
+		mu       sync.Mutex
+		accounts []models.User
+	)
+	err := inBatches(len(ids), func(start, end int) error {
+		batch, err := ocm.listAllAccounts(search + " and " + createAccountIDsSearchString(ids[start:end]))
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		accounts = append(accounts, batch...)
+		return nil
+	})
+	if err != nil {
+		return models.Users{Users: []models.User{}}, err
+	}
+
+	// the same order AMS would have paged them in, by org then id
+	sort.Slice(accounts, func(i, j int) bool {
+		a, b := accounts[i], accounts[j]
+		if q.SortOrder == "des" {
+			a, b = b, a
+		}
+		if a.OrgID != b.OrgID {
+			return a.OrgID < b.OrgID
+		}
+		return a.ID < b.ID
+	})
+
+	// AMS pages start at 1, with offset being the page
+	page := q.Offset
+	if page < 1 {
+		page = 1
+	}
+	start, end := (page-1)*q.Limit, page*q.Limit
+	if start > len(accounts) {
+		start = len(accounts)
+	}
+	if end > len(accounts) {
+		end = len(accounts)
+	}
+
+	users := models.Users{Users: []models.User{}, Total: len(accounts)}
+	users.Users = append(users.Users, accounts[start:end]...)
+	return users, nil
+}
+
+// reads every page of the accounts matching the search
+func (ocm *SDK) listAllAccounts(search string) ([]models.User, error) {
+	var accounts []models.User
+
+	for page, read := 1, 0; ; page++ {
+		var response *v1.AccountsListResponse
+		err := ocm.retryAuth(func(client *sdk.Connection) (err error) {
+			response, err = client.AccountsMgmt().V1().Accounts().List().
+				Search(search).
+				Page(page).
+				Size(orgAdminPageSize).
+				SendContext(ocm.ctx)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, responseToUsers(response).Users...)
+
+		read += response.Items().Len()
+		total, ok := response.GetTotal()
+		if response.Items().Empty() || (ok && read >= total) {
+			return accounts, nil
+		}
+	}
 }
 
 func getIsInternal(user *v1.Account) bool {
@@ -213,6 +428,20 @@ func createOrgAdminSearchString(users []models.User) string {
 	return search
 }
 
+func createOrgAdminsForOrgSearchString(orgID string) string {
+	return fmt.Sprintf(OrganizationID+"='%s' and role.id='OrganizationAdmin'", orgID)
+}
+
+// matches any of the accounts with the ids
+func createAccountIDsSearchString(ids []string) string {
+	quoted := make([]string, len(ids))
+	for i, id := range ids {
+		quoted[i] = fmt.Sprintf("'%s'", id)
+	}
+
+	return fmt.Sprintf("id in (%s)", strings.Join(quoted, ", "))
+}
+
 func createAccountsV3UsersSearchString(orgID string) string {
 	return fmt.Sprintf(OrganizationID+"='%s'", orgID)
 }
@@ -249,9 +478,9 @@ func createQueryOrder(q models.UserV1Query) string {
 	return order
 }
 
-var order = OrganizationID
-
 func createV3QueryOrder(q models.UserV3Query) string {
+	order := OrganizationID
+
 	if q.SortOrder != "" {
 		order += fmt.Sprint(" " + q.SortOrder)
 	}
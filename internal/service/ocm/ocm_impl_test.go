@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/redhatinsights/mbop/internal/config"
+	"github.com/redhatinsights/mbop/internal/models"
 
 	v1 "github.com/openshift-online/ocm-sdk-go/accountsmgmt/v1"
 	"github.com/stretchr/testify/suite"
@@ -63,6 +64,17 @@ func (suite *OcmImplTestSuite) TestGetIsInternalNoValMatch() {
 	suite.Equal(false, getIsInternal(acct))
 }
 
+func (suite *OcmImplTestSuite) TestCreateV3QueryOrder() {
+	// the same order every time, not built up across requests
+	suite.Equal("organization.id asc", createV3QueryOrder(models.UserV3Query{SortOrder: "asc"}))
+	suite.Equal("organization.id asc", createV3QueryOrder(models.UserV3Query{SortOrder: "asc"}))
+	suite.Equal("organization.id", createV3QueryOrder(models.UserV3Query{}))
+}
+
+func (suite *OcmImplTestSuite) TestCreateAccountIDsSearchString() {
+	suite.Equal("id in ('a', 'b')", createAccountIDsSearchString([]string{"a", "b"}))
+}
+
 func TestOcmImp(t *testing.T) {
 	suite.Run(t, new(OcmImplTestSuite))
 }
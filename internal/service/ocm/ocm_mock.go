@@ -13,7 +13,7 @@ import (
 
 type SDKMock struct{}
 
-func (ocm *SDKMock) InitSdkConnection(_ context.Context) error {
+func (ocm *SDKMock) Connect(_ context.Context) error {
 	return nil
 }
 
@@ -37,7 +37,7 @@ func (ocm *SDKMock) GetUsers(u models.UserBody, _ models.UserV1Query) (models.Us
 		users.AddUser(models.User{
 			Username:      user,
 			ID:            uuid.New().String(),
-			Email:         "lub@dub.com",
+			Email:         user + "@mocked.biz",
 			FirstName:     "test",
 			LastName:      "case",
 			AddressString: "https://usersTest.com",
@@ -107,6 +107,8 @@ func (ocm *SDKMock) GetAccountV3Users(orgID string, q models.UserV3Query) (model
 		})
 	}
 
+	// every mocked user is an org admin, so admin_only doesn't change anything
+	users.Total = len(users.Users)
 	return users, nil
 }
 
@@ -143,9 +145,7 @@ func (ocm *SDKMock) GetAccountV3UsersBy(orgID string, q models.UserV3Query, _ mo
 		})
 	}
 
+	// every mocked user is an org admin, so admin_only doesn't change anything
+	users.Total = len(users.Users)
 	return users, nil
 }
-
-func (ocm *SDKMock) CloseSdkConnection() {
-	// nil
-}
@@ -0,0 +1,75 @@
+package userprovider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redhatinsights/mbop/internal/models"
+	"github.com/redhatinsights/mbop/internal/service/keycloak"
+	keycloakuserservice "github.com/redhatinsights/mbop/internal/service/keycloak-user-service"
+)
+
+// keycloakProvider looks users up in the keycloak user service, authenticating
+// with a token from keycloak itself
+type keycloakProvider struct{}
+
+func newKeycloakProvider() (UserProvider, error) {
+	return &keycloakProvider{}, nil
+}
+
+func (p *keycloakProvider) GetUsers(_ context.Context, usernames models.UserBody, q models.UserV1Query) (models.Users, error) {
+	client, token, err := p.connect()
+	if err != nil {
+		return models.Users{}, err
+	}
+	return client.GetUsers(token, usernames, q)
+}
+
+func (p *keycloakProvider) ListOrgUsers(_ context.Context, orgID string, q models.UserV3Query) (models.Users, error) {
+	client, token, err := p.connect()
+	if err != nil {
+		return models.Users{}, err
+	}
+	return client.GetAccountV3Users(orgID, token, q)
+}
+
+func (p *keycloakProvider) SearchOrgUsers(_ context.Context, orgID string, q models.UserV3Query, body models.UsersByBody) (models.Users, error) {
+	client, token, err := p.connect()
+	if err != nil {
+		return models.Users{}, err
+	}
+	return client.GetAccountV3UsersBy(orgID, token, q, body)
+}
+
+// IsOrgAdmin doesn't need to ask keycloak again, the user service already
+// says whether each user it returns is an org admin
+func (p *keycloakProvider) IsOrgAdmin(_ context.Context, users []models.User) (models.OrgAdminResponse, error) {
+	admins := models.OrgAdminResponse{}
+	for _, user := range users {
+		if user.IsOrgAdmin {
+			admins[user.ID] = models.OrgAdmin{ID: user.ID, IsOrgAdmin: true}
+		}
+	}
+	return admins, nil
+}
+
+func (p *keycloakProvider) connect() (keycloakuserservice.KeyCloakUserService, string, error) {
+	keycloakClient := keycloak.NewKeyCloakClient()
+	err := keycloakClient.InitKeycloakConnection()
+	if err != nil {
+		return nil, "", fmt.Errorf("can't build keycloak connection: %w", err)
+	}
+
+	token, err := keycloakClient.GetAccessToken()
+	if err != nil {
+		return nil, "", fmt.Errorf("can't fetch keycloak token: %w", err)
+	}
+
+	client := &keycloakuserservice.UserServiceClient{}
+	err = client.InitKeycloakUserServiceConnection()
+	if err != nil {
+		return nil, "", fmt.Errorf("can't build keycloak user service connection: %w", err)
+	}
+
+	return client, token, nil
+}
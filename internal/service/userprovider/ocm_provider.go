@@ -0,0 +1,75 @@
+package userprovider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redhatinsights/mbop/internal/models"
+	"github.com/redhatinsights/mbop/internal/service/ocm"
+)
+
+// ocmProvider looks users up in AMS through the ocm sdk, or the sdk's mock
+type ocmProvider struct {
+	newClient func() ocm.OCM
+}
+
+func newAMSProvider() (UserProvider, error) {
+	return &ocmProvider{newClient: func() ocm.OCM { return &ocm.SDK{} }}, nil
+}
+
+func newMockProvider() (UserProvider, error) {
+	return &ocmProvider{newClient: func() ocm.OCM { return &ocm.SDKMock{} }}, nil
+}
+
+func (p *ocmProvider) GetUsers(ctx context.Context, usernames models.UserBody, q models.UserV1Query) (models.Users, error) {
+	var users models.Users
+	err := p.withClient(ctx, func(client ocm.OCM) (err error) {
+		users, err = client.GetUsers(usernames, q)
+		return err
+	})
+	return users, err
+}
+
+func (p *ocmProvider) ListOrgUsers(ctx context.Context, orgID string, q models.UserV3Query) (models.Users, error) {
+	var users models.Users
+	err := p.withClient(ctx, func(client ocm.OCM) (err error) {
+		users, err = client.GetAccountV3Users(orgID, q)
+		return err
+	})
+	return users, err
+}
+
+func (p *ocmProvider) SearchOrgUsers(ctx context.Context, orgID string, q models.UserV3Query, body models.UsersByBody) (models.Users, error) {
+	var users models.Users
+	err := p.withClient(ctx, func(client ocm.OCM) (err error) {
+		users, err = client.GetAccountV3UsersBy(orgID, q, body)
+		return err
+	})
+	return users, err
+}
+
+func (p *ocmProvider) IsOrgAdmin(ctx context.Context, users []models.User) (models.OrgAdminResponse, error) {
+	admins := models.OrgAdminResponse{}
+	if len(users) == 0 {
+		return admins, nil
+	}
+
+	err := p.withClient(ctx, func(client ocm.OCM) (err error) {
+		admins, err = client.GetOrgAdmin(users)
+		return err
+	})
+	return admins, err
+}
+
+// runs f with a client on the shared sdk connection, making its requests with
+// ctx
+func (p *ocmProvider) withClient(ctx context.Context, f func(ocm.OCM) error) error {
+	client := p.newClient()
+
+	err := client.Connect(ctx)
+	if err != nil {
+		return fmt.Errorf("can't build sdk connection: %w", err)
+	}
+
+	return f(client)
+}
@@ -0,0 +1,91 @@
+package userprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/redhatinsights/mbop/internal/config"
+	"github.com/redhatinsights/mbop/internal/models"
+)
+
+var ErrUnsupportedModule = errors.New("unsupported users module")
+
+/*
+UserProvider is a backend users are looked up in, picked with USERS_MODULE:
+- GetUsers; users by username
+- ListOrgUsers; a page of an org's users
+- SearchOrgUsers; a page of an org's users matching the email/username filters
+- IsOrgAdmin; which of the users are org admins, keyed by user id
+*/
+type UserProvider interface {
+	GetUsers(ctx context.Context, usernames models.UserBody, q models.UserV1Query) (models.Users, error)
+	ListOrgUsers(ctx context.Context, orgID string, q models.UserV3Query) (models.Users, error)
+	SearchOrgUsers(ctx context.Context, orgID string, q models.UserV3Query, body models.UsersByBody) (models.Users, error)
+	IsOrgAdmin(ctx context.Context, users []models.User) (models.OrgAdminResponse, error)
+}
+
+// Factory builds a provider, called each time one is needed
+type Factory func() (UserProvider, error)
+
+var (
+	factories   = make(map[string]Factory)
+	factoriesMu sync.RWMutex
+)
+
+func init() {
+	Register("ams", newAMSProvider)
+	Register("mock", newMockProvider)
+	Register("keycloak", newKeycloakProvider)
+}
+
+// Register makes a provider available under a USERS_MODULE name, panicking if
+// the name is already taken
+func Register(module string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+
+	if _, ok := factories[module]; ok {
+		panic(fmt.Sprintf("users module %q registered twice", module))
+	}
+	factories[module] = factory
+}
+
+// Registered is whether there's a provider for the users module
+func Registered(module string) bool {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	_, ok := factories[module]
+	return ok
+}
+
+// Modules is every registered users module, sorted
+func Modules() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	modules := make([]string, 0, len(factories))
+	for m := range factories {
+		modules = append(modules, m)
+	}
+	sort.Strings(modules)
+	return modules
+}
+
+// NewUserProvider builds the provider for the configured USERS_MODULE
+func NewUserProvider() (UserProvider, error) {
+	module := config.Get().UsersModule
+
+	factoriesMu.RLock()
+	factory, ok := factories[module]
+	factoriesMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnsupportedModule, module)
+	}
+
+	return factory()
+}
@@ -0,0 +1,113 @@
+package userprovider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/redhatinsights/mbop/internal/config"
+	"github.com/redhatinsights/mbop/internal/models"
+)
+
+type fakeProvider struct {
+	UserProvider
+}
+
+func withUsersModule(t *testing.T, module string) {
+	old := config.Get().UsersModule
+	config.Get().UsersModule = module
+	t.Cleanup(func() { config.Get().UsersModule = old })
+}
+
+func TestBuiltinModules(t *testing.T) {
+	want := []string{"ams", "keycloak", "mock"}
+	for _, module := range want {
+		if !Registered(module) {
+			t.Errorf("module %q not registered", module)
+		}
+	}
+	if Registered("nope") {
+		t.Error(`module "nope" registered`)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("fake", func() (UserProvider, error) { return &fakeProvider{}, nil })
+	t.Cleanup(func() { delete(factories, "fake") })
+
+	withUsersModule(t, "fake")
+
+	provider, err := NewUserProvider()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, ok := provider.(*fakeProvider); !ok {
+		t.Errorf("got a %T, want the registered provider", provider)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a module twice didn't panic")
+		}
+	}()
+	Register("fake", func() (UserProvider, error) { return &fakeProvider{}, nil })
+}
+
+func TestNewUserProviderUnsupported(t *testing.T) {
+	withUsersModule(t, "nope")
+
+	_, err := NewUserProvider()
+	if !errors.Is(err, ErrUnsupportedModule) {
+		t.Errorf("got %v, want ErrUnsupportedModule", err)
+	}
+	if err.Error() != `unsupported users module "nope"` {
+		t.Errorf("unexpected error message %q", err)
+	}
+}
+
+func TestMockProvider(t *testing.T) {
+	withUsersModule(t, "mock")
+
+	provider, err := NewUserProvider()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	users, err := provider.GetUsers(context.Background(), models.UserBody{Users: []string{"me", "you"}}, models.UserV1Query{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(users.Users) != 2 || users.Users[0].Email != "me@mocked.biz" {
+		t.Errorf("unexpected users %+v", users.Users)
+	}
+
+	admins, err := provider.IsOrgAdmin(context.Background(), users.Users)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !admins[users.Users[0].ID].IsOrgAdmin {
+		t.Errorf("unexpected org admins %+v", admins)
+	}
+
+	admins, err = provider.IsOrgAdmin(context.Background(), nil)
+	if err != nil || len(admins) != 0 {
+		t.Errorf("got %+v, %v for no users", admins, err)
+	}
+}
+
+func TestKeycloakIsOrgAdmin(t *testing.T) {
+	provider, _ := newKeycloakProvider()
+
+	admins, err := provider.IsOrgAdmin(context.Background(), []models.User{
+		{ID: "1", IsOrgAdmin: true},
+		{ID: "2", IsOrgAdmin: false},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := models.OrgAdminResponse{"1": {ID: "1", IsOrgAdmin: true}}
+	if len(admins) != len(want) || admins["1"] != want["1"] {
+		t.Errorf("got %+v, want %+v", admins, want)
+	}
+}
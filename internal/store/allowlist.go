@@ -0,0 +1,84 @@
+package store
+
+import (
+	"net"
+	"strings"
+)
+
+// how an existing allowlist block relates to one being added
+const (
+	AllowlistDuplicate = "duplicate"
+	AllowlistSuperset  = "superset"
+	AllowlistSubset    = "subset"
+)
+
+// CanonicalBlock turns a single address or CIDR block into its canonical CIDR
+// form, e.g. `10.0.0.5/24` -> `10.0.0.0/24`, `2001:DB8::1` -> `2001:db8::1/128`
+func CanonicalBlock(block string) (string, error) {
+	ipnet, err := parseBlock(block)
+	if err != nil {
+		return "", err
+	}
+	return ipnet.String(), nil
+}
+
+// parses either a CIDR block or a single address, which gets a full-length
+// mask (/32 for IPv4, /128 for IPv6) the same way postgres' inet type does
+func parseBlock(block string) (*net.IPNet, error) {
+	block = strings.TrimSpace(block)
+	if !strings.Contains(block, "/") {
+		if strings.Contains(block, ":") {
+			block += "/128"
+		} else {
+			block += "/32"
+		}
+	}
+
+	_, ipnet, err := net.ParseCIDR(block)
+	return ipnet, err
+}
+
+// blockRelation is how the existing block relates to the candidate, or empty
+// if they don't overlap at all. Blocks of different families never overlap,
+// matching postgres' inet operators.
+func blockRelation(existing, candidate *net.IPNet) string {
+	existingOnes, existingBits := existing.Mask.Size()
+	candidateOnes, candidateBits := candidate.Mask.Size()
+	if existingBits != candidateBits {
+		return ""
+	}
+
+	switch {
+	case existingOnes == candidateOnes && existing.IP.Equal(candidate.IP):
+		return AllowlistDuplicate
+	case existingOnes < candidateOnes && existing.Contains(candidate.IP):
+		return AllowlistSuperset
+	case candidateOnes < existingOnes && candidate.Contains(existing.IP):
+		return AllowlistSubset
+	}
+	return ""
+}
+
+// checks a whole set of blocks for overlaps amongst themselves, each conflict
+// being an earlier block in the set against a later one
+func overlappingBlocks(blocks []AllowlistBlock) ([]AllowlistConflict, error) {
+	parsed := make([]*net.IPNet, len(blocks))
+	for i := range blocks {
+		ipnet, err := parseBlock(blocks[i].IPBlock)
+		if err != nil {
+			return nil, err
+		}
+		parsed[i] = ipnet
+	}
+
+	conflicts := make([]AllowlistConflict, 0)
+	for i := range parsed {
+		for j := 0; j < i; j++ {
+			if rel := blockRelation(parsed[j], parsed[i]); rel != "" {
+				conflicts = append(conflicts, AllowlistConflict{IPBlock: blocks[j].IPBlock, Relation: rel, Candidate: blocks[i].IPBlock})
+			}
+		}
+	}
+
+	return conflicts, nil
+}
@@ -0,0 +1,32 @@
+package store
+
+import (
+	"testing"
+)
+
+func TestCanonicalBlock(t *testing.T) {
+	cases := map[string]string{
+		"10.0.0.1":         "10.0.0.1/32",
+		"10.0.0.5/24":      "10.0.0.0/24",
+		" 192.168.1.1/16 ": "192.168.0.0/16",
+		"2001:DB8::1":      "2001:db8::1/128",
+		"2001:db8::1/32":   "2001:db8::/32",
+		"::1":              "::1/128",
+	}
+
+	for in, expected := range cases {
+		out, err := CanonicalBlock(in)
+		if err != nil {
+			t.Errorf("unexpected error canonicalizing %q: %v", in, err)
+		}
+		if out != expected {
+			t.Errorf("canonicalizing %q, expected %q got %q", in, expected, out)
+		}
+	}
+
+	for _, in := range []string{"", "not an ip", "10.0.0.1/33", "2001:db8::1/129", "10.0.0.1, 10.0.0.2"} {
+		if _, err := CanonicalBlock(in); err == nil {
+			t.Errorf("expected error canonicalizing %q", in)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package store
 
 import (
 	"errors"
+	"fmt"
 	"reflect"
+	"strings"
 )
 
 var (
@@ -25,3 +27,44 @@ func (e ErrRegistrationAlreadyExists) Error() string {
 func (e ErrRegistrationAlreadyExists) Is(err error) bool {
 	return reflect.TypeOf(err) == reflect.TypeOf(e)
 }
+
+// error type returned when an org is already at its limit for a resource
+type ErrQuotaExceeded struct {
+	Resource string
+	Limit    int
+}
+
+func (e ErrQuotaExceeded) Error() string {
+	return fmt.Sprintf("quota exceeded: org is limited to %d %s", e.Limit, e.Resource)
+}
+
+func (e ErrQuotaExceeded) Is(err error) bool {
+	return reflect.TypeOf(err) == reflect.TypeOf(e)
+}
+
+// AllowlistConflict is an existing block (IPBlock) that overlaps with one
+// being added (Candidate), Relation being one of the Allowlist* relations
+// describing the existing block
+type AllowlistConflict struct {
+	IPBlock   string
+	Relation  string
+	Candidate string
+}
+
+// error type returned when blocks being added overlap with each other or with
+// blocks already allowlisted for the org
+type ErrAllowlistConflict struct {
+	Conflicts []AllowlistConflict
+}
+
+func (e ErrAllowlistConflict) Error() string {
+	overlaps := make([]string, len(e.Conflicts))
+	for i, c := range e.Conflicts {
+		overlaps[i] = fmt.Sprintf("%s is a %s of %s", c.IPBlock, c.Relation, c.Candidate)
+	}
+	return "ip blocks overlap: " + strings.Join(overlaps, ", ")
+}
+
+func (e ErrAllowlistConflict) Is(err error) bool {
+	return reflect.TypeOf(err) == reflect.TypeOf(e)
+}
@@ -1,28 +1,125 @@
 package store
 
 import (
-	"net"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
+// inMemoryStore keeps everything in memory. The purgers modify it from their
+// own goroutines, so every method holds mu.
 type inMemoryStore struct {
+	mu sync.RWMutex
+
 	db               []Registration
 	allowedAddresses []AllowlistBlock
+	audit            []AuditEntry
+	defaultQuota     QuotaLimits
+	quotaOverrides   map[string]QuotaOverride
+	revocations      []TokenRevocation
 }
 
-func (m *inMemoryStore) All(orgID string, _, _ int) ([]Registration, int, error) {
+func (m *inMemoryStore) All(orgID string, limit, offset int, filter *RegistrationFilter) ([]Registration, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	out := make([]Registration, 0)
 	for i := range m.db {
-		if m.db[i].OrgID == orgID {
+		if m.db[i].OrgID == orgID && m.db[i].DeletedAt == nil && matchesFilter(&m.db[i], filter) {
 			out = append(out, m.db[i])
 		}
 	}
-	return out, len(out), nil
+
+	sortBy, sortOrder := "created_at", "desc"
+	if filter != nil && filter.SortBy != "" {
+		sortBy = filter.SortBy
+	}
+	if filter != nil && filter.SortOrder != "" {
+		sortOrder = filter.SortOrder
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if sortOrder == "asc" {
+			return registrationLess(&out[i], &out[j], sortBy)
+		}
+		return registrationLess(&out[j], &out[i], sortBy)
+	})
+
+	start, end := pageBounds(len(out), limit, offset)
+	return out[start:end], len(out), nil
+}
+
+// where the page of limit items from offset starts and ends in a list of
+// count, clamped to the list so a bad limit or offset can't go out of bounds
+func pageBounds(count, limit, offset int) (int, int) {
+	if offset < 0 {
+		offset = 0
+	}
+	if limit < 0 {
+		limit = 0
+	}
+	if offset > count {
+		offset = count
+	}
+	end := count
+	if limit < count-offset {
+		end = offset + limit
+	}
+	return offset, end
+}
+
+func registrationLess(a, b *Registration, field string) bool {
+	switch field {
+	case "updated_at":
+		return a.UpdatedAt.Before(b.UpdatedAt)
+	case "display_name":
+		return a.DisplayName < b.DisplayName
+	case "uid":
+		return a.UID < b.UID
+	case "username":
+		return a.Username < b.Username
+	default:
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+}
+
+func matchesFilter(r *Registration, filter *RegistrationFilter) bool {
+	if filter == nil {
+		return true
+	}
+
+	if filter.DisplayName != "" && !strings.Contains(strings.ToLower(r.DisplayName), strings.ToLower(filter.DisplayName)) {
+		return false
+	}
+	if filter.Username != "" && r.Username != filter.Username {
+		return false
+	}
+	if !filter.CreatedAfter.IsZero() && !r.CreatedAt.After(filter.CreatedAfter) {
+		return false
+	}
+	if !filter.CreatedBefore.IsZero() && !r.CreatedAt.Before(filter.CreatedBefore) {
+		return false
+	}
+
+	for k, v := range filter.Extra {
+		val, ok := r.Extra[k]
+		if !ok || fmt.Sprint(val) != v {
+			return false
+		}
+	}
+
+	return true
 }
 
 func (m *inMemoryStore) Find(orgID string, uid string) (*Registration, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	for _, r := range m.db {
-		if r.OrgID == orgID && r.UID == uid {
+		if r.OrgID == orgID && r.UID == uid && r.DeletedAt == nil {
 			return &r, nil
 		}
 	}
@@ -31,8 +128,11 @@ func (m *inMemoryStore) Find(orgID string, uid string) (*Registration, error) {
 }
 
 func (m *inMemoryStore) FindByUID(uid string) (*Registration, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	for _, r := range m.db {
-		if r.UID == uid {
+		if r.UID == uid && r.DeletedAt == nil {
 			return &r, nil
 		}
 	}
@@ -41,7 +141,16 @@ func (m *inMemoryStore) FindByUID(uid string) (*Registration, error) {
 }
 
 func (m *inMemoryStore) Create(r *Registration) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.create(r)
+}
+
+func (m *inMemoryStore) create(r *Registration) (string, error) {
 	for i := range m.db {
+		if m.db[i].DeletedAt != nil {
+			continue
+		}
 		if m.db[i].UID == r.UID {
 			return "", ErrRegistrationAlreadyExists{Detail: "uid already exists"}
 		}
@@ -50,26 +159,81 @@ func (m *inMemoryStore) Create(r *Registration) (string, error) {
 		}
 	}
 
+	if err := m.checkQuota(r.OrgID, QuotaRegistrations); err != nil {
+		return "", err
+	}
+
 	r.CreatedAt = time.Now()
+	r.UpdatedAt = r.CreatedAt
 	m.db = append(m.db, *r)
 	return "", nil
 }
 
+func (m *inMemoryStore) Import(regs []Registration) ([]ImportResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	results := make([]ImportResult, len(regs))
+	for i := range regs {
+		results[i] = ImportResult{UID: regs[i].UID, Status: ImportCreated}
+
+		_, err := m.create(&regs[i])
+		if err != nil {
+			if !errors.Is(err, ErrRegistrationAlreadyExists{}) && !errors.Is(err, ErrQuotaExceeded{}) {
+				return nil, err
+			}
+			results[i].Status = ImportConflict
+			results[i].Detail = err.Error()
+		}
+	}
+
+	return results, nil
+}
+
 func (m *inMemoryStore) Update(r *Registration, update *RegistrationUpdate) error {
-	r, err := m.Find(r.OrgID, r.UID)
-	if err != nil {
-		return err
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	idx := -1
+	for i := range m.db {
+		if m.db[i].OrgID == r.OrgID && m.db[i].UID == r.UID && m.db[i].DeletedAt == nil {
+			idx = i
+			break
+		}
+	}
+	if idx == -1 {
+		return ErrRegistrationNotFound
+	}
+
+	if update.DisplayName != nil {
+		for i := range m.db {
+			if i != idx && m.db[i].OrgID == r.OrgID && m.db[i].DeletedAt == nil && m.db[i].DisplayName == *update.DisplayName {
+				return ErrRegistrationAlreadyExists{Detail: "display_name already exists"}
+			}
+		}
+		m.db[idx].DisplayName = *update.DisplayName
 	}
 
-	r.Extra = *update.Extra
+	if update.Extra != nil {
+		m.db[idx].Extra = *update.Extra
+	}
 
+	if update.ExpiresAt != nil {
+		m.db[idx].ExpiresAt = update.ExpiresAt
+	}
+
+	m.db[idx].UpdatedAt = time.Now()
 	return nil
 }
 
 func (m *inMemoryStore) Delete(orgID string, uid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	for i := range m.db {
-		if m.db[i].OrgID == orgID || m.db[i].UID == uid {
-			m.db = append(m.db[:i], m.db[i+1:]...)
+		if m.db[i].OrgID == orgID && m.db[i].UID == uid && m.db[i].DeletedAt == nil {
+			now := time.Now()
+			m.db[i].DeletedAt = &now
 			return nil
 		}
 	}
@@ -77,23 +241,172 @@ func (m *inMemoryStore) Delete(orgID string, uid string) error {
 	return ErrRegistrationNotFound
 }
 
-func (m *inMemoryStore) AllowedAddresses(_ string) ([]AllowlistBlock, error) {
-	return m.allowedAddresses, nil
+func (m *inMemoryStore) RotateUID(orgID, oldUID, newUID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	idx := -1
+	for i := range m.db {
+		if m.db[i].DeletedAt != nil {
+			continue
+		}
+		if m.db[i].UID == newUID {
+			return ErrRegistrationAlreadyExists{Detail: "uid already exists"}
+		}
+		if m.db[i].OrgID == orgID && m.db[i].UID == oldUID {
+			idx = i
+		}
+	}
+	if idx == -1 {
+		return ErrRegistrationNotFound
+	}
+
+	m.db[idx].UID = newUID
+	m.db[idx].UpdatedAt = time.Now()
+	return nil
 }
-func (m *inMemoryStore) AllowedIP(ip, _ string) (bool, error) {
-	for _, addr := range m.allowedAddresses {
-		_, ipnet, _ := net.ParseCIDR(addr.IPBlock)
-		if ipnet.Contains(net.ParseIP(ip)) {
+
+func (m *inMemoryStore) Restore(orgID string, uid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	idx := -1
+	for i := range m.db {
+		if m.db[i].OrgID == orgID && m.db[i].UID == uid && m.db[i].DeletedAt != nil {
+			if idx == -1 || m.db[i].DeletedAt.After(*m.db[idx].DeletedAt) {
+				idx = i
+			}
+		}
+	}
+	if idx == -1 {
+		return ErrRegistrationNotFound
+	}
+
+	for i := range m.db {
+		if m.db[i].DeletedAt != nil {
+			continue
+		}
+		if m.db[i].UID == uid {
+			return ErrRegistrationAlreadyExists{Detail: "uid already exists"}
+		}
+		if m.db[i].OrgID == orgID && m.db[i].DisplayName == m.db[idx].DisplayName {
+			return ErrRegistrationAlreadyExists{Detail: "display_name already exists"}
+		}
+	}
+
+	if err := m.checkQuota(orgID, QuotaRegistrations); err != nil {
+		return err
+	}
+
+	m.db[idx].DeletedAt = nil
+	m.db[idx].UpdatedAt = time.Now()
+	return nil
+}
+
+func (m *inMemoryStore) PurgeDeleted(retention time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before := time.Now().Add(-retention)
+	kept := make([]Registration, 0, len(m.db))
+	for i := range m.db {
+		if m.db[i].DeletedAt == nil || !m.db[i].DeletedAt.Before(before) {
+			kept = append(kept, m.db[i])
+		}
+	}
+
+	purged := len(m.db) - len(kept)
+	m.db = kept
+	return purged, nil
+}
+
+func (m *inMemoryStore) AllowedAddresses(orgID string) ([]AllowlistBlock, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := make([]AllowlistBlock, 0)
+	for i := range m.allowedAddresses {
+		if m.allowedAddresses[i].OrgID == orgID {
+			out = append(out, m.allowedAddresses[i])
+		}
+	}
+	return out, nil
+}
+func (m *inMemoryStore) AllowedIP(ip, orgID string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	addr, err := parseBlock(ip)
+	if err != nil {
+		return false, nil
+	}
+
+	for i := range m.allowedAddresses {
+		if m.allowedAddresses[i].OrgID != orgID || m.allowedAddresses[i].Expired() {
+			continue
+		}
+
+		block, err := parseBlock(m.allowedAddresses[i].IPBlock)
+		if err != nil {
+			continue
+		}
+
+		switch blockRelation(block, addr) {
+		case AllowlistDuplicate, AllowlistSuperset:
 			return true, nil
 		}
 	}
 	return false, nil
 }
 func (m *inMemoryStore) AllowAddress(ip *AllowlistBlock) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	candidate, err := parseBlock(ip.IPBlock)
+	if err != nil {
+		return err
+	}
+
+	conflicts := make([]AllowlistConflict, 0)
+	for i := range m.allowedAddresses {
+		// expired blocks don't allow anything anymore
+		if m.allowedAddresses[i].OrgID != ip.OrgID || m.allowedAddresses[i].Expired() {
+			continue
+		}
+
+		block, err := parseBlock(m.allowedAddresses[i].IPBlock)
+		if err != nil {
+			continue
+		}
+
+		if rel := blockRelation(block, candidate); rel != "" {
+			conflicts = append(conflicts, AllowlistConflict{IPBlock: m.allowedAddresses[i].IPBlock, Relation: rel, Candidate: ip.IPBlock})
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].IPBlock < conflicts[j].IPBlock })
+		return ErrAllowlistConflict{Conflicts: conflicts}
+	}
+
+	if err := m.checkQuota(ip.OrgID, QuotaAllowlistBlocks); err != nil {
+		return err
+	}
+
+	ip.CreatedAt = time.Now()
+	// replacing the expired block if it's being added again
+	for i := range m.allowedAddresses {
+		if m.allowedAddresses[i].OrgID == ip.OrgID && m.allowedAddresses[i].IPBlock == ip.IPBlock {
+			m.allowedAddresses[i] = *ip
+			return nil
+		}
+	}
 	m.allowedAddresses = append(m.allowedAddresses, *ip)
 	return nil
 }
 func (m *inMemoryStore) DenyAddress(ip *AllowlistBlock) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	for i := range m.allowedAddresses {
 		if m.allowedAddresses[i].OrgID == ip.OrgID && m.allowedAddresses[i].IPBlock == ip.IPBlock {
 			m.allowedAddresses = append(m.allowedAddresses[:i], m.allowedAddresses[i+1:]...)
@@ -103,3 +416,188 @@ func (m *inMemoryStore) DenyAddress(ip *AllowlistBlock) error {
 
 	return ErrAddressNotAllowListed
 }
+
+func (m *inMemoryStore) ReplaceAllowlist(orgID string, blocks []AllowlistBlock) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	conflicts, err := overlappingBlocks(blocks)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return ErrAllowlistConflict{Conflicts: conflicts}
+	}
+
+	q, err := m.quota(orgID)
+	if err != nil {
+		return err
+	}
+	if q.Limits.AllowlistBlocks > 0 && len(blocks) > q.Limits.AllowlistBlocks {
+		return ErrQuotaExceeded{Resource: QuotaAllowlistBlocks, Limit: q.Limits.AllowlistBlocks}
+	}
+
+	existing := make(map[string]AllowlistBlock)
+	kept := make([]AllowlistBlock, 0, len(m.allowedAddresses))
+	for i := range m.allowedAddresses {
+		if m.allowedAddresses[i].OrgID == orgID {
+			existing[m.allowedAddresses[i].IPBlock] = m.allowedAddresses[i]
+		} else {
+			kept = append(kept, m.allowedAddresses[i])
+		}
+	}
+
+	now := time.Now()
+	for _, b := range blocks {
+		b.OrgID = orgID
+		b.CreatedAt = now
+		if prev, ok := existing[b.IPBlock]; ok {
+			b.CreatedAt = prev.CreatedAt
+			b.CreatedBy = prev.CreatedBy
+		}
+		kept = append(kept, b)
+	}
+
+	m.allowedAddresses = kept
+	return nil
+}
+
+func (m *inMemoryStore) RecordAudit(e *AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e.CreatedAt = time.Now()
+	m.audit = append(m.audit, *e)
+	return nil
+}
+
+func (m *inMemoryStore) AuditEntries(orgID string, limit, offset int) ([]AuditEntry, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := make([]AuditEntry, 0)
+	// walking backwards so the newest entries come first
+	for i := len(m.audit) - 1; i >= 0; i-- {
+		if m.audit[i].OrgID == orgID {
+			out = append(out, m.audit[i])
+		}
+	}
+
+	start, end := pageBounds(len(out), limit, offset)
+	return out[start:end], len(out), nil
+}
+
+func (m *inMemoryStore) Quota(orgID string) (*Quota, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.quota(orgID)
+}
+
+func (m *inMemoryStore) quota(orgID string) (*Quota, error) {
+	q := Quota{OrgID: orgID, Limits: m.defaultQuota}
+
+	if o, ok := m.quotaOverrides[orgID]; ok {
+		if o.Registrations != nil {
+			q.Limits.Registrations = *o.Registrations
+		}
+		if o.AllowlistBlocks != nil {
+			q.Limits.AllowlistBlocks = *o.AllowlistBlocks
+		}
+	}
+
+	for i := range m.db {
+		if m.db[i].OrgID == orgID && m.db[i].DeletedAt == nil {
+			q.Registrations++
+		}
+	}
+	for i := range m.allowedAddresses {
+		if m.allowedAddresses[i].OrgID == orgID && !m.allowedAddresses[i].Expired() {
+			q.AllowlistBlocks++
+		}
+	}
+
+	return &q, nil
+}
+
+func (m *inMemoryStore) SetQuotaOverride(o *QuotaOverride) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.quotaOverrides == nil {
+		m.quotaOverrides = make(map[string]QuotaOverride)
+	}
+	m.quotaOverrides[o.OrgID] = *o
+	return nil
+}
+
+func (m *inMemoryStore) DeleteQuotaOverride(orgID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.quotaOverrides, orgID)
+	return nil
+}
+
+// checks the org has room for one more of the resource
+func (m *inMemoryStore) checkQuota(orgID, resource string) error {
+	q, err := m.quota(orgID)
+	if err != nil {
+		return err
+	}
+
+	limit, usage := q.Limits.Registrations, q.Registrations
+	if resource == QuotaAllowlistBlocks {
+		limit, usage = q.Limits.AllowlistBlocks, q.AllowlistBlocks
+	}
+
+	if limit > 0 && usage >= limit {
+		return ErrQuotaExceeded{Resource: resource, Limit: limit}
+	}
+	return nil
+}
+
+func (m *inMemoryStore) RevokeToken(r *TokenRevocation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	revoked, _ := m.tokenRevoked(r.OrgID, r.JTI)
+	if revoked {
+		return nil
+	}
+
+	r.RevokedAt = time.Now()
+	m.revocations = append(m.revocations, *r)
+	return nil
+}
+
+func (m *inMemoryStore) TokenRevoked(orgID, jti string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.tokenRevoked(orgID, jti)
+}
+
+func (m *inMemoryStore) tokenRevoked(orgID, jti string) (bool, error) {
+	for i := range m.revocations {
+		if m.revocations[i].OrgID == orgID && m.revocations[i].JTI == jti {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *inMemoryStore) PurgeExpiredRevocations() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	kept := make([]TokenRevocation, 0, len(m.revocations))
+	for i := range m.revocations {
+		if m.revocations[i].ExpiresAt.After(now) {
+			kept = append(kept, m.revocations[i])
+		}
+	}
+
+	purged := len(m.revocations) - len(kept)
+	m.revocations = kept
+	return purged, nil
+}
@@ -1,14 +1,17 @@
 package store
 
 import (
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 )
 
 type InMemoryStoreTestSuite struct {
 	suite.Suite
-	store RegistrationStore
+	store Store
 }
 
 func (suite *InMemoryStoreTestSuite) SetupSuite() {}
@@ -58,12 +61,109 @@ func (suite *InMemoryStoreTestSuite) TestAll() {
 	_, err = suite.store.Create(&Registration{OrgID: "2345", UID: "2345", DisplayName: "two"})
 	suite.Nil(err)
 
-	_, count, err := suite.store.All("1234", 0, 0)
+	_, count, err := suite.store.All("1234", 0, 0, nil)
 	suite.Nil(err)
 
 	suite.Equal(count, 1)
 }
 
+func (suite *InMemoryStoreTestSuite) TestAllExtraFilter() {
+	_, err := suite.store.Create(&Registration{OrgID: "1234", UID: "1234", DisplayName: "one", Extra: map[string]interface{}{"version": "6.13"}})
+	suite.Nil(err)
+	_, err = suite.store.Create(&Registration{OrgID: "1234", UID: "2345", DisplayName: "two", Extra: map[string]interface{}{"version": "6.12"}})
+	suite.Nil(err)
+
+	regs, count, err := suite.store.All("1234", 10, 0, &RegistrationFilter{Extra: map[string]string{"version": "6.13"}})
+	suite.Nil(err)
+	suite.Equal(1, count)
+	suite.Equal("1234", regs[0].UID)
+}
+
+func (suite *InMemoryStoreTestSuite) TestAllWithPagination() {
+	for i := 0; i < 10; i++ {
+		s := strconv.Itoa(i)
+		_, err := suite.store.Create(&Registration{OrgID: "a", UID: s, DisplayName: s})
+		suite.Nil(err)
+	}
+
+	regs, count, err := suite.store.All("a", 5, 0, nil)
+	suite.Nil(err)
+	suite.Equal(10, count)
+	suite.Equal(5, len(regs))
+
+	regs, count, err = suite.store.All("a", 5, 10, nil)
+	suite.Nil(err)
+	suite.Equal(10, count)
+	suite.Equal(0, len(regs))
+
+	regs, count, err = suite.store.All("a", -5, -5, nil)
+	suite.Nil(err)
+	suite.Equal(10, count)
+	suite.Equal(0, len(regs))
+}
+
+func (suite *InMemoryStoreTestSuite) TestAllSearchAndSort() {
+	for _, name := range []string{"rdu-sat-2", "bos-sat-1", "rdu-sat-1"} {
+		_, err := suite.store.Create(&Registration{OrgID: "a", UID: name, DisplayName: name, Username: "foobar"})
+		suite.Nil(err)
+	}
+	_, err := suite.store.Create(&Registration{OrgID: "a", UID: "other", DisplayName: "RDU-sat-3", Username: "barfoo"})
+	suite.Nil(err)
+
+	regs, count, err := suite.store.All("a", 10, 0, &RegistrationFilter{
+		DisplayName: "rdu",
+		Username:    "foobar",
+		SortBy:      "display_name",
+		SortOrder:   "asc",
+	})
+	suite.Nil(err)
+	suite.Equal(2, count)
+	suite.Equal("rdu-sat-1", regs[0].DisplayName)
+	suite.Equal("rdu-sat-2", regs[1].DisplayName)
+
+	_, count, err = suite.store.All("a", 10, 0, &RegistrationFilter{CreatedAfter: time.Now().Add(time.Hour)})
+	suite.Nil(err)
+	suite.Equal(0, count)
+
+	_, count, err = suite.store.All("a", 10, 0, &RegistrationFilter{CreatedBefore: time.Now().Add(time.Hour)})
+	suite.Nil(err)
+	suite.Equal(4, count)
+}
+
+func (suite *InMemoryStoreTestSuite) TestUpdate() {
+	_, err := suite.store.Create(&Registration{OrgID: "1234", UID: "1234", DisplayName: "one"})
+	suite.Nil(err)
+
+	name := "renamed"
+	err = suite.store.Update(
+		&Registration{OrgID: "1234", UID: "1234"},
+		&RegistrationUpdate{DisplayName: &name, Extra: &map[string]interface{}{"thing": true}},
+	)
+	suite.Nil(err)
+
+	found, err := suite.store.Find("1234", "1234")
+	suite.Nil(err)
+	suite.Equal("renamed", found.DisplayName)
+	suite.Equal(true, found.Extra["thing"])
+}
+
+func (suite *InMemoryStoreTestSuite) TestUpdateDuplicateDisplayName() {
+	_, err := suite.store.Create(&Registration{OrgID: "1234", UID: "1234", DisplayName: "one"})
+	suite.Nil(err)
+	_, err = suite.store.Create(&Registration{OrgID: "1234", UID: "2345", DisplayName: "two"})
+	suite.Nil(err)
+
+	name := "two"
+	err = suite.store.Update(&Registration{OrgID: "1234", UID: "1234"}, &RegistrationUpdate{DisplayName: &name})
+	suite.ErrorIs(err, ErrRegistrationAlreadyExists{})
+}
+
+func (suite *InMemoryStoreTestSuite) TestUpdateNotThere() {
+	name := "two"
+	err := suite.store.Update(&Registration{OrgID: "1234", UID: "1234"}, &RegistrationUpdate{DisplayName: &name})
+	suite.ErrorIs(err, ErrRegistrationNotFound)
+}
+
 func (suite *InMemoryStoreTestSuite) TestDelete() {
 	_, err := suite.store.Create(&Registration{OrgID: "1234"})
 	suite.Nil(err)
@@ -76,3 +176,417 @@ func (suite *InMemoryStoreTestSuite) TestDeleteNotExisting() {
 	err := suite.store.Delete("1234", "")
 	suite.Error(err)
 }
+
+func (suite *InMemoryStoreTestSuite) TestDeleteIsSoft() {
+	_, err := suite.store.Create(&Registration{OrgID: "1234", UID: "1234", DisplayName: "one"})
+	suite.Nil(err)
+	suite.Nil(suite.store.Delete("1234", "1234"))
+
+	_, err = suite.store.Find("1234", "1234")
+	suite.ErrorIs(err, ErrRegistrationNotFound)
+	_, err = suite.store.FindByUID("1234")
+	suite.ErrorIs(err, ErrRegistrationNotFound)
+	_, count, err := suite.store.All("1234", 10, 0, nil)
+	suite.Nil(err)
+	suite.Equal(0, count)
+
+	// the uid + display name are free to be used again
+	_, err = suite.store.Create(&Registration{OrgID: "1234", UID: "1234", DisplayName: "one"})
+	suite.Nil(err)
+}
+
+func (suite *InMemoryStoreTestSuite) TestRestore() {
+	_, err := suite.store.Create(&Registration{OrgID: "1234", UID: "1234", DisplayName: "one"})
+	suite.Nil(err)
+	suite.Nil(suite.store.Delete("1234", "1234"))
+
+	suite.Nil(suite.store.Restore("1234", "1234"))
+	_, err = suite.store.Find("1234", "1234")
+	suite.Nil(err)
+
+	suite.ErrorIs(suite.store.Restore("1234", "1234"), ErrRegistrationNotFound)
+}
+
+func (suite *InMemoryStoreTestSuite) TestRestoreConflict() {
+	_, err := suite.store.Create(&Registration{OrgID: "1234", UID: "1234", DisplayName: "one"})
+	suite.Nil(err)
+	suite.Nil(suite.store.Delete("1234", "1234"))
+	_, err = suite.store.Create(&Registration{OrgID: "1234", UID: "1234", DisplayName: "two"})
+	suite.Nil(err)
+
+	suite.ErrorIs(suite.store.Restore("1234", "1234"), ErrRegistrationAlreadyExists{})
+}
+
+func (suite *InMemoryStoreTestSuite) TestPurgeDeleted() {
+	_, err := suite.store.Create(&Registration{OrgID: "1234", UID: "1234", DisplayName: "one"})
+	suite.Nil(err)
+	_, err = suite.store.Create(&Registration{OrgID: "1234", UID: "2345", DisplayName: "two"})
+	suite.Nil(err)
+	suite.Nil(suite.store.Delete("1234", "1234"))
+
+	count, err := suite.store.PurgeDeleted(time.Hour)
+	suite.Nil(err)
+	suite.Equal(0, count)
+
+	count, err = suite.store.PurgeDeleted(0)
+	suite.Nil(err)
+	suite.Equal(1, count)
+
+	suite.ErrorIs(suite.store.Restore("1234", "1234"), ErrRegistrationNotFound)
+	_, err = suite.store.Find("1234", "2345")
+	suite.Nil(err)
+}
+
+func (suite *InMemoryStoreTestSuite) TestRotateUID() {
+	_, err := suite.store.Create(&Registration{OrgID: "1234", UID: "1234", DisplayName: "one"})
+	suite.Nil(err)
+
+	suite.Nil(suite.store.RotateUID("1234", "1234", "2345"))
+	_, err = suite.store.Find("1234", "1234")
+	suite.ErrorIs(err, ErrRegistrationNotFound)
+	r, err := suite.store.Find("1234", "2345")
+	suite.Nil(err)
+	suite.Equal("one", r.DisplayName)
+}
+
+func (suite *InMemoryStoreTestSuite) TestRotateUIDConflict() {
+	_, err := suite.store.Create(&Registration{OrgID: "1234", UID: "1234", DisplayName: "one"})
+	suite.Nil(err)
+	_, err = suite.store.Create(&Registration{OrgID: "1234", UID: "2345", DisplayName: "two"})
+	suite.Nil(err)
+
+	suite.ErrorIs(suite.store.RotateUID("1234", "1234", "2345"), ErrRegistrationAlreadyExists{})
+}
+
+func (suite *InMemoryStoreTestSuite) TestRotateUIDNotThere() {
+	suite.ErrorIs(suite.store.RotateUID("1234", "1234", "2345"), ErrRegistrationNotFound)
+}
+
+func (suite *InMemoryStoreTestSuite) TestExpiresAt() {
+	expiresAt := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+	_, err := suite.store.Create(&Registration{OrgID: "1234", UID: "1234", DisplayName: "one", ExpiresAt: &expiresAt})
+	suite.Nil(err)
+
+	r, err := suite.store.Find("1234", "1234")
+	suite.Nil(err)
+	suite.NotNil(r.ExpiresAt)
+	suite.True(r.Expired())
+}
+
+func (suite *InMemoryStoreTestSuite) TestImport() {
+	_, err := suite.store.Create(&Registration{OrgID: "1234", UID: "2345", DisplayName: "existing"})
+	suite.Nil(err)
+
+	results, err := suite.store.Import([]Registration{
+		{OrgID: "1234", UID: "1234", DisplayName: "one"},
+		{OrgID: "1234", UID: "2345", DisplayName: "two"},
+		{OrgID: "1234", UID: "3456", DisplayName: "three"},
+		{OrgID: "1234", UID: "3456", DisplayName: "four"},
+	})
+	suite.Nil(err)
+	suite.Len(results, 4)
+	suite.Equal(ImportCreated, results[0].Status)
+	suite.Equal(ImportConflict, results[1].Status)
+	suite.Equal(ImportCreated, results[2].Status)
+	suite.Equal(ImportConflict, results[3].Status)
+
+	_, count, err := suite.store.All("1234", 10, 0, nil)
+	suite.Nil(err)
+	suite.Equal(3, count)
+}
+
+func (suite *InMemoryStoreTestSuite) TestQuotaOverride() {
+	registrations, blocks := 1, 1
+	suite.Nil(suite.store.SetQuotaOverride(&QuotaOverride{OrgID: "1234", Registrations: &registrations, AllowlistBlocks: &blocks}))
+
+	_, err := suite.store.Create(&Registration{OrgID: "1234", UID: "1234", DisplayName: "one"})
+	suite.Nil(err)
+	_, err = suite.store.Create(&Registration{OrgID: "1234", UID: "2345", DisplayName: "two"})
+	suite.ErrorIs(err, ErrQuotaExceeded{})
+	// other orgs aren't affected
+	_, err = suite.store.Create(&Registration{OrgID: "4321", UID: "3456", DisplayName: "three"})
+	suite.Nil(err)
+
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.0.0/24"}))
+	suite.ErrorIs(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.1.0/24"}), ErrQuotaExceeded{})
+
+	q, err := suite.store.Quota("1234")
+	suite.Nil(err)
+	suite.Equal(QuotaLimits{Registrations: 1, AllowlistBlocks: 1}, q.Limits)
+	suite.Equal(1, q.Registrations)
+	suite.Equal(1, q.AllowlistBlocks)
+
+	suite.Nil(suite.store.DeleteQuotaOverride("1234"))
+	_, err = suite.store.Create(&Registration{OrgID: "1234", UID: "2345", DisplayName: "two"})
+	suite.Nil(err)
+}
+
+func (suite *InMemoryStoreTestSuite) TestQuotaRestore() {
+	registrations := 1
+	suite.Nil(suite.store.SetQuotaOverride(&QuotaOverride{OrgID: "1234", Registrations: &registrations}))
+
+	_, err := suite.store.Create(&Registration{OrgID: "1234", UID: "1234", DisplayName: "one"})
+	suite.Nil(err)
+	suite.Nil(suite.store.Delete("1234", "1234"))
+	_, err = suite.store.Create(&Registration{OrgID: "1234", UID: "2345", DisplayName: "two"})
+	suite.Nil(err)
+
+	suite.ErrorIs(suite.store.Restore("1234", "1234"), ErrQuotaExceeded{})
+}
+
+func (suite *InMemoryStoreTestSuite) TestQuotaImport() {
+	registrations := 2
+	suite.Nil(suite.store.SetQuotaOverride(&QuotaOverride{OrgID: "1234", Registrations: &registrations}))
+
+	results, err := suite.store.Import([]Registration{
+		{OrgID: "1234", UID: "1234", DisplayName: "one"},
+		{OrgID: "1234", UID: "2345", DisplayName: "two"},
+		{OrgID: "1234", UID: "3456", DisplayName: "three"},
+	})
+	suite.Nil(err)
+	suite.Equal(ImportCreated, results[1].Status)
+	suite.Equal(ImportConflict, results[2].Status)
+	suite.Equal(ErrQuotaExceeded{Resource: QuotaRegistrations, Limit: 2}.Error(), results[2].Detail)
+}
+
+func (suite *InMemoryStoreTestSuite) TestAllowAddressConflicts() {
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.0.0/24"}))
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.1.0/24"}))
+	// other orgs don't conflict
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "4321", IPBlock: "10.0.0.0/8"}))
+
+	err := suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.0.0/16"})
+	suite.ErrorIs(err, ErrAllowlistConflict{})
+	suite.Equal(ErrAllowlistConflict{Conflicts: []AllowlistConflict{
+		{IPBlock: "10.0.0.0/24", Relation: AllowlistSubset, Candidate: "10.0.0.0/16"},
+		{IPBlock: "10.0.1.0/24", Relation: AllowlistSubset, Candidate: "10.0.0.0/16"},
+	}}, err)
+
+	err = suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.0.5/32"})
+	suite.Equal(ErrAllowlistConflict{Conflicts: []AllowlistConflict{{IPBlock: "10.0.0.0/24", Relation: AllowlistSuperset, Candidate: "10.0.0.5/32"}}}, err)
+
+	err = suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.1.0/24"})
+	suite.Equal(ErrAllowlistConflict{Conflicts: []AllowlistConflict{{IPBlock: "10.0.1.0/24", Relation: AllowlistDuplicate, Candidate: "10.0.1.0/24"}}}, err)
+}
+
+func (suite *InMemoryStoreTestSuite) TestAllowedIPv6() {
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "2001:db8::/32"}))
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "2001:db9::1/128"}))
+	// same leading bits as the v6 block, but a different family
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "32.1.0.0/16"}))
+
+	for ip, expected := range map[string]bool{
+		"2001:db8::1":     true,
+		"2001:db8:ffff::": true,
+		"2001:db9::1":     true,
+		"2001:db9::2":     false,
+		"2001:db7::1":     false,
+		"32.1.13.184":     true,
+		"::ffff:8.8.8.8":  false,
+		"not an ip":       false,
+	} {
+		allowed, err := suite.store.AllowedIP(ip, "1234")
+		suite.Nil(err)
+		suite.Equal(expected, allowed, ip)
+	}
+}
+
+func (suite *InMemoryStoreTestSuite) TestAllowedIPOtherOrg() {
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.0.0/24"}))
+
+	allowed, err := suite.store.AllowedIP("10.0.0.1", "4321")
+	suite.Nil(err)
+	suite.False(allowed)
+
+	addrs, err := suite.store.AllowedAddresses("4321")
+	suite.Nil(err)
+	suite.Len(addrs, 0)
+}
+
+func (suite *InMemoryStoreTestSuite) TestAllowedSingleIP() {
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.0.1/32"}))
+
+	allowed, err := suite.store.AllowedIP("10.0.0.1", "1234")
+	suite.Nil(err)
+	suite.True(allowed)
+}
+
+func (suite *InMemoryStoreTestSuite) TestAllowedIPExpired() {
+	expired := time.Now().Add(-time.Minute)
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.0.0/24", ExpiresAt: &expired}))
+	future := time.Now().Add(time.Hour)
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.1.0/24", ExpiresAt: &future}))
+
+	allowed, err := suite.store.AllowedIP("10.0.0.1", "1234")
+	suite.Nil(err)
+	suite.False(allowed)
+
+	allowed, err = suite.store.AllowedIP("10.0.1.1", "1234")
+	suite.Nil(err)
+	suite.True(allowed)
+}
+
+func (suite *InMemoryStoreTestSuite) TestAllowAddressExpired() {
+	blocks := 1
+	suite.Nil(suite.store.SetQuotaOverride(&QuotaOverride{OrgID: "1234", AllowlistBlocks: &blocks}))
+
+	expired := time.Now().Add(-time.Minute)
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.0.0/24", ExpiresAt: &expired}))
+
+	q, err := suite.store.Quota("1234")
+	suite.Nil(err)
+	suite.Equal(0, q.AllowlistBlocks)
+
+	// neither a conflict nor over quota, and it replaces the expired one
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.0.0/24", Description: "again"}))
+
+	addrs, err := suite.store.AllowedAddresses("1234")
+	suite.Nil(err)
+	suite.Len(addrs, 1)
+	suite.Equal("again", addrs[0].Description)
+	suite.Nil(addrs[0].ExpiresAt)
+
+	allowed, err := suite.store.AllowedIP("10.0.0.1", "1234")
+	suite.Nil(err)
+	suite.True(allowed)
+}
+
+func (suite *InMemoryStoreTestSuite) TestReplaceAllowlist() {
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.0.0/24", CreatedBy: "first", Description: "old"}))
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.1.0/24", CreatedBy: "first"}))
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "4321", IPBlock: "10.0.1.0/24", CreatedBy: "first"}))
+
+	suite.Nil(suite.store.ReplaceAllowlist("1234", []AllowlistBlock{
+		{IPBlock: "10.0.0.0/24", CreatedBy: "second", Description: "new"},
+		{IPBlock: "2001:db8::/32", CreatedBy: "second"},
+	}))
+
+	addrs, err := suite.store.AllowedAddresses("1234")
+	suite.Nil(err)
+	suite.Len(addrs, 2)
+	for _, addr := range addrs {
+		switch addr.IPBlock {
+		case "10.0.0.0/24":
+			suite.Equal("first", addr.CreatedBy)
+			suite.Equal("new", addr.Description)
+		case "2001:db8::/32":
+			suite.Equal("second", addr.CreatedBy)
+		default:
+			suite.Fail("unexpected block", addr.IPBlock)
+		}
+	}
+
+	// other orgs are left alone
+	addrs, err = suite.store.AllowedAddresses("4321")
+	suite.Nil(err)
+	suite.Len(addrs, 1)
+}
+
+func (suite *InMemoryStoreTestSuite) TestReplaceAllowlistOverlapping() {
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "192.168.0.0/24"}))
+
+	err := suite.store.ReplaceAllowlist("1234", []AllowlistBlock{
+		{IPBlock: "10.0.0.0/16"},
+		{IPBlock: "10.0.1.0/24"},
+	})
+	suite.Equal(ErrAllowlistConflict{Conflicts: []AllowlistConflict{
+		{IPBlock: "10.0.0.0/16", Relation: AllowlistSuperset, Candidate: "10.0.1.0/24"},
+	}}, err)
+
+	// nothing changed
+	addrs, err := suite.store.AllowedAddresses("1234")
+	suite.Nil(err)
+	suite.Len(addrs, 1)
+}
+
+func (suite *InMemoryStoreTestSuite) TestReplaceAllowlistQuota() {
+	blocks := 1
+	suite.Nil(suite.store.SetQuotaOverride(&QuotaOverride{OrgID: "1234", AllowlistBlocks: &blocks}))
+
+	err := suite.store.ReplaceAllowlist("1234", []AllowlistBlock{
+		{IPBlock: "10.0.0.0/24"},
+		{IPBlock: "10.0.1.0/24"},
+	})
+	suite.ErrorIs(err, ErrQuotaExceeded{})
+
+	suite.Nil(suite.store.ReplaceAllowlist("1234", nil))
+}
+
+func (suite *InMemoryStoreTestSuite) TestRevokeToken() {
+	rev := TokenRevocation{JTI: "abc", OrgID: "1234", RevokedBy: "foobar", ExpiresAt: time.Now().Add(time.Hour)}
+	suite.Nil(suite.store.RevokeToken(&rev))
+	// revoking twice is fine
+	suite.Nil(suite.store.RevokeToken(&rev))
+
+	revoked, err := suite.store.TokenRevoked("1234", "abc")
+	suite.Nil(err)
+	suite.True(revoked)
+
+	// revocations are per org
+	revoked, err = suite.store.TokenRevoked("4321", "abc")
+	suite.Nil(err)
+	suite.False(revoked)
+
+	revoked, err = suite.store.TokenRevoked("1234", "def")
+	suite.Nil(err)
+	suite.False(revoked)
+}
+
+func (suite *InMemoryStoreTestSuite) TestPurgeExpiredRevocations() {
+	suite.Nil(suite.store.RevokeToken(&TokenRevocation{JTI: "expired", OrgID: "1234", ExpiresAt: time.Now().Add(-time.Minute)}))
+	suite.Nil(suite.store.RevokeToken(&TokenRevocation{JTI: "current", OrgID: "1234", ExpiresAt: time.Now().Add(time.Hour)}))
+
+	count, err := suite.store.PurgeExpiredRevocations()
+	suite.Nil(err)
+	suite.Equal(1, count)
+
+	revoked, err := suite.store.TokenRevoked("1234", "expired")
+	suite.Nil(err)
+	suite.False(revoked)
+
+	revoked, err = suite.store.TokenRevoked("1234", "current")
+	suite.Nil(err)
+	suite.True(revoked)
+}
+
+// the purgers run in their own goroutines alongside the requests, caught by
+// running with -race
+func (suite *InMemoryStoreTestSuite) TestConcurrentPurge() {
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		s := strconv.Itoa(i)
+
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			_, err := suite.store.Create(&Registration{OrgID: "a", UID: s, DisplayName: s})
+			suite.Nil(err)
+			suite.Nil(suite.store.Delete("a", s))
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := suite.store.PurgeDeleted(0)
+			suite.Nil(err)
+			_, _, err = suite.store.All("a", 10, 0, nil)
+			suite.Nil(err)
+		}()
+		go func() {
+			defer wg.Done()
+			suite.Nil(suite.store.RevokeToken(&TokenRevocation{JTI: s, OrgID: "a", ExpiresAt: time.Now()}))
+			_, err := suite.store.TokenRevoked("a", s)
+			suite.Nil(err)
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := suite.store.PurgeExpiredRevocations()
+			suite.Nil(err)
+		}()
+	}
+	wg.Wait()
+
+	_, err := suite.store.PurgeDeleted(0)
+	suite.Nil(err)
+	_, count, err := suite.store.All("a", 10, 0, nil)
+	suite.Nil(err)
+	suite.Equal(0, count)
+}
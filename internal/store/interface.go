@@ -1,24 +1,81 @@
 package store
 
+import "time"
+
 type Store interface {
 	RegistrationStore
 	AllowlistStore
+	AuditStore
+	QuotaStore
+	RevocationStore
 }
 
 type RegistrationStore interface {
-	All(orgID string, limit, offset int) ([]Registration, int, error)
+	All(orgID string, limit, offset int, filter *RegistrationFilter) ([]Registration, int, error)
 	// Find a registration that both the org ID + UID match
 	Find(orgID, uid string) (*Registration, error)
 	// lookup a registration by uid only
 	FindByUID(uid string) (*Registration, error)
+	// fails with ErrQuotaExceeded if the org is at its registration limit
 	Create(r *Registration) (string, error)
 	Update(r *Registration, update *RegistrationUpdate) error
+	// creates all of the registrations in one go, existing registrations are
+	// skipped and reported as conflicts rather than failing the whole import,
+	// as are any that would take the org over its registration limit
+	Import(regs []Registration) ([]ImportResult, error)
+	// moves a registration over to a new uid, e.g. when the satellite's cert
+	// is rotated
+	RotateUID(orgID, oldUID, newUID string) error
+	// soft-deletes a registration, it's excluded from everything else until
+	// it's either restored or purged
 	Delete(orgID, uid string) error
+	// restores the most recently soft-deleted registration matching org ID + UID,
+	// failing with ErrQuotaExceeded if the org is at its registration limit
+	Restore(orgID, uid string) error
+	// permanently removes registrations soft-deleted longer ago than the
+	// retention period, returning how many were removed
+	PurgeDeleted(retention time.Duration) (int, error)
 }
 
 type AllowlistStore interface {
 	AllowedAddresses(orgID string) ([]AllowlistBlock, error)
+	// whether the address (or block) falls within any of the org's unexpired
+	// blocks
 	AllowedIP(ip, orgID string) (bool, error)
+	// fails with ErrAllowlistConflict if the block overlaps any of the org's
+	// existing blocks, or ErrQuotaExceeded if the org is at its allowlist limit
 	AllowAddress(ip *AllowlistBlock) error
 	DenyAddress(ip *AllowlistBlock) error
+	// atomically swaps the org's allowlist for the given blocks. Blocks that
+	// are already present keep their created_at/created_by. Fails with
+	// ErrAllowlistConflict if any of the new blocks overlap each other, or
+	// ErrQuotaExceeded if there are more than the org's limit.
+	ReplaceAllowlist(orgID string, blocks []AllowlistBlock) error
+}
+
+type AuditStore interface {
+	// record a single mutation, CreatedAt is set by the store
+	RecordAudit(e *AuditEntry) error
+	// list the audit entries for an org, newest first
+	AuditEntries(orgID string, limit, offset int) ([]AuditEntry, int, error)
+}
+
+type QuotaStore interface {
+	// the limits in effect for an org (any override merged over the global
+	// default) along with its current usage
+	Quota(orgID string) (*Quota, error)
+	// sets (or replaces) the per-org override
+	SetQuotaOverride(o *QuotaOverride) error
+	// removes the per-org override, going back to the global default
+	DeleteQuotaOverride(orgID string) error
+}
+
+type RevocationStore interface {
+	// revokes a token, revoking one that's already revoked isn't an error
+	RevokeToken(r *TokenRevocation) error
+	// whether the org's token with the jti has been revoked
+	TokenRevoked(orgID, jti string) (bool, error)
+	// removes the revocations for tokens that have since expired, returning
+	// how many were removed
+	PurgeExpiredRevocations() (int, error)
 }
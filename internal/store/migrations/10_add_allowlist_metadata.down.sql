@@ -0,0 +1,4 @@
+alter table if exists public.allowlist
+    drop column if exists description,
+    drop column if exists created_by,
+    drop column if exists expires_at;
@@ -0,0 +1,4 @@
+alter table if exists public.allowlist
+    add column if not exists description varchar default '' not null,
+    add column if not exists created_by varchar default '' not null,
+    add column if not exists expires_at timestamp default null;
@@ -0,0 +1,12 @@
+create table if not exists public.token_revocations(
+    jti varchar not null,
+    org_id varchar not null,
+    revoked_by varchar default '' not null,
+    revoked_at timestamp default now() not null,
+    expires_at timestamp not null,
+    constraint token_revocations_pk
+        primary key (jti, org_id)
+);
+
+create index if not exists token_revocations_expires_at_index
+    on public.token_revocations (expires_at);
@@ -0,0 +1,14 @@
+create table if not exists public.audit_log(
+    id uuid default uuid_generate_v4() not null
+        constraint audit_log_pk
+            primary key,
+    actor varchar not null,
+    org_id varchar not null,
+    action varchar not null,
+    target varchar not null,
+    source_ip varchar,
+    created_at timestamp default now() not null
+);
+
+create index if not exists audit_log_org_id_created_at_index
+    on public.audit_log (org_id, created_at);
@@ -0,0 +1,21 @@
+alter table registrations
+    add column if not exists deleted_at timestamp default null;
+
+-- uniqueness only applies to live (not soft-deleted) registrations, so swap the
+-- constraints out for partial unique indexes
+alter table registrations
+    drop constraint if exists uid_unique;
+alter table registrations
+    drop constraint if exists display_name_unique;
+drop index if exists registrations_org_id_uid_uindex;
+
+create unique index if not exists registrations_uid_live_uindex
+    on registrations (uid) where deleted_at is null;
+create unique index if not exists registrations_org_id_uid_live_uindex
+    on registrations (org_id, uid) where deleted_at is null;
+create unique index if not exists registrations_display_name_org_id_live_uindex
+    on registrations (display_name, org_id) where deleted_at is null;
+
+-- for the purge job
+create index if not exists registrations_deleted_at_index
+    on registrations (deleted_at) where deleted_at is not null;
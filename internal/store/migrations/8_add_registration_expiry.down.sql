@@ -0,0 +1,2 @@
+alter table registrations
+    drop column if exists expires_at;
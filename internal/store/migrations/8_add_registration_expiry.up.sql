@@ -0,0 +1,2 @@
+alter table registrations
+    add column if not exists expires_at timestamp default null;
@@ -0,0 +1,9 @@
+create table if not exists public.org_quotas(
+    org_id varchar not null
+        constraint org_quotas_pk
+            primary key,
+    max_registrations integer,
+    max_allowlist_blocks integer,
+    created_at timestamp default now() not null,
+    updated_at timestamp default now() not null
+);
@@ -3,6 +3,8 @@ package store
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
 	// the pgx driver for the database
@@ -13,20 +15,34 @@ import (
 )
 
 type postgresStore struct {
-	db *sql.DB
+	db           *sql.DB
+	defaultQuota QuotaLimits
 }
 
-func (p *postgresStore) All(orgID string, limit, offset int) ([]Registration, int, error) {
-	rows, err := p.db.Query(`select
-	id, org_id, username, uid, display_name, extra, created_at
+func (p *postgresStore) All(orgID string, limit, offset int, filter *RegistrationFilter) ([]Registration, int, error) {
+	where, args := registrationWhereClause(orgID, filter)
+
+	// only ever interpolating whitelisted values into the order by clause
+	sortBy, sortOrder := "created_at", "desc"
+	if filter != nil && filter.SortBy != "" {
+		for _, f := range RegistrationSortFields {
+			if f == filter.SortBy {
+				sortBy = f
+			}
+		}
+	}
+	if filter != nil && filter.SortOrder == "asc" {
+		sortOrder = "asc"
+	}
+
+	rows, err := p.db.Query(fmt.Sprintf(`select
+	id, org_id, username, uid, display_name, extra, created_at, updated_at, expires_at
 	from registrations
-	where org_id = $1
-	order by created_at desc
-	limit $2
-	offset $3`,
-		orgID,
-		limit,
-		offset)
+	where %s
+	order by %s %s
+	limit $%d
+	offset $%d`, where, sortBy, sortOrder, len(args)+1, len(args)+2),
+		append(args, limit, offset)...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -42,7 +58,7 @@ func (p *postgresStore) All(orgID string, limit, offset int) ([]Registration, in
 	}
 
 	var count int
-	row := p.db.QueryRow(`select count(id) from registrations where org_id = $1`, orgID)
+	row := p.db.QueryRow(`select count(id) from registrations where `+where, args...)
 	if err := row.Scan(&count); err != nil {
 		return nil, 0, err
 	}
@@ -50,9 +66,47 @@ func (p *postgresStore) All(orgID string, limit, offset int) ([]Registration, in
 	return out, count, nil
 }
 
+// escapes the wildcard characters for a `like` query so user input is matched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// builds the where clause (and matching args) used for both listing and
+// counting registrations
+func registrationWhereClause(orgID string, filter *RegistrationFilter) (string, []any) {
+	clauses := []string{"org_id = $1", "deleted_at is null"}
+	args := []any{orgID}
+
+	if filter == nil {
+		return strings.Join(clauses, " and "), args
+	}
+
+	if filter.DisplayName != "" {
+		args = append(args, "%"+likeEscaper.Replace(filter.DisplayName)+"%")
+		clauses = append(clauses, fmt.Sprintf("display_name ilike $%d", len(args)))
+	}
+	if filter.Username != "" {
+		args = append(args, filter.Username)
+		clauses = append(clauses, fmt.Sprintf("username = $%d", len(args)))
+	}
+	if !filter.CreatedAfter.IsZero() {
+		args = append(args, filter.CreatedAfter)
+		clauses = append(clauses, fmt.Sprintf("created_at > $%d", len(args)))
+	}
+	if !filter.CreatedBefore.IsZero() {
+		args = append(args, filter.CreatedBefore)
+		clauses = append(clauses, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	for k, v := range filter.Extra {
+		args = append(args, k, v)
+		clauses = append(clauses, fmt.Sprintf("extra ->> $%d = $%d", len(args)-1, len(args)))
+	}
+
+	return strings.Join(clauses, " and "), args
+}
+
 func (p *postgresStore) Find(orgID, uid string) (*Registration, error) {
 	rows := p.db.QueryRow(
-		`select id, org_id, username, uid, display_name, extra, created_at from registrations where org_id = $1 and uid = $2 limit 1`,
+		`select id, org_id, username, uid, display_name, extra, created_at, updated_at, expires_at from registrations where org_id = $1 and uid = $2 and deleted_at is null limit 1`,
 		orgID,
 		uid,
 	)
@@ -60,56 +114,179 @@ func (p *postgresStore) Find(orgID, uid string) (*Registration, error) {
 }
 
 func (p *postgresStore) FindByUID(uid string) (*Registration, error) {
-	rows := p.db.QueryRow(`select id, org_id, username, uid, display_name, extra, created_at from registrations where uid = $1 limit 1`, uid)
+	rows := p.db.QueryRow(`select id, org_id, username, uid, display_name, extra, created_at, updated_at, expires_at from registrations where uid = $1 and deleted_at is null limit 1`, uid)
 	return scanRegistration(rows)
 }
 
 func (p *postgresStore) Create(r *Registration) (string, error) {
-	res := p.db.QueryRow(
+	tx, err := p.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	//nolint:errcheck
+	defer tx.Rollback()
+
+	res := tx.QueryRow(
 		`insert into registrations
-		(org_id, username, uid, display_name, extra)
-		values ($1, $2, $3, $4, $5)
+		(org_id, username, uid, display_name, extra, expires_at)
+		values ($1, $2, $3, $4, $5, $6)
 		returning id`,
 		r.OrgID,
 		r.Username,
 		r.UID,
 		r.DisplayName,
 		r.Extra,
+		nullTime(r.ExpiresAt),
 	)
 
 	var id string
-	err := res.Scan(&id)
+	err = res.Scan(&id)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		// constraint violation == 23505
-		if errors.As(err, &pgErr) {
-			if pgErr.Code == "23505" {
-				return "", ErrRegistrationAlreadyExists{Detail: pgErr.Detail}
-			}
-		} else {
-			return "", err
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return "", ErrRegistrationAlreadyExists{Detail: pgErr.Detail}
 		}
+		return "", err
+	}
+
+	if err := p.checkQuota(tx, r.OrgID, QuotaRegistrations); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
 	}
 
 	l.Log.Info("Created registration", "id", id, "org_id", r.OrgID, "username", r.Username, "uid", r.UID, "display_name", r.DisplayName)
 	return id, nil
 }
 
+// Import inserts every registration inside a single transaction. Each row gets
+// its own savepoint so a unique violation only skips that row, anything else
+// rolls back the whole import.
+func (p *postgresStore) Import(regs []Registration) ([]ImportResult, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	//nolint:errcheck
+	defer tx.Rollback()
+
+	results := make([]ImportResult, len(regs))
+	for i := range regs {
+		r := &regs[i]
+		results[i] = ImportResult{UID: r.UID, Status: ImportCreated}
+
+		if _, err := tx.Exec(`savepoint import_row`); err != nil {
+			return nil, err
+		}
+
+		_, err := tx.Exec(
+			`insert into registrations
+			(org_id, username, uid, display_name, extra, expires_at)
+			values ($1, $2, $3, $4, $5, $6)`,
+			r.OrgID,
+			r.Username,
+			r.UID,
+			r.DisplayName,
+			r.Extra,
+			nullTime(r.ExpiresAt),
+		)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+				return nil, err
+			}
+
+			if _, err := tx.Exec(`rollback to savepoint import_row`); err != nil {
+				return nil, err
+			}
+			results[i].Status = ImportConflict
+			results[i].Detail = ErrRegistrationAlreadyExists{Detail: pgErr.Detail}.Error()
+			continue
+		}
+
+		err = p.checkQuota(tx, r.OrgID, QuotaRegistrations)
+		if err != nil {
+			if !errors.Is(err, ErrQuotaExceeded{}) {
+				return nil, err
+			}
+
+			if _, err := tx.Exec(`rollback to savepoint import_row`); err != nil {
+				return nil, err
+			}
+			results[i].Status = ImportConflict
+			results[i].Detail = err.Error()
+			continue
+		}
+
+		if _, err := tx.Exec(`release savepoint import_row`); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	l.Log.Info("Imported registrations", "count", len(regs))
+	return results, nil
+}
+
 func (p *postgresStore) Update(r *Registration, update *RegistrationUpdate) error {
-	//TODO: maybe more fields someday, not sure.
-	_, err := p.db.Exec(
-		`update registrations set extra = $1 where org_id = $2 and uid = $3`,
-		update.Extra,
-		r.OrgID,
-		r.UID,
+	sets := make([]string, 0)
+	args := make([]any, 0)
+
+	if update.DisplayName != nil {
+		args = append(args, *update.DisplayName)
+		sets = append(sets, fmt.Sprintf("display_name = $%d", len(args)))
+	}
+	if update.Extra != nil {
+		args = append(args, *update.Extra)
+		sets = append(sets, fmt.Sprintf("extra = $%d", len(args)))
+	}
+	if update.ExpiresAt != nil {
+		args = append(args, nullTime(update.ExpiresAt))
+		sets = append(sets, fmt.Sprintf("expires_at = $%d", len(args)))
+	}
+
+	// nothing to change, just make sure it's there
+	if len(sets) == 0 {
+		_, err := p.Find(r.OrgID, r.UID)
+		return err
+	}
+
+	args = append(args, r.OrgID, r.UID)
+	res, err := p.db.Exec(
+		fmt.Sprintf(`update registrations set %s where org_id = $%d and uid = $%d and deleted_at is null`,
+			strings.Join(sets, ", "), len(args)-1, len(args)),
+		args...,
 	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrRegistrationAlreadyExists{Detail: pgErr.Detail}
+		}
+		return err
+	}
 
-	return err
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count != 1 {
+		return ErrRegistrationNotFound
+	}
+
+	l.Log.Info("Updated registration", "org_id", r.OrgID, "uid", r.UID)
+	return nil
 }
 
 func (p *postgresStore) Delete(orgID, uid string) error {
 	res, err := p.db.Exec(
-		`delete from registrations where org_id = $1 and uid = $2`,
+		`update registrations set deleted_at = (now() at time zone 'utc') where org_id = $1 and uid = $2 and deleted_at is null`,
 		orgID,
 		uid,
 	)
@@ -130,6 +307,197 @@ func (p *postgresStore) Delete(orgID, uid string) error {
 	return nil
 }
 
+func (p *postgresStore) RotateUID(orgID, oldUID, newUID string) error {
+	// a single update so the registration moves over atomically, the unique
+	// indexes make sure the new uid isn't already taken
+	res, err := p.db.Exec(
+		`update registrations set uid = $1 where org_id = $2 and uid = $3 and deleted_at is null`,
+		newUID,
+		orgID,
+		oldUID,
+	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrRegistrationAlreadyExists{Detail: pgErr.Detail}
+		}
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count != 1 {
+		return ErrRegistrationNotFound
+	}
+
+	l.Log.Info("Rotated registration uid", "orgID", orgID, "old_uid", oldUID, "new_uid", newUID)
+	return nil
+}
+
+func (p *postgresStore) Restore(orgID, uid string) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	//nolint:errcheck
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
+		`update registrations set deleted_at = null
+		where id = (
+			select id from registrations
+			where org_id = $1 and uid = $2 and deleted_at is not null
+			order by deleted_at desc
+			limit 1
+		)`,
+		orgID,
+		uid,
+	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrRegistrationAlreadyExists{Detail: pgErr.Detail}
+		}
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count != 1 {
+		return ErrRegistrationNotFound
+	}
+
+	if err := p.checkQuota(tx, orgID, QuotaRegistrations); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	l.Log.Info("Restored registration", "orgID", orgID, "uid", uid)
+	return nil
+}
+
+func (p *postgresStore) PurgeDeleted(retention time.Duration) (int, error) {
+	// doing the math on the db side so we're comparing against its own clock
+	res, err := p.db.Exec(
+		`delete from registrations where deleted_at < (now() at time zone 'utc') - make_interval(secs => $1)`,
+		retention.Seconds(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(count), nil
+}
+
+func (p *postgresStore) Quota(orgID string) (*Quota, error) {
+	return p.quota(p.db, orgID)
+}
+
+func (p *postgresStore) SetQuotaOverride(o *QuotaOverride) error {
+	_, err := p.db.Exec(
+		`insert into org_quotas (org_id, max_registrations, max_allowlist_blocks)
+		values ($1, $2, $3)
+		on conflict (org_id) do update set
+			max_registrations = excluded.max_registrations,
+			max_allowlist_blocks = excluded.max_allowlist_blocks,
+			updated_at = now()`,
+		o.OrgID,
+		nullInt(o.Registrations),
+		nullInt(o.AllowlistBlocks),
+	)
+	return err
+}
+
+func (p *postgresStore) DeleteQuotaOverride(orgID string) error {
+	_, err := p.db.Exec(`delete from org_quotas where org_id = $1`, orgID)
+	return err
+}
+
+// checkQuota is run after inserting within a transaction, failing if that
+// took the org over its limit for the resource. The per-org advisory lock is
+// held until the transaction ends so concurrent inserts are counted one after
+// the other rather than both squeezing in under the limit.
+func (p *postgresStore) checkQuota(tx *sql.Tx, orgID, resource string) error {
+	// the lock has to be its own statement, the count needs a snapshot taken
+	// after the lock is acquired to see everything committed before it
+	if err := p.lockOrg(tx, orgID); err != nil {
+		return err
+	}
+
+	q, err := p.quota(tx, orgID)
+	if err != nil {
+		return err
+	}
+
+	limit, usage := q.Limits.Registrations, q.Registrations
+	if resource == QuotaAllowlistBlocks {
+		limit, usage = q.Limits.AllowlistBlocks, q.AllowlistBlocks
+	}
+
+	if limit > 0 && usage > limit {
+		return ErrQuotaExceeded{Resource: resource, Limit: limit}
+	}
+	return nil
+}
+
+// lockOrg serializes changes to an org's registrations/allowlist, the lock is
+// held until the transaction ends and can be taken more than once.
+func (p *postgresStore) lockOrg(tx *sql.Tx, orgID string) error {
+	_, err := tx.Exec(`select pg_advisory_xact_lock(hashtext('org_lock:' || $1))`, orgID)
+	return err
+}
+
+// satisfied by both *sql.DB and *sql.Tx
+type rowQuerier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func (p *postgresStore) quota(db rowQuerier, orgID string) (*Quota, error) {
+	var maxRegistrations, maxAllowlistBlocks sql.NullInt64
+	q := Quota{OrgID: orgID, Limits: p.defaultQuota}
+
+	err := db.QueryRow(`select
+		(select max_registrations from org_quotas where org_id = $1),
+		(select max_allowlist_blocks from org_quotas where org_id = $1),
+		(select count(id) from registrations where org_id = $1 and deleted_at is null),
+		(select count(*) from allowlist where org_id = $1 and (expires_at is null or expires_at > (now() at time zone 'utc')))`,
+		orgID,
+	).Scan(&maxRegistrations, &maxAllowlistBlocks, &q.Registrations, &q.AllowlistBlocks)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxRegistrations.Valid {
+		q.Limits.Registrations = int(maxRegistrations.Int64)
+	}
+	if maxAllowlistBlocks.Valid {
+		q.Limits.AllowlistBlocks = int(maxAllowlistBlocks.Int64)
+	}
+
+	return &q, nil
+}
+
+func nullInt(i *int) sql.NullInt64 {
+	if i == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*i), Valid: true}
+}
+
 // implement our own teeny scanner interface so we can use both sql.Row and/or sql.Rows
 type scanner interface {
 	Scan(dest ...any) error
@@ -144,8 +512,10 @@ func scanRegistration(row scanner) (*Registration, error) {
 		displayName string
 		extra       []byte
 		createdAt   time.Time
+		updatedAt   time.Time
+		expiresAt   sql.NullTime
 	)
-	err := row.Scan(&id, &orgID, &username, &uid, &displayName, &extra, &createdAt)
+	err := row.Scan(&id, &orgID, &username, &uid, &displayName, &extra, &createdAt, &updatedAt, &expiresAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrRegistrationNotFound
@@ -161,6 +531,11 @@ func scanRegistration(row scanner) (*Registration, error) {
 		}
 	}
 
+	var expires *time.Time
+	if expiresAt.Valid {
+		expires = &expiresAt.Time
+	}
+
 	return &Registration{
 		ID:          id,
 		OrgID:       orgID,
@@ -169,16 +544,34 @@ func scanRegistration(row scanner) (*Registration, error) {
 		DisplayName: displayName,
 		Extra:       e,
 		CreatedAt:   createdAt,
+		UpdatedAt:   updatedAt,
+		ExpiresAt:   expires,
 	}, nil
 }
 
+// timestamps are stored without a time zone, so always write them as UTC
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
 func (p *postgresStore) AllowedIP(ip string, orgID string) (bool, error) {
+	// anything that isn't an address would just fail the cast below
+	if _, err := parseBlock(ip); err != nil {
+		return false, nil
+	}
+
 	// turns out postgres can do this on the backend! see old code that accomplishes the same thing at commit dca8f2c
 
-	// basically its doing a subquery selecting all blocks from the org or
-	// gateway and then shoving them into an array and checking if the ip exists
-	// in those blocks.
-	row := p.db.QueryRow(`select $1::inet << any(array(select ip_block from allowlist where org_id = $2)::inet[])`, ip, orgID)
+	// basically its doing a subquery selecting all unexpired blocks from the
+	// org or gateway and then shoving them into an array and checking if the
+	// ip exists in those blocks.
+	row := p.db.QueryRow(`select $1::inet <<= any(array(
+		select ip_block from allowlist
+		where org_id = $2 and (expires_at is null or expires_at > (now() at time zone 'utc'))
+	)::inet[])`, ip, orgID)
 
 	var valid bool
 	err := row.Scan(&valid)
@@ -190,8 +583,75 @@ func (p *postgresStore) AllowedIP(ip string, orgID string) (bool, error) {
 }
 
 func (p *postgresStore) AllowAddress(ip *AllowlistBlock) error {
-	_, err := p.db.Exec(`insert into allowlist (ip_block, org_id) values ($1, $2)`, ip.IPBlock, ip.OrgID)
-	return err
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	//nolint:errcheck
+	defer tx.Rollback()
+
+	if err := p.lockOrg(tx, ip.OrgID); err != nil {
+		return err
+	}
+
+	// inet's && is true when either block contains the other, expired blocks
+	// don't count since they don't allow anything anymore
+	rows, err := tx.Query(`select ip_block,
+		case
+			when network(ip_block::inet) = network($1::inet) then $3
+			when ip_block::inet >> $1::inet then $4
+			else $5
+		end
+		from allowlist
+		where org_id = $2 and ip_block::inet && $1::inet
+			and (expires_at is null or expires_at > (now() at time zone 'utc'))
+		order by ip_block`,
+		ip.IPBlock, ip.OrgID, AllowlistDuplicate, AllowlistSuperset, AllowlistSubset,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	conflicts := make([]AllowlistConflict, 0)
+	for rows.Next() {
+		c := AllowlistConflict{Candidate: ip.IPBlock}
+		if err := rows.Scan(&c.IPBlock, &c.Relation); err != nil {
+			return err
+		}
+		conflicts = append(conflicts, c)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return ErrAllowlistConflict{Conflicts: conflicts}
+	}
+
+	// the only row that can already be there is an expired one, which is
+	// replaced as if it had been added fresh
+	_, err = tx.Exec(
+		`insert into allowlist (ip_block, org_id, description, created_by, expires_at) values ($1, $2, $3, $4, $5)
+		on conflict (ip_block, org_id) do update set
+			description = excluded.description,
+			created_by = excluded.created_by,
+			created_at = now(),
+			expires_at = excluded.expires_at`,
+		ip.IPBlock,
+		ip.OrgID,
+		ip.Description,
+		ip.CreatedBy,
+		nullTime(ip.ExpiresAt),
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := p.checkQuota(tx, ip.OrgID, QuotaAllowlistBlocks); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (p *postgresStore) DenyAddress(ip *AllowlistBlock) error {
@@ -211,33 +671,188 @@ func (p *postgresStore) DenyAddress(ip *AllowlistBlock) error {
 	return nil
 }
 
+func (p *postgresStore) ReplaceAllowlist(orgID string, blocks []AllowlistBlock) error {
+	conflicts, err := overlappingBlocks(blocks)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return ErrAllowlistConflict{Conflicts: conflicts}
+	}
+
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	//nolint:errcheck
+	defer tx.Rollback()
+
+	if err := p.lockOrg(tx, orgID); err != nil {
+		return err
+	}
+
+	ipBlocks := make([]string, len(blocks))
+	for i := range blocks {
+		ipBlocks[i] = blocks[i].IPBlock
+	}
+
+	_, err = tx.Exec(`delete from allowlist where org_id = $1 and not (ip_block = any($2))`, orgID, ipBlocks)
+	if err != nil {
+		return err
+	}
+
+	// blocks that are already there keep who/when they were created
+	for i := range blocks {
+		_, err = tx.Exec(
+			`insert into allowlist (ip_block, org_id, description, created_by, expires_at) values ($1, $2, $3, $4, $5)
+			on conflict (ip_block, org_id) do update set
+				description = excluded.description,
+				expires_at = excluded.expires_at`,
+			blocks[i].IPBlock,
+			orgID,
+			blocks[i].Description,
+			blocks[i].CreatedBy,
+			nullTime(blocks[i].ExpiresAt),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := p.checkQuota(tx, orgID, QuotaAllowlistBlocks); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	l.Log.Info("Replaced allowlist", "orgID", orgID, "count", len(blocks))
+	return nil
+}
+
 func (p *postgresStore) AllowedAddresses(orgID string) ([]AllowlistBlock, error) {
 	rows, err := p.db.Query(`select
-		org_id, ip_block, created_at
+		org_id, ip_block, description, created_by, created_at, expires_at
 		from allowlist
 		where org_id = $1`, orgID)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	addresses := make([]AllowlistBlock, 0)
 	for rows.Next() {
 		var (
-			orgID     string
-			block     string
-			createdAt time.Time
+			orgID       string
+			block       string
+			description string
+			createdBy   string
+			createdAt   time.Time
+			expiresAt   sql.NullTime
 		)
 
-		err = rows.Scan(&orgID, &block, &createdAt)
+		err = rows.Scan(&orgID, &block, &description, &createdBy, &createdAt, &expiresAt)
 		if err != nil {
 			return nil, err
 		}
 
+		var expires *time.Time
+		if expiresAt.Valid {
+			expires = &expiresAt.Time
+		}
+
 		addresses = append(addresses, AllowlistBlock{
-			IPBlock:   block,
-			OrgID:     orgID,
-			CreatedAt: createdAt,
+			IPBlock:     block,
+			OrgID:       orgID,
+			Description: description,
+			CreatedBy:   createdBy,
+			CreatedAt:   createdAt,
+			ExpiresAt:   expires,
 		})
 	}
 	return addresses, nil
 }
+
+func (p *postgresStore) RecordAudit(e *AuditEntry) error {
+	_, err := p.db.Exec(
+		`insert into audit_log (actor, org_id, action, target, source_ip) values ($1, $2, $3, $4, $5)`,
+		e.Actor,
+		e.OrgID,
+		e.Action,
+		e.Target,
+		e.SourceIP,
+	)
+	return err
+}
+
+func (p *postgresStore) AuditEntries(orgID string, limit, offset int) ([]AuditEntry, int, error) {
+	rows, err := p.db.Query(`select
+		id, actor, org_id, action, target, coalesce(source_ip, ''), created_at
+		from audit_log
+		where org_id = $1
+		order by created_at desc
+		limit $2
+		offset $3`,
+		orgID,
+		limit,
+		offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	out := make([]AuditEntry, 0)
+	for rows.Next() {
+		var e AuditEntry
+		err = rows.Scan(&e.ID, &e.Actor, &e.OrgID, &e.Action, &e.Target, &e.SourceIP, &e.CreatedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, e)
+	}
+
+	var count int
+	row := p.db.QueryRow(`select count(id) from audit_log where org_id = $1`, orgID)
+	if err := row.Scan(&count); err != nil {
+		return nil, 0, err
+	}
+
+	return out, count, nil
+}
+
+func (p *postgresStore) RevokeToken(r *TokenRevocation) error {
+	_, err := p.db.Exec(
+		`insert into token_revocations (jti, org_id, revoked_by, expires_at) values ($1, $2, $3, $4)
+		on conflict (jti, org_id) do nothing`,
+		r.JTI,
+		r.OrgID,
+		r.RevokedBy,
+		r.ExpiresAt.UTC(),
+	)
+	return err
+}
+
+func (p *postgresStore) TokenRevoked(orgID, jti string) (bool, error) {
+	var revoked bool
+	err := p.db.QueryRow(
+		`select exists(select 1 from token_revocations where org_id = $1 and jti = $2)`,
+		orgID,
+		jti,
+	).Scan(&revoked)
+	return revoked, err
+}
+
+func (p *postgresStore) PurgeExpiredRevocations() (int, error) {
+	res, err := p.db.Exec(`delete from token_revocations where expires_at < (now() at time zone 'utc')`)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(count), nil
+}
@@ -47,6 +47,21 @@ func (suite *TestSuite) BeforeTest(_, testName string) {
 	if err != nil {
 		suite.FailNow("failed to clear out table for test", "test %v, error: %v", testName, err)
 	}
+
+	_, err = suite.db.Exec(`delete from audit_log`)
+	if err != nil {
+		suite.FailNow("failed to clear out table for test", "test %v, error: %v", testName, err)
+	}
+
+	_, err = suite.db.Exec(`delete from org_quotas`)
+	if err != nil {
+		suite.FailNow("failed to clear out table for test", "test %v, error: %v", testName, err)
+	}
+
+	_, err = suite.db.Exec(`delete from token_revocations`)
+	if err != nil {
+		suite.FailNow("failed to clear out table for test", "test %v, error: %v", testName, err)
+	}
 }
 
 func TestSuiteRun(t *testing.T) {
@@ -130,6 +145,62 @@ func (suite *TestSuite) TestDelete() {
 	suite.Nil(err, "failed to delete item")
 }
 
+func (suite *TestSuite) TestDeleteIsSoft() {
+	r := Registration{OrgID: "1234", Username: "foobar", UID: "1234", DisplayName: "one"}
+	_, err := suite.store.Create(&r)
+	suite.Nil(err, "failed to setup for deletion")
+	suite.Nil(suite.store.Delete("1234", "1234"))
+
+	_, err = suite.store.Find("1234", "1234")
+	suite.ErrorIs(err, ErrRegistrationNotFound)
+	_, err = suite.store.FindByUID("1234")
+	suite.ErrorIs(err, ErrRegistrationNotFound)
+	_, count, err := suite.store.All("1234", 10, 0, nil)
+	suite.Nil(err)
+	suite.Equal(0, count)
+
+	// uniqueness only considers live rows
+	_, err = suite.store.Create(&r)
+	suite.Nil(err, "failed to re-create deleted registration")
+}
+
+func (suite *TestSuite) TestRestore() {
+	r := Registration{OrgID: "1234", Username: "foobar", UID: "1234", DisplayName: "one"}
+	_, err := suite.store.Create(&r)
+	suite.Nil(err)
+	suite.Nil(suite.store.Delete("1234", "1234"))
+
+	suite.Nil(suite.store.Restore("1234", "1234"))
+	_, err = suite.store.Find("1234", "1234")
+	suite.Nil(err)
+
+	suite.ErrorIs(suite.store.Restore("1234", "1234"), ErrRegistrationNotFound)
+}
+
+func (suite *TestSuite) TestRestoreConflict() {
+	_, err := suite.store.Create(&Registration{OrgID: "1234", Username: "foobar", UID: "1234", DisplayName: "one"})
+	suite.Nil(err)
+	suite.Nil(suite.store.Delete("1234", "1234"))
+	_, err = suite.store.Create(&Registration{OrgID: "1234", Username: "foobar", UID: "1234", DisplayName: "two"})
+	suite.Nil(err)
+
+	suite.ErrorIs(suite.store.Restore("1234", "1234"), ErrRegistrationAlreadyExists{})
+}
+
+func (suite *TestSuite) TestPurgeDeleted() {
+	_, err := suite.store.Create(&Registration{OrgID: "1234", Username: "foobar", UID: "1234", DisplayName: "one"})
+	suite.Nil(err)
+	suite.Nil(suite.store.Delete("1234", "1234"))
+
+	count, err := suite.store.PurgeDeleted(time.Hour)
+	suite.Nil(err)
+	suite.Equal(0, count)
+
+	count, err = suite.store.PurgeDeleted(0)
+	suite.Nil(err)
+	suite.Equal(1, count)
+}
+
 func (suite *TestSuite) TestDeleteNotExisting() {
 	err := suite.store.Delete("1234", "1234")
 	suite.Error(err, "failed to fail to delete item")
@@ -192,11 +263,25 @@ func (suite *TestSuite) TestFindAll() {
 	_, err = suite.store.Create(&r)
 	suite.Nil(err, "failed to insert")
 
-	_, count, err := suite.store.All("1234", 0, 0)
+	_, count, err := suite.store.All("1234", 0, 0, nil)
 	suite.Nil(err, "failed to list all registrations")
 	suite.Equal(count, 2)
 }
 
+func (suite *TestSuite) TestFindAllExtraFilter() {
+	_, err := suite.store.Create(&Registration{OrgID: "1234", UID: "1234", Username: "foobar", DisplayName: "one", Extra: map[string]interface{}{"version": "6.13"}})
+	suite.Nil(err, "failed to insert")
+	_, err = suite.store.Create(&Registration{OrgID: "1234", UID: "2345", Username: "foobar", DisplayName: "two", Extra: map[string]interface{}{"version": "6.12"}})
+	suite.Nil(err, "failed to insert")
+
+	regs, count, err := suite.store.All("1234", 10, 0, &RegistrationFilter{Extra: map[string]string{"version": "6.13"}})
+	suite.Nil(err, "failed to list filtered registrations")
+	suite.Equal(1, count)
+	suite.Equal("1234", regs[0].UID)
+	suite.Equal("6.13", regs[0].Extra["version"])
+	suite.False(regs[0].UpdatedAt.IsZero())
+}
+
 func (suite *TestSuite) TestUpdate() {
 	r := Registration{OrgID: "1234", UID: "1234"}
 	_, err := suite.store.Create(&r)
@@ -209,6 +294,38 @@ func (suite *TestSuite) TestUpdate() {
 	suite.Nil(err, "failed to update registration")
 }
 
+func (suite *TestSuite) TestUpdateDisplayName() {
+	r := Registration{OrgID: "1234", UID: "1234", Username: "foobar", DisplayName: "one"}
+	_, err := suite.store.Create(&r)
+	suite.Nil(err, "failed to insert")
+
+	name := "renamed"
+	err = suite.store.Update(&r, &RegistrationUpdate{DisplayName: &name})
+	suite.Nil(err, "failed to update registration")
+
+	found, err := suite.store.Find("1234", "1234")
+	suite.Nil(err)
+	suite.Equal("renamed", found.DisplayName)
+}
+
+func (suite *TestSuite) TestUpdateDuplicateDisplayNameSameOrg() {
+	r := Registration{OrgID: "1234", UID: "1234", Username: "foobar", DisplayName: "one"}
+	_, err := suite.store.Create(&r)
+	suite.Nil(err, "failed to insert")
+	_, err = suite.store.Create(&Registration{OrgID: "1234", UID: "2345", Username: "foobar", DisplayName: "two"})
+	suite.Nil(err, "failed to insert")
+
+	name := "two"
+	err = suite.store.Update(&r, &RegistrationUpdate{DisplayName: &name})
+	suite.ErrorIs(err, ErrRegistrationAlreadyExists{})
+}
+
+func (suite *TestSuite) TestUpdateNotThere() {
+	name := "two"
+	err := suite.store.Update(&Registration{OrgID: "1234", UID: "1234"}, &RegistrationUpdate{DisplayName: &name})
+	suite.ErrorIs(err, ErrRegistrationNotFound)
+}
+
 func (suite *TestSuite) TestFindAllWithPagination() {
 	for i := 0; i < 10; i++ {
 		s := strconv.Itoa(i)
@@ -221,26 +338,55 @@ func (suite *TestSuite) TestFindAllWithPagination() {
 	}
 
 	// stepping through the pages ensuring they start/end with where it's expected
-	regs, count, err := suite.store.All("a", 5, 0)
+	regs, count, err := suite.store.All("a", 5, 0, nil)
 	suite.Nil(err)
 	suite.Equal(10, count)
 	suite.Equal(5, len(regs))
 	suite.Equal("9", regs[0].UID)
 	suite.Equal("5", regs[len(regs)-1].UID)
 
-	regs, count, err = suite.store.All("a", 5, 5)
+	regs, count, err = suite.store.All("a", 5, 5, nil)
 	suite.Nil(err)
 	suite.Equal(10, count)
 	suite.Equal(5, len(regs))
 	suite.Equal("4", regs[0].UID)
 	suite.Equal("0", regs[len(regs)-1].UID)
 
-	regs, count, err = suite.store.All("a", 5, 10)
+	regs, count, err = suite.store.All("a", 5, 10, nil)
 	suite.Nil(err)
 	suite.Equal(10, count)
 	suite.Equal(0, len(regs))
 }
 
+func (suite *TestSuite) TestFindAllSearchAndSort() {
+	for _, name := range []string{"rdu-sat-2", "bos-sat-1", "rdu-sat-1"} {
+		_, err := suite.store.Create(&Registration{OrgID: "a", UID: name, DisplayName: name, Username: "foobar"})
+		suite.Nil(err)
+	}
+	_, err := suite.store.Create(&Registration{OrgID: "a", UID: "other", DisplayName: "RDU-sat-3", Username: "barfoo"})
+	suite.Nil(err)
+
+	regs, count, err := suite.store.All("a", 10, 0, &RegistrationFilter{
+		DisplayName: "rdu",
+		Username:    "foobar",
+		SortBy:      "display_name",
+		SortOrder:   "asc",
+	})
+	suite.Nil(err)
+	suite.Equal(2, count)
+	suite.Equal("rdu-sat-1", regs[0].DisplayName)
+	suite.Equal("rdu-sat-2", regs[1].DisplayName)
+
+	// underscores are matched literally rather than as a wildcard
+	_, count, err = suite.store.All("a", 10, 0, &RegistrationFilter{DisplayName: "rdu_"})
+	suite.Nil(err)
+	suite.Equal(0, count)
+
+	_, count, err = suite.store.All("a", 10, 0, &RegistrationFilter{CreatedAfter: time.Now().Add(time.Hour)})
+	suite.Nil(err)
+	suite.Equal(0, count)
+}
+
 func (suite *TestSuite) TestIPAllowedHappyPath() {
 	config.Reset()
 	defer config.Reset()
@@ -294,3 +440,311 @@ func (suite *TestSuite) TestIPAllowedHappyMultiple() {
 		suite.Nil(err)
 	}
 }
+
+func (suite *TestSuite) TestAuditEntries() {
+	for _, action := range []string{AuditRegistrationCreate, AuditRegistrationDelete} {
+		suite.Nil(suite.store.RecordAudit(&AuditEntry{
+			Actor:    "foobar",
+			OrgID:    "1234",
+			Action:   action,
+			Target:   "abc1234",
+			SourceIP: "10.0.0.1",
+		}))
+	}
+	suite.Nil(suite.store.RecordAudit(&AuditEntry{Actor: "foobar", OrgID: "2345", Action: AuditAllowlistCreate, Target: "10.0.0.0/24"}))
+
+	entries, count, err := suite.store.AuditEntries("1234", 10, 0)
+	suite.Nil(err)
+	suite.Equal(2, count)
+	suite.Equal(AuditRegistrationDelete, entries[0].Action)
+	suite.Equal("foobar", entries[0].Actor)
+	suite.Equal("10.0.0.1", entries[0].SourceIP)
+	suite.WithinDuration(time.Now(), entries[0].CreatedAt, 5*time.Second)
+}
+
+func (suite *TestSuite) TestRotateUID() {
+	_, err := suite.store.Create(&Registration{OrgID: "1234", Username: "foobar", UID: "1234", DisplayName: "one"})
+	suite.Nil(err)
+
+	suite.Nil(suite.store.RotateUID("1234", "1234", "2345"))
+	_, err = suite.store.Find("1234", "1234")
+	suite.ErrorIs(err, ErrRegistrationNotFound)
+	r, err := suite.store.Find("1234", "2345")
+	suite.Nil(err)
+	suite.Equal("one", r.DisplayName)
+}
+
+func (suite *TestSuite) TestRotateUIDConflict() {
+	_, err := suite.store.Create(&Registration{OrgID: "1234", Username: "foobar", UID: "1234", DisplayName: "one"})
+	suite.Nil(err)
+	_, err = suite.store.Create(&Registration{OrgID: "1234", Username: "foobar", UID: "2345", DisplayName: "two"})
+	suite.Nil(err)
+
+	suite.ErrorIs(suite.store.RotateUID("1234", "1234", "2345"), ErrRegistrationAlreadyExists{})
+}
+
+func (suite *TestSuite) TestRotateUIDNotThere() {
+	suite.ErrorIs(suite.store.RotateUID("1234", "1234", "2345"), ErrRegistrationNotFound)
+}
+
+func (suite *TestSuite) TestExpiresAt() {
+	expiresAt := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+	_, err := suite.store.Create(&Registration{OrgID: "1234", Username: "foobar", UID: "1234", DisplayName: "one", ExpiresAt: &expiresAt})
+	suite.Nil(err)
+
+	r, err := suite.store.Find("1234", "1234")
+	suite.Nil(err)
+	suite.NotNil(r.ExpiresAt)
+	suite.True(r.Expired())
+}
+
+func (suite *TestSuite) TestImport() {
+	_, err := suite.store.Create(&Registration{OrgID: "1234", Username: "foobar", UID: "2345", DisplayName: "existing"})
+	suite.Nil(err)
+
+	results, err := suite.store.Import([]Registration{
+		{OrgID: "1234", Username: "foobar", UID: "1234", DisplayName: "one"},
+		{OrgID: "1234", Username: "foobar", UID: "2345", DisplayName: "two"},
+		{OrgID: "1234", Username: "foobar", UID: "3456", DisplayName: "three"},
+		{OrgID: "1234", Username: "foobar", UID: "3456", DisplayName: "four"},
+	})
+	suite.Nil(err)
+	suite.Len(results, 4)
+	suite.Equal(ImportCreated, results[0].Status)
+	suite.Equal(ImportConflict, results[1].Status)
+	suite.Equal(ImportCreated, results[2].Status)
+	suite.Equal(ImportConflict, results[3].Status)
+
+	_, count, err := suite.store.All("1234", 10, 0, nil)
+	suite.Nil(err)
+	suite.Equal(3, count)
+}
+
+func (suite *TestSuite) TestQuotaOverride() {
+	registrations, blocks := 1, 1
+	suite.Nil(suite.store.SetQuotaOverride(&QuotaOverride{OrgID: "1234", Registrations: &registrations, AllowlistBlocks: &blocks}))
+
+	_, err := suite.store.Create(&Registration{OrgID: "1234", Username: "foobar", UID: "1234", DisplayName: "one"})
+	suite.Nil(err)
+	_, err = suite.store.Create(&Registration{OrgID: "1234", Username: "foobar", UID: "2345", DisplayName: "two"})
+	suite.ErrorIs(err, ErrQuotaExceeded{})
+	// other orgs aren't affected
+	_, err = suite.store.Create(&Registration{OrgID: "4321", Username: "foobar", UID: "3456", DisplayName: "three"})
+	suite.Nil(err)
+
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.0.0/24"}))
+	suite.ErrorIs(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.1.0/24"}), ErrQuotaExceeded{})
+
+	q, err := suite.store.Quota("1234")
+	suite.Nil(err)
+	suite.Equal(QuotaLimits{Registrations: 1, AllowlistBlocks: 1}, q.Limits)
+	suite.Equal(1, q.Registrations)
+	suite.Equal(1, q.AllowlistBlocks)
+
+	suite.Nil(suite.store.DeleteQuotaOverride("1234"))
+	_, err = suite.store.Create(&Registration{OrgID: "1234", Username: "foobar", UID: "2345", DisplayName: "two"})
+	suite.Nil(err)
+}
+
+func (suite *TestSuite) TestQuotaRestore() {
+	registrations := 1
+	suite.Nil(suite.store.SetQuotaOverride(&QuotaOverride{OrgID: "1234", Registrations: &registrations}))
+
+	_, err := suite.store.Create(&Registration{OrgID: "1234", Username: "foobar", UID: "1234", DisplayName: "one"})
+	suite.Nil(err)
+	suite.Nil(suite.store.Delete("1234", "1234"))
+	_, err = suite.store.Create(&Registration{OrgID: "1234", Username: "foobar", UID: "2345", DisplayName: "two"})
+	suite.Nil(err)
+
+	suite.ErrorIs(suite.store.Restore("1234", "1234"), ErrQuotaExceeded{})
+}
+
+func (suite *TestSuite) TestQuotaImport() {
+	registrations := 2
+	suite.Nil(suite.store.SetQuotaOverride(&QuotaOverride{OrgID: "1234", Registrations: &registrations}))
+
+	results, err := suite.store.Import([]Registration{
+		{OrgID: "1234", Username: "foobar", UID: "1234", DisplayName: "one"},
+		{OrgID: "1234", Username: "foobar", UID: "2345", DisplayName: "two"},
+		{OrgID: "1234", Username: "foobar", UID: "3456", DisplayName: "three"},
+	})
+	suite.Nil(err)
+	suite.Equal(ImportCreated, results[1].Status)
+	suite.Equal(ImportConflict, results[2].Status)
+	suite.Equal(ErrQuotaExceeded{Resource: QuotaRegistrations, Limit: 2}.Error(), results[2].Detail)
+}
+
+func (suite *TestSuite) TestAllowAddressConflicts() {
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.0.0/24"}))
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.1.0/24"}))
+	// other orgs don't conflict
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "4321", IPBlock: "10.0.0.0/8"}))
+
+	err := suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.0.0/16"})
+	suite.ErrorIs(err, ErrAllowlistConflict{})
+	suite.Equal(ErrAllowlistConflict{Conflicts: []AllowlistConflict{
+		{IPBlock: "10.0.0.0/24", Relation: AllowlistSubset, Candidate: "10.0.0.0/16"},
+		{IPBlock: "10.0.1.0/24", Relation: AllowlistSubset, Candidate: "10.0.0.0/16"},
+	}}, err)
+
+	err = suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.0.5/32"})
+	suite.Equal(ErrAllowlistConflict{Conflicts: []AllowlistConflict{{IPBlock: "10.0.0.0/24", Relation: AllowlistSuperset, Candidate: "10.0.0.5/32"}}}, err)
+
+	err = suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.1.0/24"})
+	suite.Equal(ErrAllowlistConflict{Conflicts: []AllowlistConflict{{IPBlock: "10.0.1.0/24", Relation: AllowlistDuplicate, Candidate: "10.0.1.0/24"}}}, err)
+}
+
+func (suite *TestSuite) TestAllowedIPv6() {
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "2001:db8::/32"}))
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "2001:db9::1/128"}))
+	// same leading bits as the v6 block, but a different family
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "32.1.0.0/16"}))
+
+	for ip, expected := range map[string]bool{
+		"2001:db8::1":     true,
+		"2001:db8:ffff::": true,
+		"2001:db9::1":     true,
+		"2001:db9::2":     false,
+		"2001:db7::1":     false,
+		"32.1.13.184":     true,
+		"::ffff:8.8.8.8":  false,
+		"not an ip":       false,
+	} {
+		allowed, err := suite.store.AllowedIP(ip, "1234")
+		suite.Nil(err)
+		suite.Equal(expected, allowed, ip)
+	}
+}
+
+func (suite *TestSuite) TestAllowedIPOtherOrg() {
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.0.0/24"}))
+
+	allowed, err := suite.store.AllowedIP("10.0.0.1", "4321")
+	suite.Nil(err)
+	suite.False(allowed)
+
+	addrs, err := suite.store.AllowedAddresses("4321")
+	suite.Nil(err)
+	suite.Len(addrs, 0)
+}
+
+func (suite *TestSuite) TestAllowedSingleIP() {
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.0.1/32"}))
+
+	allowed, err := suite.store.AllowedIP("10.0.0.1", "1234")
+	suite.Nil(err)
+	suite.True(allowed)
+}
+
+func (suite *TestSuite) TestAllowedIPExpired() {
+	expired := time.Now().Add(-time.Minute)
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.0.0/24", ExpiresAt: &expired}))
+	future := time.Now().Add(time.Hour)
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.1.0/24", ExpiresAt: &future}))
+
+	allowed, err := suite.store.AllowedIP("10.0.0.1", "1234")
+	suite.Nil(err)
+	suite.False(allowed)
+
+	allowed, err = suite.store.AllowedIP("10.0.1.1", "1234")
+	suite.Nil(err)
+	suite.True(allowed)
+}
+
+func (suite *TestSuite) TestReplaceAllowlist() {
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.0.0/24", CreatedBy: "first", Description: "old"}))
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "10.0.1.0/24", CreatedBy: "first"}))
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "4321", IPBlock: "10.0.1.0/24", CreatedBy: "first"}))
+
+	suite.Nil(suite.store.ReplaceAllowlist("1234", []AllowlistBlock{
+		{IPBlock: "10.0.0.0/24", CreatedBy: "second", Description: "new"},
+		{IPBlock: "2001:db8::/32", CreatedBy: "second"},
+	}))
+
+	addrs, err := suite.store.AllowedAddresses("1234")
+	suite.Nil(err)
+	suite.Len(addrs, 2)
+	for _, addr := range addrs {
+		switch addr.IPBlock {
+		case "10.0.0.0/24":
+			suite.Equal("first", addr.CreatedBy)
+			suite.Equal("new", addr.Description)
+		case "2001:db8::/32":
+			suite.Equal("second", addr.CreatedBy)
+		default:
+			suite.Fail("unexpected block", addr.IPBlock)
+		}
+	}
+
+	// other orgs are left alone
+	addrs, err = suite.store.AllowedAddresses("4321")
+	suite.Nil(err)
+	suite.Len(addrs, 1)
+}
+
+func (suite *TestSuite) TestReplaceAllowlistOverlapping() {
+	suite.Nil(suite.store.AllowAddress(&AllowlistBlock{OrgID: "1234", IPBlock: "192.168.0.0/24"}))
+
+	err := suite.store.ReplaceAllowlist("1234", []AllowlistBlock{
+		{IPBlock: "10.0.0.0/16"},
+		{IPBlock: "10.0.1.0/24"},
+	})
+	suite.Equal(ErrAllowlistConflict{Conflicts: []AllowlistConflict{
+		{IPBlock: "10.0.0.0/16", Relation: AllowlistSuperset, Candidate: "10.0.1.0/24"},
+	}}, err)
+
+	// nothing changed
+	addrs, err := suite.store.AllowedAddresses("1234")
+	suite.Nil(err)
+	suite.Len(addrs, 1)
+}
+
+func (suite *TestSuite) TestReplaceAllowlistQuota() {
+	blocks := 1
+	suite.Nil(suite.store.SetQuotaOverride(&QuotaOverride{OrgID: "1234", AllowlistBlocks: &blocks}))
+
+	err := suite.store.ReplaceAllowlist("1234", []AllowlistBlock{
+		{IPBlock: "10.0.0.0/24"},
+		{IPBlock: "10.0.1.0/24"},
+	})
+	suite.ErrorIs(err, ErrQuotaExceeded{})
+
+	suite.Nil(suite.store.ReplaceAllowlist("1234", nil))
+}
+
+func (suite *TestSuite) TestRevokeToken() {
+	rev := TokenRevocation{JTI: "abc", OrgID: "1234", RevokedBy: "foobar", ExpiresAt: time.Now().Add(time.Hour)}
+	suite.Nil(suite.store.RevokeToken(&rev))
+	// revoking twice is fine
+	suite.Nil(suite.store.RevokeToken(&rev))
+
+	revoked, err := suite.store.TokenRevoked("1234", "abc")
+	suite.Nil(err)
+	suite.True(revoked)
+
+	// revocations are per org
+	revoked, err = suite.store.TokenRevoked("4321", "abc")
+	suite.Nil(err)
+	suite.False(revoked)
+
+	revoked, err = suite.store.TokenRevoked("1234", "def")
+	suite.Nil(err)
+	suite.False(revoked)
+}
+
+func (suite *TestSuite) TestPurgeExpiredRevocations() {
+	suite.Nil(suite.store.RevokeToken(&TokenRevocation{JTI: "expired", OrgID: "1234", ExpiresAt: time.Now().Add(-time.Minute)}))
+	suite.Nil(suite.store.RevokeToken(&TokenRevocation{JTI: "current", OrgID: "1234", ExpiresAt: time.Now().Add(time.Hour)}))
+
+	count, err := suite.store.PurgeExpiredRevocations()
+	suite.Nil(err)
+	suite.Equal(1, count)
+
+	revoked, err := suite.store.TokenRevoked("1234", "expired")
+	suite.Nil(err)
+	suite.False(revoked)
+
+	revoked, err = suite.store.TokenRevoked("1234", "current")
+	suite.Nil(err)
+	suite.True(revoked)
+}
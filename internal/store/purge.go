@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	l "github.com/redhatinsights/mbop/internal/logger"
+)
+
+// PurgeDeletedRegistrations permanently removes soft-deleted registrations
+// older than the retention period every interval, until the context is done.
+func PurgeDeletedRegistrations(ctx context.Context, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := GetStore().PurgeDeleted(retention)
+			if err != nil {
+				l.Log.Error(err, "failed to purge deleted registrations")
+				continue
+			}
+
+			if count > 0 {
+				l.Log.Info("Purged deleted registrations", "count", count, "retention", retention.String())
+			}
+		}
+	}
+}
+
+// PurgeExpiredRevocations removes the revocations for tokens that have expired
+// anyway every interval, until the context is done.
+func PurgeExpiredRevocations(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := GetStore().PurgeExpiredRevocations()
+			if err != nil {
+				l.Log.Error(err, "failed to purge expired token revocations")
+				continue
+			}
+
+			if count > 0 {
+				l.Log.Info("Purged expired token revocations", "count", count)
+			}
+		}
+	}
+}
@@ -24,7 +24,7 @@ func SetupStore() error {
 
 		GetStore = func() Store { return pgStore }
 	case "memory":
-		mem = &inMemoryStore{}
+		mem = &inMemoryStore{defaultQuota: defaultQuota()}
 		GetStore = func() Store { return mem }
 	}
 
@@ -52,5 +52,14 @@ func setupPostgresStore() (*postgresStore, error) {
 		return nil, err
 	}
 
-	return &postgresStore{db: db}, nil
+	return &postgresStore{db: db, defaultQuota: defaultQuota()}, nil
+}
+
+// the global quota limits from config, applying to any org without an override
+func defaultQuota() QuotaLimits {
+	c := config.Get()
+	return QuotaLimits{
+		Registrations:   c.RegistrationQuota,
+		AllowlistBlocks: c.AllowlistQuota,
+	}
 }
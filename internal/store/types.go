@@ -10,6 +10,8 @@ Registration represents an instance of a satellite that is registered via:
 
 ID is a generated UUID
 Extra is just a jsonb column if we want to store some extra metadata someday
+DeletedAt is set when the registration is soft-deleted, pending restore or purge
+ExpiresAt is optional, after which the registration can no longer authenticate
 */
 type Registration struct {
 	ID          string
@@ -19,14 +21,150 @@ type Registration struct {
 	DisplayName string
 	Extra       map[string]interface{}
 	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	ExpiresAt   *time.Time
+	DeletedAt   *time.Time
 }
 
+// Expired is whether the registration has an expiry that has passed
+func (r *Registration) Expired() bool {
+	return r.ExpiresAt != nil && time.Now().After(*r.ExpiresAt)
+}
+
+// RegistrationUpdate holds the fields that can be changed on an existing
+// registration, nil fields are left untouched.
 type RegistrationUpdate struct {
-	Extra *map[string]interface{}
+	DisplayName *string
+	Extra       *map[string]interface{}
+	ExpiresAt   *time.Time
+}
+
+// RegistrationFilter narrows down (and orders) the registrations returned from
+// All, zero values are ignored.
+//
+// DisplayName is a case-insensitive substring search, Extra matches on the
+// (stringified) top-level values of the extra column. SortBy has to be one of
+// the RegistrationSortFields, defaulting to created_at desc.
+type RegistrationFilter struct {
+	DisplayName   string
+	Username      string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Extra         map[string]string
+	SortBy        string
+	SortOrder     string
+}
+
+// the fields registrations can be sorted by, mapping directly to their columns
+var RegistrationSortFields = []string{"created_at", "updated_at", "display_name", "uid", "username"}
+
+// the outcome of importing a single registration
+const (
+	ImportCreated  = "created"
+	ImportConflict = "conflict"
+	ImportInvalid  = "invalid"
+)
+
+// ImportResult is the outcome for one row of a bulk import, Detail explains
+// why a row wasn't created.
+type ImportResult struct {
+	UID    string
+	Status string
+	Detail string
 }
 
+/*
+AllowlistBlock is a range of addresses an org's satellites can register from:
+- Description; free text on why the block is there
+- CreatedBy; the username that added the block
+- ExpiresAt is optional, after which the block no longer matches
+*/
 type AllowlistBlock struct {
-	IPBlock   string
+	IPBlock     string
+	OrgID       string
+	Description string
+	CreatedBy   string
+	CreatedAt   time.Time
+	ExpiresAt   *time.Time
+}
+
+// Expired is whether the block has an expiry that has passed
+func (b *AllowlistBlock) Expired() bool {
+	return b.ExpiresAt != nil && time.Now().After(*b.ExpiresAt)
+}
+
+// the resources that are limited per org
+const (
+	QuotaRegistrations   = "registrations"
+	QuotaAllowlistBlocks = "allowlist blocks"
+)
+
+// QuotaLimits caps how many registrations and allowlist blocks an org can
+// have, 0 meaning unlimited.
+type QuotaLimits struct {
+	Registrations   int
+	AllowlistBlocks int
+}
+
+// QuotaOverride replaces the global default limits for a single org, nil
+// limits fall back to the default.
+type QuotaOverride struct {
+	OrgID           string
+	Registrations   *int
+	AllowlistBlocks *int
+}
+
+// Quota is the limits in effect for an org alongside its current usage.
+type Quota struct {
+	OrgID           string
+	Limits          QuotaLimits
+	Registrations   int
+	AllowlistBlocks int
+}
+
+// the actions recorded in the audit log
+const (
+	AuditRegistrationCreate  = "registration.create"
+	AuditRegistrationUpdate  = "registration.update"
+	AuditRegistrationDelete  = "registration.delete"
+	AuditRegistrationRestore = "registration.restore"
+	AuditRegistrationRotate  = "registration.rotate"
+	AuditRegistrationImport  = "registration.import"
+	AuditAllowlistCreate     = "allowlist.create"
+	AuditAllowlistDelete     = "allowlist.delete"
+	AuditAllowlistReplace    = "allowlist.replace"
+	AuditTokenRevoke         = "token.revoke"
+)
+
+/*
+AuditEntry is a record of a mutation made through the API:
+- Actor; the username from the identity making the change
+- Action; one of the Audit* constants
+- Target; what was changed, e.g. the registration uid or the ip block
+- SourceIP; the address the request came from
+*/
+type AuditEntry struct {
+	ID        string
+	Actor     string
 	OrgID     string
+	Action    string
+	Target    string
+	SourceIP  string
 	CreatedAt time.Time
 }
+
+/*
+TokenRevocation marks one of the org's tokens as no longer valid before it
+would otherwise expire:
+- JTI; the token's jti claim
+- RevokedBy; the username that revoked it
+- ExpiresAt; when the token itself expires, after which the revocation is no
+longer needed and gets purged
+*/
+type TokenRevocation struct {
+	JTI       string
+	OrgID     string
+	RevokedBy string
+	RevokedAt time.Time
+	ExpiresAt time.Time
+}